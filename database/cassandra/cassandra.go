@@ -17,11 +17,6 @@ import (
 	"github.com/hashicorp/go-multierror"
 )
 
-func init() {
-	db := new(Cassandra)
-	database.Register("cassandra", db)
-}
-
 var (
 	multiStmtDelimiter = []byte(";")
 
@@ -35,6 +30,7 @@ var (
 	ErrNoKeyspace    = errors.New("no keyspace provided")
 	ErrDatabaseDirty = errors.New("database is dirty")
 	ErrClosedSession = errors.New("session is closed")
+	ErrShortLockTTL  = errors.New("lock TTL must be at least 1s, Cassandra TTLs are whole seconds")
 )
 
 type Config struct {
@@ -42,6 +38,7 @@ type Config struct {
 	KeyspaceName          string
 	MultiStatementEnabled bool
 	MultiStatementMaxSize int
+	LockTTL               time.Duration
 }
 
 type Cassandra struct {
@@ -57,6 +54,8 @@ func WithInstance(session *gocql.Session, config *Config) (database.Driver, erro
 		return nil, ErrNilConfig
 	} else if len(config.KeyspaceName) == 0 {
 		return nil, ErrNoKeyspace
+	} else if config.LockTTL != 0 && config.LockTTL < time.Second {
+		return nil, fmt.Errorf("%w: %v", ErrShortLockTTL, config.LockTTL)
 	}
 
 	if session.Closed() {
@@ -80,7 +79,9 @@ func WithInstance(session *gocql.Session, config *Config) (database.Driver, erro
 		return nil, err
 	}
 
-	return c, nil
+	return &CassandraExtras{
+		Cassandra: c,
+	}, nil
 }
 
 func (c *Cassandra) Open(url string) (database.Driver, error) {
@@ -94,6 +95,17 @@ func (c *Cassandra) Open(url string) (database.Driver, error) {
 		return nil, ErrNoKeyspace
 	}
 
+	var lockTTL time.Duration
+	if s := u.Query().Get("x-lock-ttl"); len(s) > 0 {
+		lockTTL, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		if lockTTL < time.Second {
+			return nil, fmt.Errorf("%w: x-lock-ttl=%s", ErrShortLockTTL, s)
+		}
+	}
+
 	cluster := gocql.NewCluster(u.Host)
 	cluster.Keyspace = strings.TrimPrefix(u.Path, "/")
 	cluster.Consistency = gocql.All
@@ -190,6 +202,7 @@ func (c *Cassandra) Open(url string) (database.Driver, error) {
 		MigrationsTable:       u.Query().Get("x-migrations-table"),
 		MultiStatementEnabled: u.Query().Get("x-multi-statement") == "true",
 		MultiStatementMaxSize: multiStatementMaxSize,
+		LockTTL:               lockTTL,
 	})
 }
 
@@ -246,12 +259,14 @@ func (c *Cassandra) Run(migration io.Reader) error {
 func (c *Cassandra) SetVersion(version int, dirty bool) error {
 	// DELETE instead of TRUNCATE because AWS Keyspaces does not support it
 	// see: https://docs.aws.amazon.com/keyspaces/latest/devguide/cassandra-apis.html
+	// Every write is a lightweight transaction, as those of CassandraExtras to the same partitions,
+	// because mixing them with plain writes breaks their linearizability.
 	squery := `SELECT version FROM "` + c.config.MigrationsTable + `"`
-	dquery := `DELETE FROM "` + c.config.MigrationsTable + `" WHERE version = ?`
+	dquery := `DELETE FROM "` + c.config.MigrationsTable + `" WHERE version = ? IF EXISTS`
 	iter := c.session.Query(squery).Iter()
 	var previous int
 	for iter.Scan(&previous) {
-		if err := c.session.Query(dquery, previous).Exec(); err != nil {
+		if _, err := c.session.Query(dquery, previous).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
 			return &database.Error{OrigErr: err, Query: []byte(dquery)}
 		}
 	}
@@ -263,8 +278,8 @@ func (c *Cassandra) SetVersion(version int, dirty bool) error {
 	// empty schema version for failed down migration on the first migration
 	// See: https://github.com/golang-migrate/migrate/issues/330
 	if version >= 0 || (version == database.NilVersion && dirty) {
-		query := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty) VALUES (?, ?)`
-		if err := c.session.Query(query, version, dirty).Exec(); err != nil {
+		query := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty, applied_at) VALUES (?, ?, toTimestamp(now())) IF NOT EXISTS`
+		if _, err := c.session.Query(query, version, dirty).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
 			return &database.Error{OrigErr: err, Query: []byte(query)}
 		}
 	}
@@ -272,23 +287,34 @@ func (c *Cassandra) SetVersion(version int, dirty bool) error {
 	return nil
 }
 
-// Return current keyspace version
+// Return current keyspace version: the dirty version if there is one, the highest version otherwise.
+// Rows are returned in token order, not by version, so all of them are read.
 func (c *Cassandra) Version() (version int, dirty bool, err error) {
-	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `" LIMIT 1`
-	err = c.session.Query(query).Scan(&version, &dirty)
-	switch {
-	case err == gocql.ErrNotFound:
-		return database.NilVersion, false, nil
+	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `"`
+	iter := c.session.Query(query).Iter()
 
-	case err != nil:
+	version = database.NilVersion
+	found := false
+	var v int
+	var d bool
+	for iter.Scan(&v, &d) {
+		switch {
+		case d && !dirty:
+			version, dirty = v, true
+		case !dirty && (!found || v > version):
+			version = v
+		}
+		found = true
+	}
+
+	if err := iter.Close(); err != nil {
 		if _, ok := err.(*gocql.Error); ok {
 			return database.NilVersion, false, nil
 		}
 		return 0, false, &database.Error{OrigErr: err, Query: []byte(query)}
-
-	default:
-		return version, dirty, nil
 	}
+
+	return version, dirty, nil
 }
 
 func (c *Cassandra) Drop() error {
@@ -324,7 +350,15 @@ func (c *Cassandra) ensureVersionTable() (err error) {
 		}
 	}()
 
-	err = c.session.Query(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version bigint, dirty boolean, PRIMARY KEY(version))", c.config.MigrationsTable)).Exec()
+	err = c.session.Query(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (version bigint, dirty boolean, applied_at timestamp, PRIMARY KEY(version))`, c.config.MigrationsTable)).Exec()
+	if err != nil {
+		return err
+	}
+	// Earlier releases created the migrations table without applied_at
+	if err = c.ensureAppliedAtColumn(); err != nil {
+		return err
+	}
+	err = c.session.Query(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (lock_key text, PRIMARY KEY(lock_key))`, c.lockTable())).Exec()
 	if err != nil {
 		return err
 	}
@@ -334,6 +368,25 @@ func (c *Cassandra) ensureVersionTable() (err error) {
 	return nil
 }
 
+// ensureAppliedAtColumn adds the applied_at column to a migrations table created by an earlier release.
+func (c *Cassandra) ensureAppliedAtColumn() error {
+	query := `SELECT column_name FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ? AND column_name = 'applied_at'`
+	var column string
+	err := c.session.Query(query, c.config.KeyspaceName, c.config.MigrationsTable).Scan(&column)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gocql.ErrNotFound) {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	query = `ALTER TABLE "` + c.config.MigrationsTable + `" ADD applied_at timestamp`
+	if err := c.session.Query(query).Exec(); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return nil
+}
+
 // ParseConsistency wraps gocql.ParseConsistency
 // to return an error instead of a panicking.
 func parseConsistency(consistencyStr string) (consistency gocql.Consistency, err error) {
@@ -0,0 +1,247 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/gocql/gocql"
+)
+
+func init() {
+	db := CassandraExtras{
+		Cassandra: &Cassandra{},
+	}
+
+	database.Register("cassandra", &db)
+}
+
+// lockKey is the single row of the lock table that concurrent migrators compete for.
+const lockKey = "migrate"
+
+var (
+	ErrMigrationExists = errors.New("migration is already recorded")
+)
+
+// CassandraExtras implements database.ExtendedDriver on top of Cassandra.
+// Every write to the history table is a lightweight transaction (IF NOT EXISTS / IF),
+// so several migrators running against the same keyspace never overwrite each other's rows.
+type CassandraExtras struct {
+	*Cassandra
+}
+
+// Lock acquires a cluster wide lock by inserting the lock row with a lightweight transaction.
+// If another migrator already holds the row, database.ErrLocked is returned.
+// When Config.LockTTL is set, the row expires on its own, so a crashed migrator can't keep the keyspace locked forever.
+// The TTL is rounded up to whole seconds.
+func (c *CassandraExtras) Lock() error {
+	return database.CasRestoreOnErr(&c.isLocked, false, true, database.ErrLocked, func() error {
+		query := `INSERT INTO "` + c.lockTable() + `" (lock_key) VALUES (?) IF NOT EXISTS`
+		if c.config.LockTTL > 0 {
+			query += fmt.Sprintf(" USING TTL %d", int(math.Ceil(c.config.LockTTL.Seconds())))
+		}
+
+		applied, err := c.session.Query(query, lockKey).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return &database.Error{OrigErr: err, Err: "try lock failed", Query: []byte(query)}
+		}
+
+		if !applied {
+			return database.ErrLocked
+		}
+
+		return nil
+	})
+}
+
+// Unlock releases the lock row acquired by Lock.
+func (c *CassandraExtras) Unlock() error {
+	return database.CasRestoreOnErr(&c.isLocked, true, false, database.ErrNotLocked, func() error {
+		query := `DELETE FROM "` + c.lockTable() + `" WHERE lock_key = ? IF EXISTS`
+		if _, err := c.session.Query(query, lockKey).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		return nil
+	})
+}
+
+// GetAllAppliedMigrations retrieves all versions recorded in the migrations table.
+// Cassandra returns partitions in token order, so the versions are sorted in descending order before they are returned.
+func (c *CassandraExtras) GetAllAppliedMigrations() ([]int, error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `"`
+	iter := c.session.Query(query).Iter()
+
+	var appliedMigrations []int
+	var version int
+	for iter.Scan(&version) {
+		appliedMigrations = append(appliedMigrations, version)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(appliedMigrations)))
+
+	return appliedMigrations, nil
+}
+
+// AddDirtyMigration inserts a dirty row for the given version using IF NOT EXISTS.
+// If the row was already written, e.g. by a concurrent migrator, ErrMigrationExists is returned instead of silently overwriting it.
+func (c *CassandraExtras) AddDirtyMigration(version uint) error {
+	query := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty, applied_at) VALUES (?, true, toTimestamp(now())) IF NOT EXISTS`
+
+	applied, err := c.session.Query(query, int64(version)).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	if !applied {
+		return &database.Error{
+			OrigErr: ErrMigrationExists,
+			Err:     fmt.Sprintf("version %d", version),
+			Query:   []byte(query),
+		}
+	}
+
+	return nil
+}
+
+// UpdateMigrationDirtyFlag sets or clears the dirty flag of an existing row using a conditional update.
+// The update is skipped when no row exists for the version, so a concurrent RemoveMigration can't be undone by it.
+func (c *CassandraExtras) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	query := `UPDATE "` + c.config.MigrationsTable + `" SET dirty = ?, applied_at = toTimestamp(now()) WHERE version = ? IF EXISTS`
+
+	if _, err := c.session.Query(query, dirty, int64(version)).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return nil
+}
+
+// IsMigrationApplied checks if a row for the given version exists in the migrations table.
+func (c *CassandraExtras) IsMigrationApplied(version uint) (bool, error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `" WHERE version = ?`
+
+	var v int64
+	err := c.session.Query(query, int64(version)).Scan(&v)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return true, nil
+}
+
+// RemoveMigration deletes the row of the given version using IF EXISTS.
+// Removing a version that isn't recorded is not an error.
+func (c *CassandraExtras) RemoveMigration(version uint) error {
+	query := `DELETE FROM "` + c.config.MigrationsTable + `" WHERE version = ? IF EXISTS`
+
+	if _, err := c.session.Query(query, int64(version)).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return nil
+}
+
+// IsDatabaseDirty returns the first version found with the dirty flag set.
+// The migrations table is small, so filtering on the non-key dirty column is acceptable here.
+func (c *CassandraExtras) IsDatabaseDirty() (int, bool, error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `" WHERE dirty = true LIMIT 1 ALLOW FILTERING`
+
+	var version int
+	err := c.session.Query(query).Scan(&version)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return 0, false, nil
+		}
+
+		return 0, false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return version, true, nil
+}
+
+// LegacyVersion returns the version of the row written by an earlier release, which only kept the current version.
+// Such a row has no applied_at, as every row written since has one.
+func (c *CassandraExtras) LegacyVersion() (int, bool, error) {
+	query := `SELECT version, applied_at FROM "` + c.config.MigrationsTable + `"`
+	iter := c.session.Query(query).Iter()
+
+	legacy, isLegacy := database.NilVersion, false
+	var version int
+	var appliedAt time.Time
+	for iter.Scan(&version, &appliedAt) {
+		if appliedAt.IsZero() && (!isLegacy || version > legacy) {
+			legacy, isLegacy = version, true
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return database.NilVersion, false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return legacy, isLegacy, nil
+}
+
+// BackfillHistory inserts a clean row for every version using IF NOT EXISTS and then
+// sets applied_at on the rows of the earlier release, keeping their dirty flag.
+func (c *CassandraExtras) BackfillHistory(versions []uint) error {
+	query := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty, applied_at) VALUES (?, false, toTimestamp(now())) IF NOT EXISTS`
+	for _, version := range versions {
+		if _, err := c.session.Query(query, int64(version)).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
+			return &database.Error{
+				OrigErr: err,
+				Query:   []byte(query),
+			}
+		}
+	}
+
+	for {
+		legacy, isLegacy, err := c.LegacyVersion()
+		if err != nil || !isLegacy {
+			return err
+		}
+
+		query = `UPDATE "` + c.config.MigrationsTable + `" SET applied_at = toTimestamp(now()) WHERE version = ? IF EXISTS`
+		if _, err := c.session.Query(query, int64(legacy)).SerialConsistency(gocql.Serial).MapScanCAS(map[string]interface{}{}); err != nil {
+			return &database.Error{
+				OrigErr: err,
+				Query:   []byte(query),
+			}
+		}
+	}
+}
+
+func (c *Cassandra) lockTable() string {
+	return c.config.MigrationsTable + "_lock"
+}
@@ -2,10 +2,12 @@ package cassandra
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/abramad-labs/histomigrate"
 	"strconv"
 	"testing"
+	"time"
 )
 
 import (
@@ -14,6 +16,7 @@ import (
 )
 
 import (
+	"github.com/abramad-labs/histomigrate/database"
 	dt "github.com/abramad-labs/histomigrate/database/testing"
 	"github.com/abramad-labs/histomigrate/dktesting"
 	_ "github.com/abramad-labs/histomigrate/source/file"
@@ -60,9 +63,22 @@ func isReady(ctx context.Context, c dktest.ContainerInfo) bool {
 	return true
 }
 
+func TestShortLockTTL(t *testing.T) {
+	c := &Cassandra{}
+	if _, err := c.Open("cassandra://localhost:9042/testks?x-lock-ttl=500ms"); !errors.Is(err, ErrShortLockTTL) {
+		t.Errorf("expected ErrShortLockTTL, got %v", err)
+	}
+	if _, err := WithInstance(nil, &Config{KeyspaceName: "testks", LockTTL: 500 * time.Millisecond}); !errors.Is(err, ErrShortLockTTL) {
+		t.Errorf("expected ErrShortLockTTL, got %v", err)
+	}
+}
+
 func Test(t *testing.T) {
 	t.Run("test", test)
 	t.Run("testMigrate", testMigrate)
+	t.Run("testExtended", testExtended)
+	t.Run("testLegacyHistory", testLegacyHistory)
+	t.Run("testMixedCaseTable", testMixedCaseTable)
 
 	t.Cleanup(func() {
 		for _, spec := range specs {
@@ -120,3 +136,118 @@ func testMigrate(t *testing.T) {
 		dt.TestMigrate(t, m)
 	})
 }
+
+func testExtended(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.Port(9042)
+		if err != nil {
+			t.Fatal("Unable to get mapped port:", err)
+		}
+		addr := fmt.Sprintf("cassandra://%v:%v/testks?x-lock-ttl=1m", ip, port)
+		p := &Cassandra{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ed, ok := d.(database.ExtendedDriver)
+		if !ok {
+			t.Fatal("expected driver to implement database.ExtendedDriver")
+		}
+		dt.TestLockAndUnlock(t, ed)
+		dt.TestExtended(t, ed)
+	})
+}
+
+func testLegacyHistory(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.Port(9042)
+		if err != nil {
+			t.Fatal("Unable to get mapped port:", err)
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the migrations table of an earlier release at version 3
+		cluster := gocql.NewCluster(ip)
+		cluster.Port = portNum
+		cluster.Keyspace = "testks"
+		cluster.Consistency = gocql.All
+		session, err := cluster.CreateSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range []string{
+			"CREATE TABLE legacy_migrations (version bigint, dirty boolean, PRIMARY KEY(version))",
+			"INSERT INTO legacy_migrations (version, dirty) VALUES (3, false)",
+		} {
+			if err := session.Query(query).Exec(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		session.Close()
+
+		addr := fmt.Sprintf("cassandra://%v:%v/testks?x-migrations-table=legacy_migrations", ip, port)
+		p := &Cassandra{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ld, ok := d.(database.LegacyHistoryDriver)
+		if !ok {
+			t.Fatal("expected driver to implement database.LegacyHistoryDriver")
+		}
+		dt.TestLegacyHistory(t, ld, false)
+
+		// applied_at has been added, so history rows can be written
+		if err := ld.AddDirtyMigration(4); err != nil {
+			t.Fatal(err)
+		}
+		if err := ld.UpdateMigrationDirtyFlag(4, false); err != nil {
+			t.Fatal(err)
+		}
+		if version, dirty, err := ld.Version(); err != nil || version != 4 || dirty {
+			t.Fatalf("expected version 4, got %v (dirty: %v, err: %v)", version, dirty, err)
+		}
+	})
+}
+
+func testMixedCaseTable(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.Port(9042)
+		if err != nil {
+			t.Fatal("Unable to get mapped port:", err)
+		}
+		addr := fmt.Sprintf("cassandra://%v:%v/testks?x-migrations-table=SchemaMigrations", ip, port)
+		p := &Cassandra{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ed, ok := d.(database.ExtendedDriver)
+		if !ok {
+			t.Fatal("expected driver to implement database.ExtendedDriver")
+		}
+		dt.TestLockAndUnlock(t, ed)
+		dt.TestExtended(t, ed)
+	})
+}
@@ -3,30 +3,32 @@ package cockroachdb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	nurl "net/url"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/abramad-labs/histomigrate"
 	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/database/multistmt"
+	"github.com/abramad-labs/histomigrate/source"
 	"github.com/cockroachdb/cockroach-go/v2/crdb"
 	"github.com/hashicorp/go-multierror"
 	"github.com/lib/pq"
 	"go.uber.org/atomic"
 )
 
-func init() {
-	db := CockroachDb{}
-	database.Register("cockroach", &db)
-	database.Register("cockroachdb", &db)
-	database.Register("crdb-postgres", &db)
-}
-
 var DefaultMigrationsTable = "schema_migrations"
 var DefaultLockTable = "schema_lock"
 
+var (
+	multiStmtDelimiter = []byte(";")
+	multiStmtMaxSize   = 10 * 1 << 20 // 10 MB
+)
+
 var (
 	ErrNilConfig      = fmt.Errorf("no config")
 	ErrNoDatabaseName = fmt.Errorf("no database name")
@@ -92,7 +94,9 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 		return nil, err
 	}
 
-	return px, nil
+	return &CockroachDbExtras{
+		CockroachDb: px,
+	}, nil
 }
 
 func (c *CockroachDb) Open(url string) (database.Driver, error) {
@@ -211,17 +215,39 @@ func (c *CockroachDb) Unlock() error {
 }
 
 func (c *CockroachDb) Run(migration io.Reader) error {
+	return c.RunWithDirectives(context.Background(), migration, source.Directives{})
+}
+
+// RunWithDirectives runs the migration like Run, stopping it once ctx is done. With the no-transaction directive,
+// the statements of the migration run one by one, split at semicolons, since CockroachDB runs several statements
+// sent at once in an implicit transaction.
+func (c *CockroachDb) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
+	if directives.NoTransaction {
+		var err error
+		if e := multistmt.Parse(migration, multiStmtDelimiter, multiStmtMaxSize, func(m []byte) bool {
+			err = c.runStatement(ctx, m)
+			return err == nil
+		}); e != nil {
+			return e
+		}
+		return err
+	}
+
 	migr, err := io.ReadAll(migration)
 	if err != nil {
 		return err
 	}
+	return c.runStatement(ctx, migr)
+}
 
-	// run migration
-	query := string(migr[:])
-	if _, err := c.db.Exec(query); err != nil {
-		return database.Error{OrigErr: err, Err: "migration failed", Query: migr}
+func (c *CockroachDb) runStatement(ctx context.Context, statement []byte) error {
+	query := string(statement)
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	if _, err := c.db.ExecContext(ctx, query); err != nil {
+		return database.Error{OrigErr: err, Err: "migration failed", Query: statement}
 	}
-
 	return nil
 }
 
@@ -235,7 +261,7 @@ func (c *CockroachDb) SetVersion(version int, dirty bool) error {
 		// empty schema version for failed down migration on the first migration
 		// See: https://github.com/golang-migrate/migrate/issues/330
 		if version >= 0 || (version == database.NilVersion && dirty) {
-			if _, err := tx.Exec(`INSERT INTO "`+c.config.MigrationsTable+`" (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			if _, err := tx.Exec(`INSERT INTO "`+c.config.MigrationsTable+`" (version, dirty, applied_at) VALUES ($1, $2, now())`, version, dirty); err != nil {
 				return err
 			}
 		}
@@ -245,7 +271,7 @@ func (c *CockroachDb) SetVersion(version int, dirty bool) error {
 }
 
 func (c *CockroachDb) Version() (version int, dirty bool, err error) {
-	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `" LIMIT 1`
+	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `" ORDER BY dirty DESC, version DESC LIMIT 1`
 	err = c.db.QueryRow(query).Scan(&version, &dirty)
 
 	switch {
@@ -333,17 +359,43 @@ func (c *CockroachDb) ensureVersionTable() (err error) {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
 	}
 	if count == 1 {
-		return nil
+		return c.ensureAppliedAt()
 	}
 
 	// if not, create the empty migration table
-	query = `CREATE TABLE "` + c.config.MigrationsTable + `" (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL)`
+	query = `CREATE TABLE "` + c.config.MigrationsTable + `" (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL, applied_at TIMESTAMPTZ DEFAULT now())`
 	if _, err := c.db.Exec(query); err != nil {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
 	}
 	return nil
 }
 
+// ensureAppliedAt adds the applied_at column to tables created by earlier releases. It is added without
+// a default first, so that their rows keep a NULL applied_at and are found by LegacyVersion.
+// Every ALTER is a schema change, so the column is only altered if it or its default is missing.
+func (c *CockroachDb) ensureAppliedAt() error {
+	var columnDefault sql.NullString
+	query := `SELECT column_default FROM information_schema.columns WHERE table_name = $1 AND table_schema = (SELECT current_schema()) AND column_name = 'applied_at'`
+	err := c.db.QueryRow(query, c.config.MigrationsTable).Scan(&columnDefault)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	var queries []string
+	if errors.Is(err, sql.ErrNoRows) {
+		queries = append(queries, `ALTER TABLE "`+c.config.MigrationsTable+`" ADD COLUMN IF NOT EXISTS applied_at TIMESTAMPTZ`)
+	}
+	if !columnDefault.Valid {
+		queries = append(queries, `ALTER TABLE "`+c.config.MigrationsTable+`" ALTER COLUMN applied_at SET DEFAULT now()`)
+	}
+	for _, query := range queries {
+		if _, err := c.db.Exec(query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+	return nil
+}
+
 func (c *CockroachDb) ensureLockTable() error {
 	// check if lock table exists
 	var count int
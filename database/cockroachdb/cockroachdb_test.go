@@ -5,6 +5,7 @@ package cockroachdb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/abramad-labs/histomigrate"
 	"log"
@@ -14,12 +15,14 @@ import (
 
 import (
 	"github.com/dhui/dktest"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 import (
+	"github.com/abramad-labs/histomigrate/database"
 	dt "github.com/abramad-labs/histomigrate/database/testing"
 	"github.com/abramad-labs/histomigrate/dktesting"
+	"github.com/abramad-labs/histomigrate/source"
 	_ "github.com/abramad-labs/histomigrate/source/file"
 )
 
@@ -146,7 +149,7 @@ func TestMultiStatement(t *testing.T) {
 
 		// make sure second table exists
 		var exists bool
-		if err := d.(*CockroachDb).db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT current_schema()))").Scan(&exists); err != nil {
+		if err := d.(*CockroachDbExtras).db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT current_schema()))").Scan(&exists); err != nil {
 			t.Fatal(err)
 		}
 		if !exists {
@@ -155,6 +158,37 @@ func TestMultiStatement(t *testing.T) {
 	})
 }
 
+func TestNoTransaction(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(26257)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := fmt.Sprintf("cockroach://root@%v:%v/migrate?sslmode=disable", ip, port)
+		c := &CockroachDb{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migration := "-- histomigrate: no-transaction\nCREATE TABLE foo (foo text); CREATE INDEX idx_foo ON foo (foo);"
+		err = d.(database.DirectiveDriver).RunWithDirectives(context.Background(), strings.NewReader(migration), source.Directives{NoTransaction: true})
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %v", err)
+		}
+
+		var exists bool
+		if err := d.(*CockroachDbExtras).db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE tablename = 'foo' AND indexname = 'idx_foo')").Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatalf("expected index idx_foo to exist")
+		}
+	})
+}
+
 func TestFilterCustomQuery(t *testing.T) {
 	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
 		createDB(t, ci)
@@ -172,3 +206,117 @@ func TestFilterCustomQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestExtended(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(26257)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := fmt.Sprintf("cockroach://root@%v:%v/migrate?sslmode=disable", ip, port)
+		c := &CockroachDb{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt.TestExtended(t, d.(*CockroachDbExtras))
+	})
+}
+
+func TestAddDirtyMigrationRetry(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(26257)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := fmt.Sprintf("cockroach://root@%v:%v/migrate?sslmode=disable", ip, port)
+		c := &CockroachDb{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cd := d.(*CockroachDbExtras)
+
+		query := `INSERT INTO "` + cd.config.MigrationsTable + `" (version, dirty, applied_at) VALUES ($1, true, now()) ON CONFLICT (version) DO NOTHING`
+		attempts := 0
+		err = cd.executeTx(func(tx *sql.Tx, ambiguous bool) error {
+			attempts++
+			if attempts == 1 {
+				// a concurrent writer records the version, and this attempt fails to serialize
+				if _, err := cd.db.Exec(query, 5); err != nil {
+					return err
+				}
+				return &pq.Error{Code: "40001"}
+			}
+			return insertDirty(tx, query, 5, ambiguous)
+		})
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", attempts)
+		}
+		if !errors.Is(err, ErrMigrationExists) {
+			t.Fatalf("expected ErrMigrationExists after the retry, got %v", err)
+		}
+	})
+}
+
+func TestLegacyHistory(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(26257)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the migrations table of an earlier release at version 3
+		db, err := sql.Open("postgres", fmt.Sprintf("postgres://root@%v:%v/migrate?sslmode=disable", ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range []string{
+			`CREATE TABLE legacy_migrations (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL)`,
+			`INSERT INTO legacy_migrations (version, dirty) VALUES (3, false)`,
+		} {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		addr := fmt.Sprintf("cockroach://root@%v:%v/migrate?sslmode=disable&x-migrations-table=legacy_migrations", ip, port)
+		c := &CockroachDb{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt.TestLegacyHistory(t, d.(*CockroachDbExtras), false)
+
+		// opening an up to date table doesn't start schema changes
+		schemaChanges := func() (count int) {
+			query := `SELECT COUNT(1) FROM [SHOW JOBS] WHERE job_type = 'SCHEMA CHANGE'`
+			if err := d.(*CockroachDbExtras).db.QueryRow(query).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			return count
+		}
+		before := schemaChanges()
+		d2, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after := schemaChanges(); after != before {
+			t.Errorf("expected no schema change, got %d", after-before)
+		}
+		if err := d2.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
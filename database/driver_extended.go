@@ -1,5 +1,13 @@
 package database
 
+import (
+	"context"
+	"database/sql"
+	"io"
+
+	"github.com/abramad-labs/histomigrate/source"
+)
+
 type ExtendedDriver interface {
 	// Embeds core database interaction capabilities.
 	Driver
@@ -22,3 +30,80 @@ type ExtendedDriver interface {
 	// RemoveMigration deletes a migration record from the applied list.
 	RemoveMigration(uint) error
 }
+
+// TransactionalDriver is an optional interface for ExtendedDriver implementations
+// that can apply a migration body and its history write as one unit of work.
+type TransactionalDriver interface {
+	ExtendedDriver
+
+	// RunWithHistory runs the migration and records the version as applied (up is true)
+	// or removes it from the applied list (up is false). If the body fails, the history must be left
+	// either untouched or marked dirty, never recorded as cleanly applied.
+	RunWithHistory(version uint, up bool, migration io.Reader) error
+}
+
+// RepeatableDriver is an optional interface for ExtendedDriver implementations that keep
+// track of repeatable migrations. Repeatable migrations have no version; they are identified
+// by name and run again whenever the checksum of their content changes.
+type RepeatableDriver interface {
+	ExtendedDriver
+
+	// GetAllAppliedRepeatables returns the checksum of the last run of every applied repeatable migration, by name.
+	GetAllAppliedRepeatables() (map[string]string, error)
+
+	// SetRepeatableApplied records that the named repeatable migration ran with the given checksum.
+	SetRepeatableApplied(name string, checksum string) error
+}
+
+// FuncDriver is an optional interface for drivers that can run migrations implemented in Go.
+type FuncDriver interface {
+	Driver
+
+	// RunFunc calls fn with a transaction, committing it if fn succeeds and rolling it back otherwise.
+	RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error
+}
+
+// ContextDriver is an optional interface for drivers that can cancel a running migration.
+// Migrate uses it for migrations with a timeout directive.
+type ContextDriver interface {
+	Driver
+
+	// RunContext runs the migration like Run, stopping it once ctx is done.
+	RunContext(ctx context.Context, migration io.Reader) error
+}
+
+// TransactionalContextDriver is an optional interface for TransactionalDriver implementations
+// that can cancel a running migration. Migrate uses it for migrations with a timeout directive.
+type TransactionalContextDriver interface {
+	TransactionalDriver
+
+	// RunWithHistoryContext runs the migration like RunWithHistory, stopping it once ctx is done.
+	RunWithHistoryContext(ctx context.Context, version uint, up bool, migration io.Reader) error
+}
+
+// DirectiveDriver is an optional interface for drivers that honour the directives changing how
+// a migration body runs, see source.Directives. Migrate runs migrations with a no-transaction directive
+// through it, and fails them with drivers that don't implement it.
+type DirectiveDriver interface {
+	Driver
+
+	// RunWithDirectives runs the migration like Run, stopping it once ctx is done.
+	// With NoTransaction set, neither the driver nor the database may wrap the statements
+	// of the migration in a transaction, each statement must run on its own.
+	RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error
+}
+
+// LegacyHistoryDriver is an optional interface for ExtendedDriver implementations whose migrations table
+// may still hold the single version row written by an earlier release, which only kept the current version.
+// Before reading or writing the history, Migrate backfills it with every version of the source below
+// the legacy one, so that migrations applied before the upgrade aren't taken for pending ones.
+type LegacyHistoryDriver interface {
+	ExtendedDriver
+
+	// LegacyVersion returns the version of the legacy row and true while the history hasn't been backfilled yet.
+	LegacyVersion() (version int, ok bool, err error)
+
+	// BackfillHistory records the versions as cleanly applied and converts the legacy row into
+	// a history row, keeping its dirty flag, so that LegacyVersion no longer reports it.
+	BackfillHistory(versions []uint) error
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/abramad-labs/histomigrate"
 	"github.com/abramad-labs/histomigrate/database"
 	"github.com/abramad-labs/histomigrate/database/multistmt"
+	"github.com/abramad-labs/histomigrate/source"
 	"github.com/hashicorp/go-multierror"
 	"github.com/lib/pq"
 )
@@ -52,6 +53,8 @@ type Postgres struct {
 	conn     *sql.Conn
 	db       *sql.DB
 	isLocked atomic.Bool
+	// sharedConn is set by WithSchema, closing leaves the connection open
+	sharedConn bool
 
 	// Open and WithInstance need to guarantee that config is never nil
 	config *Config
@@ -83,11 +86,38 @@ func (p *Postgres) Open(url string) (database.Driver, error) {
 		return nil, err
 	}
 
+	if purl.Query().Has("x-schemas") || purl.Query().Has("x-schema-pattern") {
+		return nil, ErrManySchemas
+	}
+
+	config, err := ConfigFromURL(purl)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := sql.Open("postgres", migrate.FilterCustomQuery(purl).String())
 	if err != nil {
 		return nil, err
 	}
 
+	if schema := purl.Query().Get("x-schema"); schema != "" {
+		return withSchemaInstance(db, schema, config)
+	}
+
+	px, err := WithInstance(db, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return px, nil
+}
+
+// ConfigFromURL returns the driver config given by the query parameters of a postgres URL, as used by Open.
+// x-schema isn't part of the config, see WithSchema.
+func ConfigFromURL(purl *nurl.URL) (*Config, error) {
+	var err error
+
 	migrationsTable := purl.Query().Get("x-migrations-table")
 	migrationsTableQuoted := false
 	if s := purl.Query().Get("x-migrations-table-quoted"); len(s) > 0 {
@@ -128,23 +158,20 @@ func (p *Postgres) Open(url string) (database.Driver, error) {
 		}
 	}
 
-	px, err := WithInstance(db, &Config{
+	return &Config{
 		DatabaseName:          purl.Path,
 		MigrationsTable:       migrationsTable,
 		MigrationsTableQuoted: migrationsTableQuoted,
 		StatementTimeout:      time.Duration(statementTimeout) * time.Millisecond,
 		MultiStatementEnabled: multiStatementEnabled,
 		MultiStatementMaxSize: multiStatementMaxSize,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return px, nil
+	}, nil
 }
 
 func (p *Postgres) Close() error {
+	if p.sharedConn {
+		return resetSearchPath(context.Background(), p.conn)
+	}
 	connErr := p.conn.Close()
 	var dbErr error
 	if p.db != nil {
@@ -191,27 +218,50 @@ func (p *Postgres) Unlock() error {
 }
 
 func (p *Postgres) Run(migration io.Reader) error {
+	return p.RunContext(context.Background(), migration)
+}
+
+// RunContext runs the migration like Run, cancelling the running statement once ctx is done.
+func (p *Postgres) RunContext(ctx context.Context, migration io.Reader) error {
 	if p.config.MultiStatementEnabled {
-		var err error
-		if e := multistmt.Parse(migration, multiStmtDelimiter, p.config.MultiStatementMaxSize, func(m []byte) bool {
-			if err = p.runStatement(m); err != nil {
-				return false
-			}
-			return true
-		}); e != nil {
-			return e
-		}
-		return err
+		return p.runStatements(ctx, migration)
 	}
 	migr, err := io.ReadAll(migration)
 	if err != nil {
 		return err
 	}
-	return p.runStatement(migr)
+	return p.runStatement(ctx, migr)
 }
 
-func (p *Postgres) runStatement(statement []byte) error {
-	ctx := context.Background()
+// RunWithDirectives runs the migration like RunContext. With the no-transaction directive, the statements
+// of the migration run one by one like with x-multi-statement, since PostgreSQL runs several statements
+// sent at once in an implicit transaction.
+func (p *Postgres) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
+	if directives.NoTransaction {
+		return p.runStatements(ctx, migration)
+	}
+	return p.RunContext(ctx, migration)
+}
+
+// runStatements runs the statements of migration one by one, split at semicolons.
+func (p *Postgres) runStatements(ctx context.Context, migration io.Reader) error {
+	maxSize := p.config.MultiStatementMaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMultiStatementMaxSize
+	}
+	var err error
+	if e := multistmt.Parse(migration, multiStmtDelimiter, maxSize, func(m []byte) bool {
+		if err = p.runStatement(ctx, m); err != nil {
+			return false
+		}
+		return true
+	}); e != nil {
+		return e
+	}
+	return err
+}
+
+func (p *Postgres) runStatement(ctx context.Context, statement []byte) error {
 	if p.config.StatementTimeout != 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, p.config.StatementTimeout)
@@ -21,6 +21,8 @@ func init() {
 	database.Register("postgresql", &db)
 }
 
+var quotedIdentifier = regexp.MustCompile(`"(.*?)"`)
+
 type PostgresExtras struct {
 	*Postgres
 }
@@ -73,8 +75,7 @@ func WithConnection(ctx context.Context, conn *sql.Conn, config *Config) (*Postg
 	config.migrationsSchemaName = config.SchemaName
 	config.migrationsTableName = config.MigrationsTable
 	if config.MigrationsTableQuoted {
-		re := regexp.MustCompile(`"(.*?)"`)
-		result := re.FindAllStringSubmatch(config.MigrationsTable, -1)
+		result := quotedIdentifier.FindAllStringSubmatch(config.MigrationsTable, -1)
 		config.migrationsTableName = result[len(result)-1][1]
 		if len(result) == 2 {
 			config.migrationsSchemaName = result[0][1]
@@ -333,3 +334,113 @@ func (p *Postgres) IsDatabaseDirty() (int, bool, error) {
 
 	return migr, true, nil
 }
+
+// GetAllAppliedRepeatables returns the checksum of every applied repeatable migration, by name.
+// Repeatable migrations are recorded in a table next to the migrations table, named after it with a "_repeatable" suffix.
+// A missing table is reported as no applied repeatable migrations.
+func (p *PostgresExtras) GetAllAppliedRepeatables() (map[string]string, error) {
+	query := fmt.Sprintf(`SELECT name, checksum FROM %s`, p.repeatableTable())
+
+	rows, err := p.conn.QueryContext(context.Background(), query)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "undefined_table" {
+			return map[string]string{}, nil
+		}
+
+		return nil, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	applied := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, &database.Error{
+				OrigErr: err,
+				Query:   []byte(query),
+			}
+		}
+		applied[name] = checksum
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return applied, nil
+}
+
+// SetRepeatableApplied records the checksum and the time of the last run of a repeatable migration.
+// The repeatable migrations table is created on first use.
+func (p *PostgresExtras) SetRepeatableApplied(name string, checksum string) error {
+	query := fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY, checksum TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW())`,
+		p.repeatableTable(),
+	)
+	if _, err := p.conn.ExecContext(context.Background(), query); err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	query = fmt.Sprintf(
+		`INSERT INTO %s (name, checksum, applied_at) VALUES ($1, $2, NOW()) ON CONFLICT (name) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = EXCLUDED.applied_at`,
+		p.repeatableTable(),
+	)
+	if _, err := p.conn.ExecContext(context.Background(), query, name, checksum); err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return nil
+}
+
+// repeatableTable returns the quoted name of the repeatable migrations table.
+func (p *PostgresExtras) repeatableTable() string {
+	return pq.QuoteIdentifier(p.config.migrationsSchemaName) + `.` + pq.QuoteIdentifier(p.config.migrationsTableName+"_repeatable")
+}
+
+// RunFunc runs a migration implemented in Go in a transaction on the migration connection.
+func (p *PostgresExtras) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	tx, err := p.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Err:     "transaction start failed",
+		}
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = multierror.Append(err, rbErr)
+		}
+
+		return &database.Error{
+			OrigErr: err,
+			Err:     "migration failed",
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &database.Error{
+			OrigErr: err,
+			Err:     "transaction commit failed",
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/hashicorp/go-multierror"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSchemaMigrationTable = fmt.Errorf("migrations table must not name a schema when migrating schemas")
+	ErrManySchemas          = fmt.Errorf("x-schemas and x-schema-pattern select many schemas, migrate them with schemas.FanOutURL")
+)
+
+// ListSchemas returns the names of the schemas matching the LIKE pattern, e.g. tenant_%, in order.
+func ListSchemas(ctx context.Context, conn *sql.Conn, pattern string) (schemas []string, err error) {
+	query := `SELECT nspname FROM pg_namespace WHERE nspname LIKE $1 ORDER BY nspname`
+	rows, err := conn.QueryContext(ctx, query, pattern)
+	if err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+		schemas = append(schemas, schema)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return schemas, nil
+}
+
+// WithSchema initializes a new PostgresExtras instance migrating a single schema over an existing sql.Conn.
+// It sets the search_path of the connection to the schema, so that migrations create their objects in it,
+// and keeps the migrations table in the schema as well. As the advisory lock key includes the schema of the
+// migrations table, every schema is locked on its own.
+// Closing the instance resets the search_path and leaves the connection open, so that it can migrate the next schema.
+func WithSchema(ctx context.Context, conn *sql.Conn, schema string, config *Config) (*PostgresExtras, error) {
+	if config == nil {
+		return nil, ErrNilConfig
+	}
+	if config.MigrationsTableQuoted && len(quotedIdentifier.FindAllString(config.MigrationsTable, -1)) > 1 {
+		return nil, ErrSchemaMigrationTable
+	}
+
+	query := `SELECT COUNT(1) FROM pg_namespace WHERE nspname = $1`
+	var count int
+	if err := conn.QueryRowContext(ctx, query, schema).Scan(&count); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoSchema, schema)
+	}
+
+	query = `SET search_path TO ` + pq.QuoteIdentifier(schema)
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	config.SchemaName = schema
+	px, err := WithConnection(ctx, conn, config)
+	if err != nil {
+		if errReset := resetSearchPath(ctx, conn); errReset != nil {
+			err = multierror.Append(err, errReset)
+		}
+		return nil, err
+	}
+	px.sharedConn = true
+	return px, nil
+}
+
+// withSchemaInstance is WithSchema over a connection of its own of instance, closed along with the driver.
+func withSchemaInstance(instance *sql.DB, schema string, config *Config) (database.Driver, error) {
+	ctx := context.Background()
+
+	if err := instance.Ping(); err != nil {
+		return nil, err
+	}
+
+	conn, err := instance.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	px, err := WithSchema(ctx, conn, schema, config)
+	if err != nil {
+		if errClose := conn.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+		return nil, err
+	}
+	px.sharedConn = false
+	px.db = instance
+	return px, nil
+}
+
+func resetSearchPath(ctx context.Context, conn *sql.Conn) error {
+	query := `RESET search_path`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/abramad-labs/histomigrate/database"
 	dt "github.com/abramad-labs/histomigrate/database/testing"
 	"github.com/abramad-labs/histomigrate/dktesting"
+	"github.com/abramad-labs/histomigrate/source"
 	_ "github.com/abramad-labs/histomigrate/source/file"
 )
 
@@ -89,6 +90,7 @@ func Test(t *testing.T) {
 	t.Run("testMigrate", testMigrate)
 	t.Run("testMultipleStatements", testMultipleStatements)
 	t.Run("testMultipleStatementsInMultiStatementMode", testMultipleStatementsInMultiStatementMode)
+	t.Run("testNoTransaction", testNoTransaction)
 	t.Run("testErrorParsing", testErrorParsing)
 	t.Run("testFilterCustomQuery", testFilterCustomQuery)
 	t.Run("testWithSchema", testWithSchema)
@@ -99,6 +101,7 @@ func Test(t *testing.T) {
 	t.Run("testPostgresLock", testPostgresLock)
 	t.Run("testWithInstanceConcurrent", testWithInstanceConcurrent)
 	t.Run("testWithConnection", testWithConnection)
+	t.Run("testSchemas", testSchemas)
 
 	t.Cleanup(func() {
 		for _, spec := range specs {
@@ -224,6 +227,45 @@ func testMultipleStatementsInMultiStatementMode(t *testing.T) {
 	})
 }
 
+func testNoTransaction(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.FirstPort()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := pgConnectionString(ip, port)
+		p := &Postgres{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		// CREATE INDEX CONCURRENTLY fails in the implicit transaction of several statements
+		migration := "-- histomigrate: no-transaction\nCREATE TABLE foo (foo text); CREATE INDEX CONCURRENTLY idx_foo ON foo (foo);"
+		if err := d.Run(strings.NewReader(migration)); err == nil {
+			t.Fatal("expected CREATE INDEX CONCURRENTLY to fail within a transaction")
+		}
+		err = d.(database.DirectiveDriver).RunWithDirectives(context.Background(), strings.NewReader(migration), source.Directives{NoTransaction: true})
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %v", err)
+		}
+
+		var exists bool
+		if err := d.(*Postgres).conn.QueryRowContext(context.Background(), "SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = (SELECT current_schema()) AND indexname = 'idx_foo')").Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatalf("expected index idx_foo to exist")
+		}
+	})
+}
+
 func testErrorParsing(t *testing.T) {
 	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
 		ip, port, err := c.FirstPort()
@@ -748,6 +790,97 @@ func testWithConnection(t *testing.T) {
 	})
 }
 
+func testSchemas(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.FirstPort()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("postgres", pgConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		for _, schema := range []string{"tenant_acme", "tenant_globex", "other"} {
+			if _, err := conn.ExecContext(ctx, "CREATE SCHEMA "+schema+" AUTHORIZATION postgres"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		schemas, err := ListSchemas(ctx, conn, "tenant_%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(schemas) != 2 || schemas[0] != "tenant_acme" || schemas[1] != "tenant_globex" {
+			t.Fatalf("unexpected schemas %v", schemas)
+		}
+
+		// a shared connection is back to its search_path once closed
+		px, err := WithSchema(ctx, conn, "tenant_acme", &Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustRun(t, px, []string{"CREATE TABLE users (id INT)"})
+		if err := px.Close(); err != nil {
+			t.Fatal(err)
+		}
+		var schema string
+		if err := conn.QueryRowContext(ctx, `SELECT CURRENT_SCHEMA()`).Scan(&schema); err != nil {
+			t.Fatal(err)
+		}
+		if schema != "public" {
+			t.Errorf("expected the search_path to be reset, got %s", schema)
+		}
+
+		// x-schema selects the schema of a connection of its own
+		p := &Postgres{}
+		d, err := p.Open(pgConnectionString(ip, port, "x-schema=tenant_globex"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustRun(t, d, []string{"CREATE TABLE users (id INT)"})
+		if err := d.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// every schema has its own tables and migrations table
+		for _, schema := range []string{"tenant_acme", "tenant_globex"} {
+			var count int
+			query := `SELECT COUNT(1) FROM information_schema.tables WHERE table_schema = $1 AND table_name IN ('users', 'schema_migrations')`
+			if err := conn.QueryRowContext(ctx, query, schema).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("expected the tables of %s, got %d", schema, count)
+			}
+		}
+
+		if _, err := WithSchema(ctx, conn, "missing", &Config{}); !errors.Is(err, ErrNoSchema) {
+			t.Errorf("expected ErrNoSchema, got %v", err)
+		}
+		if _, err := p.Open(pgConnectionString(ip, port, "x-schema-pattern=tenant_%25")); !errors.Is(err, ErrManySchemas) {
+			t.Errorf("expected ErrManySchemas, got %v", err)
+		}
+	})
+}
+
 func Test_computeLineFromPos(t *testing.T) {
 	testcases := []struct {
 		pos      int
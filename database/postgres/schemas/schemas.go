@@ -0,0 +1,218 @@
+// Package schemas migrates the schemas of a PostgreSQL database as targets of their own,
+// e.g. one schema per tenant, see postgres.WithSchema and migrate.FanOut.
+package schemas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	nurl "net/url"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate"
+	"github.com/abramad-labs/histomigrate/database/postgres"
+	"github.com/hashicorp/go-multierror"
+)
+
+var (
+	ErrNoSchemas         = errors.New("no schemas")
+	ErrSchemasAndPattern = errors.New("x-schemas and x-schema-pattern are mutually exclusive")
+)
+
+// FanOut runs the migrations of sourceURL against every schema, one after the other over the same connection,
+// see postgres.WithSchema and migrate.FanOut. The targets of the results are named after the schemas.
+// config is the template of the driver config of every schema. The Parallel and New fields of fanOut are ignored,
+// fanOut may be nil.
+func FanOut(ctx context.Context, conn *sql.Conn, sourceURL string, schemas []string, config *postgres.Config, fanOut *migrate.FanOutConfig) ([]migrate.TargetResult, error) {
+	if config == nil {
+		return nil, postgres.ErrNilConfig
+	}
+	if len(schemas) == 0 {
+		return nil, ErrNoSchemas
+	}
+
+	fanOutConfig := migrate.FanOutConfig{}
+	if fanOut != nil {
+		fanOutConfig = *fanOut
+	}
+	// a connection runs one statement at a time
+	fanOutConfig.Parallel = 1
+	fanOutConfig.New = func(target migrate.Target) (*migrate.Migrate, error) {
+		schemaConfig := *config
+		px, err := postgres.WithSchema(ctx, conn, target.Name, &schemaConfig)
+		if err != nil {
+			return nil, err
+		}
+		m, err := migrate.NewWithDatabaseInstance(sourceURL, "postgres", px)
+		if err != nil {
+			if errClose := px.Close(); errClose != nil {
+				err = multierror.Append(err, errClose)
+			}
+			return nil, err
+		}
+		return m, nil
+	}
+
+	targets := make([]migrate.Target, 0, len(schemas))
+	for _, schema := range schemas {
+		targets = append(targets, migrate.Target{Name: schema})
+	}
+	return migrate.FanOut(sourceURL, targets, &fanOutConfig)
+}
+
+// FanOutURL runs the migrations of sourceURL against the schemas selected by a postgres database URL with
+// x-schemas or x-schema-pattern, see Targets, one after the other over a single connection, see FanOut.
+// The driver config of every schema is given by the other query parameters of the URL, see postgres.ConfigFromURL.
+func FanOutURL(sourceURL string, databaseURL string, fanOut *migrate.FanOutConfig) (results []migrate.TargetResult, err error) {
+	purl, err := nurl.Parse(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	list, pattern, err := selection(purl.Query())
+	if err != nil {
+		return nil, err
+	}
+	if list == nil && pattern == "" {
+		return nil, ErrNoSchemas
+	}
+	config, err := postgres.ConfigFromURL(purl)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", migrate.FilterCustomQuery(purl).String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := db.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := conn.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	schemas := list
+	if pattern != "" {
+		if schemas, err = postgres.ListSchemas(ctx, conn, pattern); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSchemas(schemas, pattern); err != nil {
+		return nil, err
+	}
+	return FanOut(ctx, conn, sourceURL, schemas, config, fanOut)
+}
+
+// Selects reports whether a postgres database URL selects many schemas with x-schemas or x-schema-pattern.
+func Selects(databaseURL string) bool {
+	purl, err := nurl.Parse(databaseURL)
+	if err != nil {
+		return false
+	}
+	query := purl.Query()
+	return query.Get("x-schemas") != "" || query.Get("x-schema-pattern") != ""
+}
+
+// Targets returns a target per schema selected by a postgres database URL, for migrate.FanOut:
+// the comma-separated schemas of x-schemas, or the schemas matching the LIKE pattern of x-schema-pattern.
+// The database URL of every target selects its schema with x-schema instead, so every target opens
+// connections of its own; FanOutURL migrates the schemas over a single connection.
+// Targets returns nil if databaseURL selects no schemas this way.
+func Targets(databaseURL string) ([]migrate.Target, error) {
+	purl, err := nurl.Parse(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	query := purl.Query()
+	schemas, pattern, err := selection(query)
+	if err != nil {
+		return nil, err
+	}
+	if schemas == nil && pattern == "" {
+		return nil, nil
+	}
+	if pattern != "" {
+		if schemas, err = listSchemas(purl, pattern); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSchemas(schemas, pattern); err != nil {
+		return nil, err
+	}
+
+	query.Del("x-schemas")
+	query.Del("x-schema-pattern")
+	targets := make([]migrate.Target, 0, len(schemas))
+	for _, schema := range schemas {
+		query.Set("x-schema", schema)
+		turl := *purl
+		turl.RawQuery = query.Encode()
+		targets = append(targets, migrate.Target{Name: schema, DatabaseURL: turl.String()})
+	}
+	return targets, nil
+}
+
+// selection returns the schemas listed by x-schemas, or the pattern of x-schema-pattern.
+// Both are empty if query selects no schemas this way.
+func selection(query nurl.Values) (schemas []string, pattern string, err error) {
+	list, pattern := query.Get("x-schemas"), query.Get("x-schema-pattern")
+	if list != "" && pattern != "" {
+		return nil, "", ErrSchemasAndPattern
+	}
+	if list == "" {
+		return nil, pattern, nil
+	}
+
+	schemas = []string{}
+	for _, schema := range strings.Split(list, ",") {
+		if schema = strings.TrimSpace(schema); schema != "" {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, "", nil
+}
+
+// checkSchemas returns ErrNoSchemas if no schemas have been selected.
+func checkSchemas(schemas []string, pattern string) error {
+	if len(schemas) == 0 && pattern != "" {
+		return fmt.Errorf("%w matching %s", ErrNoSchemas, pattern)
+	} else if len(schemas) == 0 {
+		return ErrNoSchemas
+	}
+	return nil
+}
+
+func listSchemas(purl *nurl.URL, pattern string) (schemas []string, err error) {
+	db, err := sql.Open("postgres", migrate.FilterCustomQuery(purl).String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := db.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := conn.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+	return postgres.ListSchemas(ctx, conn, pattern)
+}
@@ -0,0 +1,290 @@
+package schemas
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/dhui/dktest"
+
+	"github.com/abramad-labs/histomigrate"
+	"github.com/abramad-labs/histomigrate/database/postgres"
+	"github.com/abramad-labs/histomigrate/dktesting"
+	_ "github.com/abramad-labs/histomigrate/source/file"
+)
+
+const pgPassword = "postgres"
+
+var specs = []dktesting.ContainerSpec{
+	{ImageName: "postgres:17", Options: dktest.Options{
+		Env:          map[string]string{"POSTGRES_PASSWORD": pgPassword},
+		PortRequired: true, ReadyFunc: isReady}},
+}
+
+func pgConnectionString(host, port string) string {
+	return fmt.Sprintf("postgres://postgres:%s@%s:%s/postgres?sslmode=disable", pgPassword, host, port)
+}
+
+func isReady(ctx context.Context, c dktest.ContainerInfo) bool {
+	ip, port, err := c.FirstPort()
+	if err != nil {
+		return false
+	}
+
+	db, err := sql.Open("postgres", pgConnectionString(ip, port))
+	if err != nil {
+		return false
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Println("close error:", err)
+		}
+	}()
+	if err = db.PingContext(ctx); err != nil {
+		switch err {
+		case sqldriver.ErrBadConn, io.EOF:
+			return false
+		default:
+			log.Println(err)
+		}
+		return false
+	}
+
+	return true
+}
+
+func TestTargetsList(t *testing.T) {
+	targets, err := Targets("postgres://localhost:5432/app?sslmode=disable&x-schemas=tenant_acme,+tenant_globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []migrate.Target{
+		{Name: "tenant_acme", DatabaseURL: "postgres://localhost:5432/app?sslmode=disable&x-schema=tenant_acme"},
+		{Name: "tenant_globex", DatabaseURL: "postgres://localhost:5432/app?sslmode=disable&x-schema=tenant_globex"},
+	}
+	if fmt.Sprint(targets) != fmt.Sprint(expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+
+	if targets, err := Targets("postgres://localhost:5432/app?sslmode=disable"); err != nil || targets != nil {
+		t.Errorf("expected no targets, got %v, %v", targets, err)
+	}
+	if _, err := Targets("postgres://localhost:5432/app?x-schemas=a&x-schema-pattern=b"); !errors.Is(err, ErrSchemasAndPattern) {
+		t.Errorf("expected ErrSchemasAndPattern, got %v", err)
+	}
+	if _, err := Targets("postgres://localhost:5432/app?x-schemas=,"); !errors.Is(err, ErrNoSchemas) {
+		t.Errorf("expected ErrNoSchemas, got %v", err)
+	}
+
+	for databaseURL, expected := range map[string]bool{
+		"postgres://localhost:5432/app?x-schemas=a":            true,
+		"postgres://localhost:5432/app?x-schema-pattern=a_%25": true,
+		"postgres://localhost:5432/app?x-schema=a":             false,
+	} {
+		if Selects(databaseURL) != expected {
+			t.Errorf("expected Selects(%s) to be %v", databaseURL, expected)
+		}
+	}
+}
+
+func Test(t *testing.T) {
+	t.Run("testFanOut", testFanOut)
+	t.Run("testTargetsPattern", testTargetsPattern)
+	t.Run("testFanOutURL", testFanOutURL)
+
+	t.Cleanup(func() {
+		for _, spec := range specs {
+			t.Log("Cleaning up ", spec.ImageName)
+			if err := spec.Cleanup(); err != nil {
+				t.Error("Error removing ", spec.ImageName, "error:", err)
+			}
+		}
+	})
+}
+
+func createSchemas(t *testing.T, ctx context.Context, conn *sql.Conn, schemas ...string) {
+	for _, schema := range schemas {
+		if _, err := conn.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema+" AUTHORIZATION postgres"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testFanOut(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.FirstPort()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("postgres", pgConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		createSchemas(t, ctx, conn, "tenant_acme", "tenant_globex")
+
+		results, err := FanOut(ctx, conn, "file://../examples/migrations", []string{"tenant_acme", "tenant_globex"}, &postgres.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Err != nil || r.Pending != 0 || r.Version != 1485949617 {
+				t.Errorf("unexpected result %+v", r)
+			}
+		}
+
+		// every schema has its own tables and migrations table, the connection is back to its search_path
+		for _, schema := range []string{"tenant_acme", "tenant_globex"} {
+			var count int
+			query := `SELECT COUNT(1) FROM information_schema.tables WHERE table_schema = $1 AND table_name IN ('users', 'schema_migrations')`
+			if err := conn.QueryRowContext(ctx, query, schema).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("expected the tables of %s, got %d", schema, count)
+			}
+		}
+		var schema string
+		if err := conn.QueryRowContext(ctx, `SELECT CURRENT_SCHEMA()`).Scan(&schema); err != nil {
+			t.Fatal(err)
+		}
+		if schema != "public" {
+			t.Errorf("expected the search_path to be reset, got %s", schema)
+		}
+	})
+}
+
+func testTargetsPattern(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.FirstPort()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("postgres", pgConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		createSchemas(t, ctx, conn, "shop_acme", "shop_globex", "other")
+
+		targets, err := Targets(pgConnectionString(ip, port) + "&x-schema-pattern=shop_%25")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(targets) != 2 || targets[0].Name != "shop_acme" || targets[1].Name != "shop_globex" {
+			t.Fatalf("unexpected targets %v", targets)
+		}
+
+		results, err := migrate.FanOut("file://../examples/migrations", targets, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Err != nil || r.Pending != 0 || r.Version != 1485949617 {
+				t.Errorf("unexpected result %+v", r)
+			}
+		}
+
+		if _, err := Targets(pgConnectionString(ip, port) + "&x-schema-pattern=missing_%25"); !errors.Is(err, ErrNoSchemas) {
+			t.Errorf("expected ErrNoSchemas, got %v", err)
+		}
+	})
+}
+
+func testFanOutURL(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		ip, port, err := c.FirstPort()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("postgres", pgConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		createSchemas(t, ctx, conn, "url_acme", "url_globex", "other")
+
+		databaseURL := pgConnectionString(ip, port) + "&x-schema-pattern=url_%25&x-migrations-table=tenant_migrations"
+		results, err := FanOutURL("file://../examples/migrations", databaseURL, &migrate.FanOutConfig{Parallel: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Target.Name != "url_acme" || results[1].Target.Name != "url_globex" {
+			t.Fatalf("unexpected results %+v", results)
+		}
+		for _, r := range results {
+			if r.Err != nil || r.Pending != 0 || r.Version != 1485949617 {
+				t.Errorf("unexpected result %+v", r)
+			}
+		}
+
+		// the migrations table is taken from the URL
+		for _, schema := range []string{"url_acme", "url_globex"} {
+			var count int
+			query := `SELECT COUNT(1) FROM information_schema.tables WHERE table_schema = $1 AND table_name IN ('users', 'tenant_migrations')`
+			if err := conn.QueryRowContext(ctx, query, schema).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("expected the tables of %s, got %d", schema, count)
+			}
+		}
+
+		if _, err := FanOutURL("file://../examples/migrations", pgConnectionString(ip, port)+"&x-schema-pattern=missing_%25", nil); !errors.Is(err, ErrNoSchemas) {
+			t.Errorf("expected ErrNoSchemas, got %v", err)
+		}
+	})
+}
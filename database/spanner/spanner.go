@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	sdb "cloud.google.com/go/spanner/admin/database/apiv1"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/abramad-labs/histomigrate"
 	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/source"
 
 	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
 	"github.com/hashicorp/go-multierror"
@@ -24,11 +26,6 @@ import (
 	"google.golang.org/api/iterator"
 )
 
-func init() {
-	db := Spanner{}
-	database.Register("spanner", &db)
-}
-
 // DefaultMigrationsTable is used if no custom table is specified
 const DefaultMigrationsTable = "SchemaMigrations"
 
@@ -103,7 +100,9 @@ func WithInstance(instance *DB, config *Config) (database.Driver, error) {
 		return nil, err
 	}
 
-	return sx, nil
+	return &SpannerExtras{
+		Spanner: sx,
+	}, nil
 }
 
 // Open implements database.Driver
@@ -169,20 +168,22 @@ func (s *Spanner) Unlock() error {
 
 // Run implements database.Driver
 func (s *Spanner) Run(migration io.Reader) error {
+	return s.RunWithDirectives(context.Background(), migration, source.Directives{})
+}
+
+// RunWithDirectives runs the migration like Run, stopping to wait for it once ctx is done.
+// Schema updates never run in a transaction, so the no-transaction directive needs nothing more.
+func (s *Spanner) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
 	migr, err := io.ReadAll(migration)
 	if err != nil {
 		return err
 	}
 
-	stmts := []string{string(migr)}
-	if s.config.CleanStatements {
-		stmts, err = cleanStatements(migr)
-		if err != nil {
-			return err
-		}
+	stmts, err := s.statements(migr)
+	if err != nil {
+		return err
 	}
 
-	ctx := context.Background()
 	op, err := s.db.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
 		Database:   s.config.DatabaseName,
 		Statements: stmts,
@@ -199,6 +200,14 @@ func (s *Spanner) Run(migration io.Reader) error {
 	return nil
 }
 
+// statements returns the DDL statements of a migration, see Config.CleanStatements.
+func (s *Spanner) statements(migr []byte) ([]string, error) {
+	if s.config.CleanStatements {
+		return cleanStatements(migr)
+	}
+	return []string{string(migr)}, nil
+}
+
 // SetVersion implements database.Driver
 func (s *Spanner) SetVersion(version int, dirty bool) error {
 	ctx := context.Background()
@@ -208,8 +217,8 @@ func (s *Spanner) SetVersion(version int, dirty bool) error {
 			m := []*spanner.Mutation{
 				spanner.Delete(s.config.MigrationsTable, spanner.AllKeys()),
 				spanner.Insert(s.config.MigrationsTable,
-					[]string{"Version", "Dirty"},
-					[]interface{}{version, dirty},
+					[]string{"Version", "Dirty", "AppliedAt"},
+					[]interface{}{version, dirty, time.Now().UTC()},
 				)}
 			return txn.BufferWrite(m)
 		})
@@ -220,12 +229,12 @@ func (s *Spanner) SetVersion(version int, dirty bool) error {
 	return nil
 }
 
-// Version implements database.Driver
+// Version implements database.Driver. It returns the dirty version if there is one, the highest version otherwise.
 func (s *Spanner) Version() (version int, dirty bool, err error) {
 	ctx := context.Background()
 
 	stmt := spanner.Statement{
-		SQL: `SELECT Version, Dirty FROM ` + s.config.MigrationsTable + ` LIMIT 1`,
+		SQL: `SELECT Version, Dirty FROM ` + s.config.MigrationsTable + ` ORDER BY Dirty DESC, Version DESC LIMIT 1`,
 	}
 	iter := s.db.data.Single().Query(ctx, stmt)
 	defer iter.Stop()
@@ -317,12 +326,15 @@ func (s *Spanner) ensureVersionTable() (err error) {
 	tbl := s.config.MigrationsTable
 	iter := s.db.data.Single().Read(ctx, tbl, spanner.AllKeys(), []string{"Version"})
 	if err := iter.Do(func(r *spanner.Row) error { return nil }); err == nil {
-		return nil
+		return s.ensureHistoryColumns()
 	}
 
 	stmt := fmt.Sprintf(`CREATE TABLE %s (
     Version INT64 NOT NULL,
-    Dirty    BOOL NOT NULL
+    Dirty    BOOL NOT NULL,
+    AppliedAt TIMESTAMP,
+    Operation STRING(MAX),
+    Down BOOL
 	) PRIMARY KEY(Version)`, tbl)
 
 	op, err := s.db.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
@@ -340,6 +352,39 @@ func (s *Spanner) ensureVersionTable() (err error) {
 	return nil
 }
 
+// ensureHistoryColumns adds the columns used by the migration history to
+// migrations tables created by earlier releases.
+func (s *Spanner) ensureHistoryColumns() error {
+	ctx := context.Background()
+	tbl := s.config.MigrationsTable
+
+	stmts := make([]string, 0, 3)
+	for _, col := range []struct{ name, typ string }{{"AppliedAt", "TIMESTAMP"}, {"Operation", "STRING(MAX)"}, {"Down", "BOOL"}} {
+		iter := s.db.data.Single().Read(ctx, tbl, spanner.AllKeys(), []string{col.name})
+		if err := iter.Do(func(r *spanner.Row) error { return nil }); err == nil {
+			continue
+		}
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, tbl, col.name, col.typ))
+	}
+
+	if len(stmts) == 0 {
+		return nil
+	}
+
+	op, err := s.db.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   s.config.DatabaseName,
+		Statements: stmts,
+	})
+	if err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(strings.Join(stmts, "; "))}
+	}
+	if err := op.Wait(ctx); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(strings.Join(stmts, "; "))}
+	}
+
+	return nil
+}
+
 func cleanStatements(migration []byte) ([]string, error) {
 	// The Spanner GCP backend does not yet support comments for the UpdateDatabaseDdl RPC
 	// (see https://issuetracker.google.com/issues/159730604) we use
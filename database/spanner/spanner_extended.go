@@ -0,0 +1,320 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sdb "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+
+	"github.com/abramad-labs/histomigrate/database"
+)
+
+func init() {
+	db := SpannerExtras{
+		Spanner: &Spanner{},
+	}
+
+	database.Register("spanner", &db)
+}
+
+// DefaultOperationWaitAttempts is the number of times RunWithHistory waits on an
+// UpdateDatabaseDdl operation before giving up when waiting fails for reasons other
+// than the operation itself, e.g. a dropped connection.
+var DefaultOperationWaitAttempts = 3
+
+// SpannerExtras implements database.ExtendedDriver, database.LegacyHistoryDriver and database.TransactionalDriver on top of Spanner.
+// Spanner can't run DDL in a read-write transaction, so history rows are written
+// with mutations before and after each UpdateDatabaseDdl operation.
+type SpannerExtras struct {
+	*Spanner
+}
+
+// GetAllAppliedMigrations retrieves all versions recorded in the migrations table in descending order.
+func (s *SpannerExtras) GetAllAppliedMigrations() ([]int, error) {
+	ctx := context.Background()
+
+	stmt := spanner.Statement{
+		SQL: `SELECT Version FROM ` + s.config.MigrationsTable + ` ORDER BY Version DESC`,
+	}
+	iter := s.db.data.Single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	var appliedMigrations []int
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+		}
+
+		var v int64
+		if err := row.Columns(&v); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+		}
+		appliedMigrations = append(appliedMigrations, int(v))
+	}
+
+	return appliedMigrations, nil
+}
+
+// AddDirtyMigration inserts a dirty row for the given version.
+// The insert mutation fails if the version is already recorded.
+func (s *SpannerExtras) AddDirtyMigration(version uint) error {
+	m := spanner.Insert(s.config.MigrationsTable,
+		[]string{"Version", "Dirty", "AppliedAt"},
+		[]interface{}{int64(version), true, time.Now().UTC()},
+	)
+
+	if _, err := s.db.data.Apply(context.Background(), []*spanner.Mutation{m}); err != nil {
+		return &database.Error{OrigErr: err, Err: fmt.Sprintf("failed to add dirty migration %d", version)}
+	}
+
+	return nil
+}
+
+// UpdateMigrationDirtyFlag sets or clears the dirty flag of the given version and refreshes its AppliedAt timestamp.
+// Clearing the flag also clears the recorded UpdateDatabaseDdl operation.
+func (s *SpannerExtras) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	cols := []string{"Version", "Dirty", "AppliedAt"}
+	vals := []interface{}{int64(version), dirty, time.Now().UTC()}
+	if !dirty {
+		cols = append(cols, "Operation", "Down")
+		vals = append(vals, spanner.NullString{}, spanner.NullBool{})
+	}
+
+	m := spanner.Update(s.config.MigrationsTable, cols, vals)
+	if _, err := s.db.data.Apply(context.Background(), []*spanner.Mutation{m}); err != nil {
+		return &database.Error{OrigErr: err, Err: fmt.Sprintf("failed to update dirty flag of migration %d", version)}
+	}
+
+	return nil
+}
+
+// IsMigrationApplied checks if a row for the given version exists in the migrations table.
+func (s *SpannerExtras) IsMigrationApplied(version uint) (bool, error) {
+	_, err := s.db.data.Single().ReadRow(context.Background(), s.config.MigrationsTable, spanner.Key{int64(version)}, []string{"Version"})
+	if err != nil {
+		if spanner.ErrCode(err) == codes.NotFound {
+			return false, nil
+		}
+
+		return false, &database.Error{OrigErr: err, Err: fmt.Sprintf("failed to read migration %d", version)}
+	}
+
+	return true, nil
+}
+
+// RemoveMigration deletes the row of the given version from the migrations table.
+// Deleting a version that isn't recorded is not an error.
+func (s *SpannerExtras) RemoveMigration(version uint) error {
+	m := spanner.Delete(s.config.MigrationsTable, spanner.Key{int64(version)})
+	if _, err := s.db.data.Apply(context.Background(), []*spanner.Mutation{m}); err != nil {
+		return &database.Error{OrigErr: err, Err: fmt.Sprintf("failed to remove migration %d", version)}
+	}
+
+	return nil
+}
+
+// IsDatabaseDirty returns the first version found with the dirty flag set.
+// A dirty row recording the UpdateDatabaseDdl operation of its migration, e.g. left by a migrator that went away
+// while waiting on it, is reconciled first: the operation is waited on and, if it succeeded, the row is cleaned up
+// or removed as RunWithHistory would have done. Rows without an operation, or whose operation failed or can't be
+// looked up anymore, stay dirty.
+func (s *SpannerExtras) IsDatabaseDirty() (int, bool, error) {
+	for {
+		version, operation, down, err := s.firstDirty()
+		if err != nil || version == nil {
+			return 0, false, err
+		}
+		if !operation.Valid {
+			return int(*version), true, nil
+		}
+
+		op := s.db.admin.UpdateDatabaseDdlOperation(operation.StringVal)
+		if _, err := s.waitForOperation(context.Background(), op); err != nil {
+			return int(*version), true, nil
+		}
+		if down.Valid && down.Bool {
+			err = s.RemoveMigration(uint(*version))
+		} else {
+			err = s.UpdateMigrationDirtyFlag(uint(*version), false)
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// firstDirty returns the first dirty row with its operation, or a nil version if there is none.
+func (s *SpannerExtras) firstDirty() (version *int64, operation spanner.NullString, down spanner.NullBool, err error) {
+	stmt := spanner.Statement{
+		SQL: `SELECT Version, Operation, Down FROM ` + s.config.MigrationsTable + ` WHERE Dirty = true LIMIT 1`,
+	}
+	iter := s.db.data.Single().Query(context.Background(), stmt)
+	defer iter.Stop()
+
+	row, err := iter.Next()
+	switch err {
+	case iterator.Done:
+		return nil, operation, down, nil
+	case nil:
+		var v int64
+		if err := row.Columns(&v, &operation, &down); err != nil {
+			return nil, operation, down, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+		}
+		return &v, operation, down, nil
+	default:
+		return nil, operation, down, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+	}
+}
+
+// LegacyVersion returns the version of the row written by an earlier release, which only kept the current version.
+// Such a row has no AppliedAt, as every row written since has one.
+func (s *SpannerExtras) LegacyVersion() (int, bool, error) {
+	ctx := context.Background()
+
+	stmt := spanner.Statement{
+		SQL: `SELECT Version FROM ` + s.config.MigrationsTable + ` WHERE AppliedAt IS NULL ORDER BY Version DESC LIMIT 1`,
+	}
+	iter := s.db.data.Single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	row, err := iter.Next()
+	switch err {
+	case iterator.Done:
+		return database.NilVersion, false, nil
+	case nil:
+		var v int64
+		if err := row.Columns(&v); err != nil {
+			return database.NilVersion, false, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+		}
+		return int(v), true, nil
+	default:
+		return database.NilVersion, false, &database.Error{OrigErr: err, Query: []byte(stmt.SQL)}
+	}
+}
+
+// BackfillHistory inserts a clean row for every version not recorded yet and sets AppliedAt on the rows
+// of the earlier release, keeping their dirty flag, in a single read-write transaction.
+func (s *SpannerExtras) BackfillHistory(versions []uint) error {
+	ctx := context.Background()
+	tbl := s.config.MigrationsTable
+
+	_, err := s.db.data.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		now := time.Now().UTC()
+		recorded := make(map[int64]bool)
+		var mutations []*spanner.Mutation
+
+		err := txn.Read(ctx, tbl, spanner.AllKeys(), []string{"Version", "AppliedAt"}).Do(func(r *spanner.Row) error {
+			var v int64
+			var appliedAt spanner.NullTime
+			if err := r.Columns(&v, &appliedAt); err != nil {
+				return err
+			}
+			recorded[v] = true
+			if !appliedAt.Valid {
+				mutations = append(mutations, spanner.Update(tbl, []string{"Version", "AppliedAt"}, []interface{}{v, now}))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, version := range versions {
+			if !recorded[int64(version)] {
+				mutations = append(mutations, spanner.Insert(tbl,
+					[]string{"Version", "Dirty", "AppliedAt"},
+					[]interface{}{int64(version), false, now},
+				))
+			}
+		}
+
+		return txn.BufferWrite(mutations)
+	})
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to backfill the migration history"}
+	}
+
+	return nil
+}
+
+// RunWithHistory marks the version dirty, starts the UpdateDatabaseDdl operation and records
+// its name and direction on the dirty row, so that IsDatabaseDirty can reconcile the row if the migrator
+// goes away while the operation runs.
+// Once the operation finished, the row is reconciled: cleaned up after an up migration or removed after a down migration.
+// Spanner applies the statements of a batch one by one, so a failing operation may leave some of them applied;
+// the row then stays dirty and the error reports how many statements were committed.
+func (s *SpannerExtras) RunWithHistory(version uint, up bool, migration io.Reader) error {
+	if up {
+		if err := s.AddDirtyMigration(version); err != nil {
+			return err
+		}
+	} else {
+		if err := s.UpdateMigrationDirtyFlag(version, true); err != nil {
+			return err
+		}
+	}
+
+	migr, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	stmts, err := s.statements(migr)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	op, err := s.db.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   s.config.DatabaseName,
+		Statements: stmts,
+	})
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "migration failed", Query: migr}
+	}
+
+	m := spanner.Update(s.config.MigrationsTable, []string{"Version", "Operation", "Down"}, []interface{}{int64(version), op.Name(), !up})
+	if _, err := s.db.data.Apply(ctx, []*spanner.Mutation{m}); err != nil {
+		return &database.Error{OrigErr: err, Err: fmt.Sprintf("failed to record operation %s of migration %d", op.Name(), version)}
+	}
+
+	if op, err = s.waitForOperation(ctx, op); err != nil {
+		message := "migration failed"
+		if md, mdErr := op.Metadata(); mdErr == nil && md != nil {
+			message = fmt.Sprintf("migration failed after %d of %d statements", len(md.CommitTimestamps), len(stmts))
+		}
+		return &database.Error{OrigErr: err, Err: message, Query: migr}
+	}
+
+	if up {
+		return s.UpdateMigrationDirtyFlag(version, false)
+	}
+
+	return s.RemoveMigration(version)
+}
+
+// waitForOperation waits for op to finish. If waiting fails while the operation is still running,
+// the operation is resumed by name and waited on again, up to DefaultOperationWaitAttempts times.
+// The returned operation is the one last waited on.
+func (s *SpannerExtras) waitForOperation(ctx context.Context, op *sdb.UpdateDatabaseDdlOperation) (*sdb.UpdateDatabaseDdlOperation, error) {
+	var err error
+	for attempt := 0; attempt < DefaultOperationWaitAttempts; attempt++ {
+		if err = op.Wait(ctx); err == nil || op.Done() {
+			return op, err
+		}
+
+		op = s.db.admin.UpdateDatabaseDdlOperation(op.Name())
+	}
+
+	return op, err
+}
@@ -1,8 +1,10 @@
 package spanner
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/abramad-labs/histomigrate"
@@ -10,6 +12,9 @@ import (
 	dt "github.com/abramad-labs/histomigrate/database/testing"
 	_ "github.com/abramad-labs/histomigrate/source/file"
 
+	"cloud.google.com/go/spanner"
+	sdb "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
 	"cloud.google.com/go/spanner/spannertest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -61,6 +66,140 @@ func TestMigrate(t *testing.T) {
 	})
 }
 
+func TestExtended(t *testing.T) {
+	withSpannerEmulator(t, func(t *testing.T) {
+		uri := fmt.Sprintf("spanner://%s", db)
+		s := &Spanner{}
+		d, err := s.Open(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt.TestExtended(t, d.(*SpannerExtras))
+	})
+}
+
+func TestRunWithHistory(t *testing.T) {
+	withSpannerEmulator(t, func(t *testing.T) {
+		uri := fmt.Sprintf("spanner://%s?x-clean-statements=true", db)
+		s := &Spanner{}
+		d, err := s.Open(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sx := d.(*SpannerExtras)
+
+		require.NoError(t, sx.RunWithHistory(1, true, strings.NewReader("CREATE TABLE foo (id INT64) PRIMARY KEY (id)")))
+		isApplied, err := sx.IsMigrationApplied(1)
+		require.NoError(t, err)
+		assert.True(t, isApplied)
+		_, dirty, err := sx.IsDatabaseDirty()
+		require.NoError(t, err)
+		assert.False(t, dirty)
+
+		// the second statement fails, the row stays dirty
+		err = sx.RunWithHistory(2, true, strings.NewReader("CREATE TABLE bar (id INT64) PRIMARY KEY (id); CREATE TABLE foo (id INT64) PRIMARY KEY (id)"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "migration failed")
+		version, dirty, err := sx.IsDatabaseDirty()
+		require.NoError(t, err)
+		assert.True(t, dirty)
+		assert.Equal(t, 2, version)
+
+		require.NoError(t, sx.RemoveMigration(2))
+		require.NoError(t, sx.RunWithHistory(1, false, strings.NewReader("DROP TABLE foo")))
+		isApplied, err = sx.IsMigrationApplied(1)
+		require.NoError(t, err)
+		assert.False(t, isApplied)
+	})
+}
+
+func TestReconcileOperation(t *testing.T) {
+	withSpannerEmulator(t, func(t *testing.T) {
+		ctx := context.Background()
+		s := &Spanner{}
+		d, err := s.Open(fmt.Sprintf("spanner://%s", db))
+		require.NoError(t, err)
+		sx := d.(*SpannerExtras)
+
+		// the migrator goes away after starting the operation of a migration
+		interrupt := func(version uint, up bool, stmt string) {
+			t.Helper()
+			if up {
+				require.NoError(t, sx.AddDirtyMigration(version))
+			} else {
+				require.NoError(t, sx.UpdateMigrationDirtyFlag(version, true))
+			}
+			op, err := sx.db.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+				Database:   sx.config.DatabaseName,
+				Statements: []string{stmt},
+			})
+			require.NoError(t, err)
+			_, err = sx.db.data.Apply(ctx, []*spanner.Mutation{
+				spanner.Update(sx.config.MigrationsTable, []string{"Version", "Operation", "Down"}, []interface{}{int64(version), op.Name(), !up}),
+			})
+			require.NoError(t, err)
+		}
+
+		interrupt(1, true, "CREATE TABLE foo (id INT64) PRIMARY KEY (id)")
+		_, dirty, err := sx.IsDatabaseDirty()
+		require.NoError(t, err)
+		assert.False(t, dirty)
+		isApplied, err := sx.IsMigrationApplied(1)
+		require.NoError(t, err)
+		assert.True(t, isApplied)
+
+		interrupt(1, false, "DROP TABLE foo")
+		_, dirty, err = sx.IsDatabaseDirty()
+		require.NoError(t, err)
+		assert.False(t, dirty)
+		isApplied, err = sx.IsMigrationApplied(1)
+		require.NoError(t, err)
+		assert.False(t, isApplied)
+
+		// without an operation, nothing tells how far the migration went
+		require.NoError(t, sx.AddDirtyMigration(2))
+		version, dirty, err := sx.IsDatabaseDirty()
+		require.NoError(t, err)
+		assert.True(t, dirty)
+		assert.Equal(t, 2, version)
+	})
+}
+
+func TestLegacyHistory(t *testing.T) {
+	withSpannerEmulator(t, func(t *testing.T) {
+		ctx := context.Background()
+
+		// the migrations table of an earlier release at version 3
+		admin, err := sdb.NewDatabaseAdminClient(ctx)
+		require.NoError(t, err)
+		op, err := admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   db,
+			Statements: []string{"CREATE TABLE LegacyMigrations (Version INT64 NOT NULL, Dirty BOOL NOT NULL) PRIMARY KEY(Version)"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, op.Wait(ctx))
+		require.NoError(t, admin.Close())
+
+		data, err := spanner.NewClient(ctx, db)
+		require.NoError(t, err)
+		_, err = data.Apply(ctx, []*spanner.Mutation{
+			spanner.Insert("LegacyMigrations", []string{"Version", "Dirty"}, []interface{}{int64(3), true}),
+		})
+		require.NoError(t, err)
+		data.Close()
+
+		s := &Spanner{}
+		d, err := s.Open(fmt.Sprintf("spanner://%s?x-migrations-table=LegacyMigrations", db))
+		require.NoError(t, err)
+		dt.TestLegacyHistory(t, d.(*SpannerExtras), true)
+
+		version, dirty, err := d.Version()
+		require.NoError(t, err)
+		assert.Equal(t, 3, version)
+		assert.True(t, dirty)
+	})
+}
+
 func TestCleanStatements(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -1,15 +1,19 @@
 package sqlcipher
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/abramad-labs/histomigrate"
+	"github.com/abramad-labs/histomigrate/database"
 	dt "github.com/abramad-labs/histomigrate/database/testing"
+	"github.com/abramad-labs/histomigrate/source"
 	_ "github.com/abramad-labs/histomigrate/source/file"
 	_ "github.com/mutecomm/go-sqlcipher/v4"
 )
@@ -107,6 +111,30 @@ func TestNoTxWrap(t *testing.T) {
 	dt.Test(t, d, []byte("BEGIN; CREATE TABLE t (Qty int, Name string); COMMIT;"))
 }
 
+func TestNoTransaction(t *testing.T) {
+	dir := t.TempDir()
+	p := &Sqlite{}
+	d, err := p.Open(fmt.Sprintf("sqlite3://%s", filepath.Join(dir, "sqlite3.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// VACUUM fails within a transaction
+	migration := "-- histomigrate: no-transaction\nVACUUM;"
+	if err := d.Run(strings.NewReader(migration)); err == nil {
+		t.Fatal("expected VACUUM to fail within a transaction")
+	}
+	err = d.(database.DirectiveDriver).RunWithDirectives(context.Background(), strings.NewReader(migration), source.Directives{NoTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestNoTxWrapInvalidValue(t *testing.T) {
 	dir := t.TempDir()
 	t.Logf("DB path : %s\n", filepath.Join(dir, "sqlite3.db"))
@@ -0,0 +1,130 @@
+package sqlhistory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Dialect describes the SQL flavour of a database, as far as the history queries need it.
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th argument of a query, starting at 1.
+	Placeholder func(n int) string
+
+	// QuoteIdentifier quotes a table or column name.
+	QuoteIdentifier func(name string) string
+
+	// InsertMissing returns a statement that inserts a row of the version, dirty and applied_at
+	// columns into table, bound in that order, unless a row with the same version exists.
+	// The statement must not affect any row in that case.
+	InsertMissing func(table string) string
+
+	// CreateTable returns the statement creating the history table. The table needs a
+	// version (BIGINT, primary key), a dirty (boolean) and an applied_at (timestamp) column.
+	CreateTable func(table string) string
+
+	// IsUndefinedTable reports whether err was caused by a missing history table.
+	IsUndefinedTable func(err error) bool
+}
+
+// Postgres is the dialect of PostgreSQL and compatible databases such as CockroachDB and YugabyteDB.
+var Postgres = Dialect{
+	Placeholder:      dollarPlaceholder,
+	QuoteIdentifier:  quoteWith(`"`),
+	InsertMissing:    onConflictDoNothing(quoteWith(`"`), dollarPlaceholder),
+	CreateTable:      createTable("BIGINT", "BOOLEAN", "TIMESTAMP"),
+	IsUndefinedTable: sqlStateIs("42P01"),
+}
+
+// Redshift is the dialect of Amazon Redshift, which supports neither ON CONFLICT nor unique constraints:
+// its primary keys are informational only, so rows are inserted only if a query finds no row of the version.
+var Redshift = Dialect{
+	Placeholder:     dollarPlaceholder,
+	QuoteIdentifier: quoteWith(`"`),
+	InsertMissing: func(table string) string {
+		quote := quoteWith(`"`)
+		return fmt.Sprintf("INSERT INTO %s (%s) SELECT CAST($1 AS BIGINT), CAST($2 AS BOOLEAN), CAST($3 AS TIMESTAMP) WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s = $1)",
+			table, strings.Join(quoteAll(quote, historyColumns), ", "), table, quote("version"))
+	},
+	CreateTable:      createTable("BIGINT", "BOOLEAN", "TIMESTAMP"),
+	IsUndefinedTable: sqlStateIs("42P01"),
+}
+
+// SQLite is the dialect of SQLite, e.g. through the sqlite, sqlite3 and sqlcipher database/sql drivers.
+var SQLite = Dialect{
+	Placeholder:      func(int) string { return "?" },
+	QuoteIdentifier:  quoteWith(`"`),
+	InsertMissing:    onConflictDoNothing(quoteWith(`"`), func(int) string { return "?" }),
+	CreateTable:      createTable("INTEGER", "BOOLEAN", "DATETIME"),
+	IsUndefinedTable: messageContains("no such table"),
+}
+
+// MySQL is the dialect of MySQL and MariaDB.
+var MySQL = Dialect{
+	Placeholder:     func(int) string { return "?" },
+	QuoteIdentifier: quoteWith("`"),
+	InsertMissing: func(table string) string {
+		quote := quoteWith("`")
+		// assigning the version to itself leaves an existing row unchanged, which counts as no affected row
+		return insert(quote, func(int) string { return "?" }, table, historyColumns) +
+			fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s = %s", quote("version"), quote("version"))
+	},
+	CreateTable: createTable("BIGINT", "BOOLEAN", "DATETIME"),
+	// 1146 is ER_NO_SUCH_TABLE
+	IsUndefinedTable: messageContains("Error 1146"),
+}
+
+var historyColumns = []string{"version", "dirty", "applied_at"}
+
+func dollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func quoteWith(q string) func(string) string {
+	return func(name string) string {
+		return q + strings.ReplaceAll(name, q, q+q) + q
+	}
+}
+
+func quoteAll(quote func(string) string, names []string) []string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quote(name))
+	}
+	return quoted
+}
+
+func insert(quote func(string) string, placeholder func(int) string, table string, columns []string) string {
+	vals := make([]string, 0, len(columns))
+	for i := range columns {
+		vals = append(vals, placeholder(i+1))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoteAll(quote, columns), ", "), strings.Join(vals, ", "))
+}
+
+func onConflictDoNothing(quote func(string) string, placeholder func(int) string) func(string) string {
+	return func(table string) string {
+		return insert(quote, placeholder, table, historyColumns) + fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quote("version"))
+	}
+}
+
+func createTable(versionType, dirtyType, appliedAtType string) func(string) string {
+	return func(table string) string {
+		return fmt.Sprintf("CREATE TABLE %s (version %s NOT NULL PRIMARY KEY, dirty %s NOT NULL, applied_at %s)",
+			table, versionType, dirtyType, appliedAtType)
+	}
+}
+
+// sqlStateIs matches errors of drivers exposing the SQLSTATE code, like lib/pq and pgx.
+func sqlStateIs(code string) func(error) bool {
+	return func(err error) bool {
+		var e interface{ SQLState() string }
+		return errors.As(err, &e) && e.SQLState() == code
+	}
+}
+
+func messageContains(s string) func(error) bool {
+	return func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), s)
+	}
+}
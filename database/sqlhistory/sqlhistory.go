@@ -0,0 +1,233 @@
+// Package sqlhistory implements the migration history of database.ExtendedDriver on top of database/sql.
+//
+// A driver gets history support by handing its connection, a Dialect and the name of the history table
+// to New and combining the result with itself through Extend:
+//
+//	h, err := sqlhistory.New(ctx, conn, sqlhistory.Postgres, "schema_migrations")
+//	if err != nil {
+//		return nil, err
+//	}
+//	return sqlhistory.Extend(driver, h), nil
+package sqlhistory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/hashicorp/go-multierror"
+)
+
+var (
+	ErrNilConn           = errors.New("no connection")
+	ErrNoTable           = errors.New("no history table")
+	ErrIncompleteDialect = errors.New("incomplete dialect")
+	ErrMigrationExists   = errors.New("migration already recorded")
+)
+
+// History keeps one row per applied migration in a table.
+// All reads and writes go through the same connection, usually the one holding the migration lock.
+type History struct {
+	conn    *sql.Conn
+	dialect Dialect
+	table   string // quoted
+
+	selectAll     string
+	selectOne     string
+	selectDirty   string
+	insertMissing string
+	update        string
+	delete        string
+}
+
+// New returns the History kept in table, creating the table if it doesn't exist.
+// The table name may be qualified with a schema, e.g. "public.schema_migrations".
+func New(ctx context.Context, conn *sql.Conn, dialect Dialect, table string) (*History, error) {
+	if conn == nil {
+		return nil, ErrNilConn
+	}
+	if table == "" {
+		return nil, ErrNoTable
+	}
+	if dialect.Placeholder == nil || dialect.QuoteIdentifier == nil || dialect.InsertMissing == nil ||
+		dialect.CreateTable == nil || dialect.IsUndefinedTable == nil {
+		return nil, ErrIncompleteDialect
+	}
+
+	parts := strings.Split(table, ".")
+	for i := range parts {
+		parts[i] = dialect.QuoteIdentifier(parts[i])
+	}
+	quoted := strings.Join(parts, ".")
+
+	q := dialect.QuoteIdentifier
+	p := dialect.Placeholder
+	h := &History{
+		conn:    conn,
+		dialect: dialect,
+		table:   quoted,
+
+		selectAll:     fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s DESC`, q("version"), quoted, q("version")),
+		selectOne:     fmt.Sprintf(`SELECT COUNT(1) FROM %s WHERE %s = %s`, quoted, q("version"), p(1)),
+		selectDirty:   fmt.Sprintf(`SELECT %s FROM %s WHERE %s = %s ORDER BY %s`, q("version"), quoted, q("dirty"), p(1), q("version")),
+		insertMissing: dialect.InsertMissing(quoted),
+		update:        fmt.Sprintf(`UPDATE %s SET %s = %s, %s = %s WHERE %s = %s`, quoted, q("dirty"), p(1), q("applied_at"), p(2), q("version"), p(3)),
+		delete:        fmt.Sprintf(`DELETE FROM %s WHERE %s = %s`, quoted, q("version"), p(1)),
+	}
+
+	if err := h.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// GetAllAppliedMigrations retrieves all versions recorded in the history table in descending order.
+func (h *History) GetAllAppliedMigrations() (appliedMigrations []int, err error) {
+	rows, err := h.conn.QueryContext(context.Background(), h.selectAll)
+	if err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(h.selectAll)}
+	}
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(h.selectAll)}
+		}
+		appliedMigrations = append(appliedMigrations, int(version))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(h.selectAll)}
+	}
+
+	return appliedMigrations, nil
+}
+
+// IsMigrationApplied checks if a row for the given version exists in the history table.
+// A missing history table is reported as not applied.
+func (h *History) IsMigrationApplied(version uint) (bool, error) {
+	var count int
+	if err := h.conn.QueryRowContext(context.Background(), h.selectOne, int64(version)).Scan(&count); err != nil {
+		if h.dialect.IsUndefinedTable(err) {
+			return false, nil
+		}
+		return false, &database.Error{OrigErr: err, Query: []byte(h.selectOne)}
+	}
+
+	return count > 0, nil
+}
+
+// IsDatabaseDirty returns the lowest version with the dirty flag set.
+// A missing history table is reported as a clean database.
+func (h *History) IsDatabaseDirty() (int, bool, error) {
+	var version int64
+	if err := h.conn.QueryRowContext(context.Background(), h.selectDirty, true).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || h.dialect.IsUndefinedTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, &database.Error{OrigErr: err, Query: []byte(h.selectDirty)}
+	}
+
+	return int(version), true, nil
+}
+
+// AddDirtyMigration inserts a dirty row for the given version.
+// It fails with ErrMigrationExists if the version is already recorded.
+func (h *History) AddDirtyMigration(version uint) error {
+	inserted, err := h.exec(h.insertMissing, int64(version), true, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return fmt.Errorf("%w: %d", ErrMigrationExists, version)
+	}
+	return nil
+}
+
+// UpdateMigrationDirtyFlag sets or clears the dirty flag of the given version and refreshes its applied_at timestamp.
+// A version that isn't recorded is left alone.
+func (h *History) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	_, err := h.exec(h.update, dirty, time.Now().UTC(), int64(version))
+	return err
+}
+
+// RemoveMigration deletes the row of the given version from the history table.
+// Deleting a version that isn't recorded is not an error.
+func (h *History) RemoveMigration(version uint) error {
+	_, err := h.exec(h.delete, int64(version))
+	return err
+}
+
+// exec runs a single history write in its own transaction and returns the number of affected rows.
+func (h *History) exec(query string, args ...interface{}) (int64, error) {
+	tx, err := h.conn.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return 0, &database.Error{OrigErr: err, Err: "transaction start failed"}
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, rollback(tx, err, query)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, rollback(tx, err, query)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, &database.Error{OrigErr: err, Err: "transaction commit failed"}
+	}
+
+	return affected, nil
+}
+
+func rollback(tx *sql.Tx, err error, query string) error {
+	if errRollback := tx.Rollback(); errRollback != nil {
+		err = multierror.Append(err, errRollback)
+	}
+	return &database.Error{OrigErr: err, Query: []byte(query)}
+}
+
+// ensureTable creates the history table unless a query against it succeeds.
+func (h *History) ensureTable(ctx context.Context) error {
+	rows, err := h.conn.QueryContext(ctx, h.selectAll)
+	if err == nil {
+		return rows.Close()
+	}
+	if !h.dialect.IsUndefinedTable(err) {
+		return &database.Error{OrigErr: err, Query: []byte(h.selectAll)}
+	}
+
+	query := h.dialect.CreateTable(h.table)
+	if _, err := h.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// Driver is a database.ExtendedDriver made of a database.Driver and a History.
+// It only has the methods of database.Driver and History: optional interfaces implemented by the
+// wrapped driver, e.g. database.ContextDriver or database.TransactionalDriver, are hidden.
+type Driver struct {
+	database.Driver
+	*History
+}
+
+// Extend adds the history methods of h to d. Optional interfaces of d are hidden, see Driver;
+// a driver implementing any should embed History in a type of its own instead.
+func Extend(d database.Driver, h *History) *Driver {
+	return &Driver{
+		Driver:  d,
+		History: h,
+	}
+}
@@ -0,0 +1,112 @@
+package sqlhistory
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/database/stub"
+	dt "github.com/abramad-labs/histomigrate/database/testing"
+)
+
+func openConn(t *testing.T) *sql.Conn {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sqlite.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestHistory(t *testing.T) {
+	conn := openConn(t)
+
+	h, err := New(context.Background(), conn, SQLite, "schema_migrations")
+	require.NoError(t, err)
+
+	var d database.ExtendedDriver = Extend(&stub.Stub{}, h)
+	dt.TestExtended(t, d)
+
+	// the table is reused on the next call
+	h, err = New(context.Background(), conn, SQLite, "schema_migrations")
+	require.NoError(t, err)
+	require.NoError(t, h.AddDirtyMigration(5))
+	applied, err := h.GetAllAppliedMigrations()
+	require.NoError(t, err)
+	assert.Equal(t, []int{5}, applied)
+}
+
+func TestAddDirtyMigrationExists(t *testing.T) {
+	h, err := New(context.Background(), openConn(t), SQLite, "schema_migrations")
+	require.NoError(t, err)
+
+	require.NoError(t, h.AddDirtyMigration(7))
+	assert.ErrorIs(t, h.AddDirtyMigration(7), ErrMigrationExists)
+}
+
+func TestUpdateMigrationDirtyFlagMissingRow(t *testing.T) {
+	h, err := New(context.Background(), openConn(t), SQLite, "schema_migrations")
+	require.NoError(t, err)
+
+	require.NoError(t, h.UpdateMigrationDirtyFlag(7, true))
+	applied, err := h.IsMigrationApplied(7)
+	require.NoError(t, err)
+	assert.False(t, applied)
+}
+
+func TestNew(t *testing.T) {
+	conn := openConn(t)
+
+	_, err := New(context.Background(), nil, SQLite, "schema_migrations")
+	assert.ErrorIs(t, err, ErrNilConn)
+
+	_, err = New(context.Background(), conn, SQLite, "")
+	assert.ErrorIs(t, err, ErrNoTable)
+
+	_, err = New(context.Background(), conn, Dialect{}, "schema_migrations")
+	assert.ErrorIs(t, err, ErrIncompleteDialect)
+}
+
+func TestDialects(t *testing.T) {
+	assert.Equal(t,
+		`INSERT INTO t ("version", "dirty", "applied_at") VALUES ($1, $2, $3) ON CONFLICT ("version") DO NOTHING`,
+		Postgres.InsertMissing("t"))
+	assert.Equal(t,
+		`INSERT INTO t ("version", "dirty", "applied_at") SELECT CAST($1 AS BIGINT), CAST($2 AS BOOLEAN), CAST($3 AS TIMESTAMP) WHERE NOT EXISTS (SELECT 1 FROM t WHERE "version" = $1)`,
+		Redshift.InsertMissing("t"))
+	assert.Equal(t,
+		`INSERT INTO t ("version", "dirty", "applied_at") VALUES (?, ?, ?) ON CONFLICT ("version") DO NOTHING`,
+		SQLite.InsertMissing("t"))
+	assert.Equal(t,
+		"INSERT INTO t (`version`, `dirty`, `applied_at`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `version` = `version`",
+		MySQL.InsertMissing("t"))
+	assert.Equal(t, `"a""b"`, Postgres.QuoteIdentifier(`a"b`))
+}
+
+func TestRedshiftInsertMissing(t *testing.T) {
+	// SQLite runs the statement of Redshift, which has no unique constraints to rely on
+	dialect := SQLite
+	dialect.Placeholder = Redshift.Placeholder
+	dialect.InsertMissing = Redshift.InsertMissing
+	dialect.CreateTable = func(table string) string {
+		return "CREATE TABLE " + table + " (version INTEGER NOT NULL, dirty BOOLEAN NOT NULL, applied_at DATETIME)"
+	}
+
+	h, err := New(context.Background(), openConn(t), dialect, "schema_migrations")
+	require.NoError(t, err)
+
+	require.NoError(t, h.AddDirtyMigration(7))
+	assert.ErrorIs(t, h.AddDirtyMigration(7), ErrMigrationExists)
+	applied, err := h.GetAllAppliedMigrations()
+	require.NoError(t, err)
+	assert.Equal(t, []int{7}, applied)
+}
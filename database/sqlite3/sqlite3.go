@@ -1,6 +1,7 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/abramad-labs/histomigrate"
 	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/source"
 	"github.com/hashicorp/go-multierror"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -162,7 +164,7 @@ func (m *Sqlite) Drop() (err error) {
 	if len(tableNames) > 0 {
 		for _, t := range tableNames {
 			query := "DROP TABLE " + t
-			err = m.executeQuery(query)
+			err = m.executeQuery(context.Background(), query)
 			if err != nil {
 				return &database.Error{OrigErr: err, Query: []byte(query)}
 			}
@@ -192,24 +194,30 @@ func (m *Sqlite) Unlock() error {
 }
 
 func (m *Sqlite) Run(migration io.Reader) error {
+	return m.RunWithDirectives(context.Background(), migration, source.Directives{})
+}
+
+// RunWithDirectives runs the migration like Run. With the no-transaction directive,
+// the migration isn't wrapped in a transaction, just like with x-no-tx-wrap.
+func (m *Sqlite) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
 	migr, err := io.ReadAll(migration)
 	if err != nil {
 		return err
 	}
 	query := string(migr[:])
 
-	if m.config.NoTxWrap {
-		return m.executeQueryNoTx(query)
+	if m.config.NoTxWrap || directives.NoTransaction {
+		return m.executeQueryNoTx(ctx, query)
 	}
-	return m.executeQuery(query)
+	return m.executeQuery(ctx, query)
 }
 
-func (m *Sqlite) executeQuery(query string) error {
-	tx, err := m.db.Begin()
+func (m *Sqlite) executeQuery(ctx context.Context, query string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return &database.Error{OrigErr: err, Err: "transaction start failed"}
 	}
-	if _, err := tx.Exec(query); err != nil {
+	if _, err := tx.ExecContext(ctx, query); err != nil {
 		if errRollback := tx.Rollback(); errRollback != nil {
 			err = multierror.Append(err, errRollback)
 		}
@@ -221,8 +229,8 @@ func (m *Sqlite) executeQuery(query string) error {
 	return nil
 }
 
-func (m *Sqlite) executeQueryNoTx(query string) error {
-	if _, err := m.db.Exec(query); err != nil {
+func (m *Sqlite) executeQueryNoTx(ctx context.Context, query string) error {
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
 	}
 	return nil
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	nurl "net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -14,14 +15,11 @@ import (
 	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/abramad-labs/histomigrate"
 	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/source"
 	"github.com/hashicorp/go-multierror"
 	mssql "github.com/microsoft/go-mssqldb" // mssql support
 )
 
-func init() {
-	database.Register("sqlserver", &SQLServer{})
-}
-
 // DefaultMigrationsTable is the name of the migrations table in the database
 var DefaultMigrationsTable = "schema_migrations"
 
@@ -45,6 +43,7 @@ type Config struct {
 	MigrationsTable string
 	DatabaseName    string
 	SchemaName      string
+	NoTxWrap        bool
 }
 
 // SQL Server connection
@@ -118,7 +117,9 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 		return nil, err
 	}
 
-	return ss, nil
+	return &SQLServerExtras{
+		SQLServer: ss,
+	}, nil
 }
 
 // Open a connection to the database.
@@ -167,10 +168,21 @@ func (ss *SQLServer) Open(url string) (database.Driver, error) {
 	}
 
 	migrationsTable := purl.Query().Get("x-migrations-table")
+	schemaName := purl.Query().Get("x-migrations-schema")
+
+	noTxWrap := false
+	if s := purl.Query().Get("x-no-tx-wrap"); len(s) > 0 {
+		noTxWrap, err = strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse option x-no-tx-wrap: %w", err)
+		}
+	}
 
 	px, err := WithInstance(db, &Config{
 		DatabaseName:    purl.Path,
 		MigrationsTable: migrationsTable,
+		SchemaName:      schemaName,
+		NoTxWrap:        noTxWrap,
 	})
 
 	if err != nil {
@@ -238,24 +250,49 @@ func (ss *SQLServer) Unlock() error {
 	})
 }
 
-// Run the migrations for the database
+// Run the migrations for the database.
+// Scripts are split on GO batch separators and the batches are sent to the server one after another.
 func (ss *SQLServer) Run(migration io.Reader) error {
+	return ss.RunContext(context.Background(), migration)
+}
+
+// RunContext runs the migration like Run, cancelling the running batch once ctx is done.
+func (ss *SQLServer) RunContext(ctx context.Context, migration io.Reader) error {
 	migr, err := io.ReadAll(migration)
 	if err != nil {
 		return err
 	}
 
-	// run migration
-	query := string(migr[:])
-	if _, err := ss.conn.ExecContext(context.Background(), query); err != nil {
-		if msErr, ok := err.(mssql.Error); ok {
-			message := fmt.Sprintf("migration failed: %s", msErr.Message)
-			if msErr.ProcName != "" {
-				message = fmt.Sprintf("%s (proc name %s)", msErr.Message, msErr.ProcName)
+	return ss.runBatches(ctx, ss.conn, migr)
+}
+
+// RunWithDirectives runs the migration like RunContext. Run never opens a transaction, every batch
+// commits on its own, so the no-transaction directive needs nothing more.
+func (ss *SQLServer) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
+	return ss.RunContext(ctx, migration)
+}
+
+// execer is implemented by both *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// runBatches runs every batch of the migration against ex, stopping at the first failure.
+// Line numbers reported by the server are relative to the batch, so they are shifted to match the migration file.
+func (ss *SQLServer) runBatches(ctx context.Context, ex execer, migr []byte) error {
+	for _, b := range splitBatches(string(migr)) {
+		for i := 0; i < b.count; i++ {
+			if _, err := ex.ExecContext(ctx, b.query); err != nil {
+				if msErr, ok := err.(mssql.Error); ok {
+					message := fmt.Sprintf("migration failed: %s", msErr.Message)
+					if msErr.ProcName != "" {
+						message = fmt.Sprintf("%s (proc name %s)", msErr.Message, msErr.ProcName)
+					}
+					return database.Error{OrigErr: err, Err: message, Query: []byte(b.query), Line: uint(msErr.LineNo) + b.line}
+				}
+				return database.Error{OrigErr: err, Err: "migration failed", Query: []byte(b.query)}
 			}
-			return database.Error{OrigErr: err, Err: message, Query: migr, Line: uint(msErr.LineNo)}
 		}
-		return database.Error{OrigErr: err, Err: "migration failed", Query: migr}
 	}
 
 	return nil
@@ -303,7 +340,7 @@ func (ss *SQLServer) SetVersion(version int, dirty bool) error {
 
 // Version of the current database state
 func (ss *SQLServer) Version() (version int, dirty bool, err error) {
-	query := `SELECT TOP 1 version, dirty FROM ` + ss.getMigrationTable()
+	query := `SELECT TOP 1 version, dirty FROM ` + ss.getMigrationTable() + ` ORDER BY dirty DESC, version DESC`
 	err = ss.conn.QueryRowContext(context.Background(), query).Scan(&version, &dirty)
 	switch {
 	case err == sql.ErrNoRows:
@@ -374,7 +411,17 @@ func (ss *SQLServer) ensureVersionTable() (err error) {
 		WHERE id = object_id(N'` + ss.getMigrationTable() + `')
 			AND OBJECTPROPERTY(id, N'IsUserTable') = 1
 	)
-	CREATE TABLE ` + ss.getMigrationTable() + ` ( version BIGINT PRIMARY KEY NOT NULL, dirty BIT NOT NULL );`
+	CREATE TABLE ` + ss.getMigrationTable() + ` ( version BIGINT PRIMARY KEY NOT NULL, dirty BIT NOT NULL, applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME() );`
+
+	if _, err = ss.conn.ExecContext(context.Background(), query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	// Tables created by earlier releases only track the current version, add the column used by the migration history.
+	// The column is nullable, so that the row of the earlier release keeps a NULL applied_at and can be told apart
+	// from the history until it is backfilled, see SQLServerExtras.LegacyVersion.
+	query = `IF COL_LENGTH(N'` + ss.getMigrationTable() + `', N'applied_at') IS NULL
+	ALTER TABLE ` + ss.getMigrationTable() + ` ADD applied_at DATETIME2 NULL DEFAULT SYSUTCDATETIME();`
 
 	if _, err = ss.conn.ExecContext(context.Background(), query); err != nil {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
@@ -383,6 +430,55 @@ func (ss *SQLServer) ensureVersionTable() (err error) {
 	return nil
 }
 
+// batchSeparator matches a line holding only the GO batch separator, optionally followed by a repeat count.
+// GO is not a T-SQL statement, it is understood by client tools such as sqlcmd and SSMS only.
+var batchSeparator = regexp.MustCompile(`(?i)^\s*GO(?:\s+([0-9]+))?\s*(?:--.*)?$`)
+
+type batch struct {
+	query string
+	// line is the number of lines preceding the batch in the migration
+	line uint
+	// count is the number of times the batch has to be run
+	count int
+}
+
+// splitBatches splits a migration into the batches separated by GO lines.
+// A migration without separators is returned as a single, unmodified batch. Empty batches are dropped.
+// Separators inside block comments or string literals are not detected.
+func splitBatches(migr string) []batch {
+	var batches []batch
+	var current strings.Builder
+	var start uint
+
+	lines := strings.SplitAfter(migr, "\n")
+	for i, line := range lines {
+		m := batchSeparator.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			current.WriteString(line)
+			continue
+		}
+
+		count := 1
+		if m[1] != "" {
+			if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+				count = n
+			}
+		}
+
+		if strings.TrimSpace(current.String()) != "" {
+			batches = append(batches, batch{query: current.String(), line: start, count: count})
+		}
+		current.Reset()
+		start = uint(i + 1)
+	}
+
+	if strings.TrimSpace(current.String()) != "" {
+		batches = append(batches, batch{query: current.String(), line: start, count: 1})
+	}
+
+	return batches
+}
+
 func (ss *SQLServer) getMigrationTable() string {
 	return fmt.Sprintf("[%s].[%s]", ss.config.SchemaName, ss.config.MigrationsTable)
 }
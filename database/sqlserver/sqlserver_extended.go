@@ -0,0 +1,295 @@
+package sqlserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/hashicorp/go-multierror"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func init() {
+	db := SQLServerExtras{
+		SQLServer: &SQLServer{},
+	}
+
+	database.Register("sqlserver", &db)
+}
+
+// errInvalidObjectName is the error number SQL Server reports for a missing table.
+const errInvalidObjectName = 208
+
+// SQLServerExtras implements database.ExtendedDriver, database.TransactionalDriver and database.LegacyHistoryDriver on top of SQLServer.
+// All history reads and writes go through the connection holding the sp_getapplock session lock.
+type SQLServerExtras struct {
+	*SQLServer
+}
+
+// GetAllAppliedMigrations retrieves all versions recorded in the migrations table in descending order.
+func (ss *SQLServerExtras) GetAllAppliedMigrations() ([]int, error) {
+	query := `SELECT version FROM ` + ss.getMigrationTable() + ` ORDER BY version DESC`
+
+	rows, err := ss.conn.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	var appliedMigrations []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, &database.Error{
+				OrigErr: err,
+				Query:   []byte(query),
+			}
+		}
+		appliedMigrations = append(appliedMigrations, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return appliedMigrations, nil
+}
+
+// AddDirtyMigration inserts a dirty row for the given version into the migrations table.
+func (ss *SQLServerExtras) AddDirtyMigration(version uint) error {
+	return ss.inTx(func(tx *sql.Tx) error {
+		return ss.insertMigration(tx, version, true)
+	})
+}
+
+// UpdateMigrationDirtyFlag sets or clears the dirty flag of the given version and refreshes its applied_at timestamp.
+func (ss *SQLServerExtras) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	return ss.inTx(func(tx *sql.Tx) error {
+		var dirtyBit int
+		if dirty {
+			dirtyBit = 1
+		}
+
+		query := `UPDATE ` + ss.getMigrationTable() + ` SET dirty = @p1, applied_at = SYSUTCDATETIME() WHERE version = @p2`
+		if _, err := tx.Exec(query, dirtyBit, int64(version)); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		return nil
+	})
+}
+
+// IsMigrationApplied checks if a row for the given version exists in the migrations table.
+// A missing migrations table is reported as not applied.
+func (ss *SQLServerExtras) IsMigrationApplied(version uint) (bool, error) {
+	query := `SELECT COUNT(1) FROM ` + ss.getMigrationTable() + ` WHERE version = @p1`
+
+	var count int
+	if err := ss.conn.QueryRowContext(context.Background(), query, int64(version)).Scan(&count); err != nil {
+		if isInvalidObjectName(err) {
+			return false, nil
+		}
+
+		return false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return count > 0, nil
+}
+
+// RemoveMigration deletes the row of the given version from the migrations table.
+func (ss *SQLServerExtras) RemoveMigration(version uint) error {
+	return ss.inTx(func(tx *sql.Tx) error {
+		return ss.deleteMigration(tx, version)
+	})
+}
+
+// IsDatabaseDirty returns the first version found with the dirty flag set.
+// A missing migrations table is reported as a clean database.
+func (ss *SQLServerExtras) IsDatabaseDirty() (int, bool, error) {
+	query := `SELECT TOP 1 version FROM ` + ss.getMigrationTable() + ` WHERE dirty = 1`
+
+	var version int
+	if err := ss.conn.QueryRowContext(context.Background(), query).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidObjectName(err) {
+			return 0, false, nil
+		}
+
+		return 0, false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return version, true, nil
+}
+
+// LegacyVersion returns the version of the row written by an earlier release, which only kept the current version.
+// Such a row has no applied_at, as every row written since has one.
+func (ss *SQLServerExtras) LegacyVersion() (int, bool, error) {
+	query := `SELECT TOP 1 version FROM ` + ss.getMigrationTable() + ` WHERE applied_at IS NULL ORDER BY version DESC`
+
+	var version int
+	if err := ss.conn.QueryRowContext(context.Background(), query).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidObjectName(err) {
+			return database.NilVersion, false, nil
+		}
+
+		return database.NilVersion, false, &database.Error{
+			OrigErr: err,
+			Query:   []byte(query),
+		}
+	}
+
+	return version, true, nil
+}
+
+// BackfillHistory records the versions as cleanly applied and sets applied_at on the rows of the earlier release,
+// keeping their dirty flag, in a single transaction.
+func (ss *SQLServerExtras) BackfillHistory(versions []uint) error {
+	return ss.inTx(func(tx *sql.Tx) error {
+		query := `IF NOT EXISTS (SELECT 1 FROM ` + ss.getMigrationTable() + ` WHERE version = @p1)
+		INSERT INTO ` + ss.getMigrationTable() + ` (version, dirty, applied_at) VALUES (@p1, 0, SYSUTCDATETIME())`
+		for _, version := range versions {
+			if _, err := tx.Exec(query, int64(version)); err != nil {
+				return &database.Error{OrigErr: err, Query: []byte(query)}
+			}
+		}
+
+		query = `UPDATE ` + ss.getMigrationTable() + ` SET applied_at = SYSUTCDATETIME() WHERE applied_at IS NULL`
+		if _, err := tx.Exec(query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		return nil
+	})
+}
+
+// RunWithHistory runs all batches of the migration and the history write in a single transaction,
+// so a failing batch leaves neither a partial migration nor a dirty row behind.
+// Statements that T-SQL doesn't allow in a transaction (e.g. ALTER DATABASE, full-text index DDL) need
+// x-no-tx-wrap, in which case the migration is tracked through the dirty flag instead.
+func (ss *SQLServerExtras) RunWithHistory(version uint, up bool, migration io.Reader) error {
+	return ss.RunWithHistoryContext(context.Background(), version, up, migration)
+}
+
+// RunWithHistoryContext runs the migration like RunWithHistory, rolling it back once ctx is done.
+func (ss *SQLServerExtras) RunWithHistoryContext(ctx context.Context, version uint, up bool, migration io.Reader) error {
+	if ss.config.NoTxWrap {
+		return ss.runWithDirtyFlag(ctx, version, up, migration)
+	}
+
+	migr, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	return ss.inTx(func(tx *sql.Tx) error {
+		if err := ss.runBatches(ctx, tx, migr); err != nil {
+			return err
+		}
+
+		if up {
+			return ss.insertMigration(tx, version, false)
+		}
+
+		return ss.deleteMigration(tx, version)
+	})
+}
+
+// RunFunc runs a migration implemented in Go in a transaction on the locked connection.
+func (ss *SQLServerExtras) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return ss.inTx(func(tx *sql.Tx) error {
+		if err := fn(ctx, tx); err != nil {
+			return &database.Error{OrigErr: err, Err: "migration failed"}
+		}
+		return nil
+	})
+}
+
+// runWithDirtyFlag marks the migration dirty, runs it outside of a transaction and then records the result.
+func (ss *SQLServerExtras) runWithDirtyFlag(ctx context.Context, version uint, up bool, migration io.Reader) error {
+	if up {
+		if err := ss.AddDirtyMigration(version); err != nil {
+			return err
+		}
+	} else {
+		if err := ss.UpdateMigrationDirtyFlag(version, true); err != nil {
+			return err
+		}
+	}
+
+	if err := ss.RunContext(ctx, migration); err != nil {
+		return err
+	}
+
+	if up {
+		return ss.UpdateMigrationDirtyFlag(version, false)
+	}
+
+	return ss.RemoveMigration(version)
+}
+
+func (ss *SQLServerExtras) insertMigration(tx *sql.Tx, version uint, dirty bool) error {
+	var dirtyBit int
+	if dirty {
+		dirtyBit = 1
+	}
+
+	query := `INSERT INTO ` + ss.getMigrationTable() + ` (version, dirty, applied_at) VALUES (@p1, @p2, SYSUTCDATETIME())`
+	if _, err := tx.Exec(query, int64(version), dirtyBit); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (ss *SQLServerExtras) deleteMigration(tx *sql.Tx, version uint) error {
+	query := `DELETE FROM ` + ss.getMigrationTable() + ` WHERE version = @p1`
+	if _, err := tx.Exec(query, int64(version)); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// inTx runs f in a transaction on the locked connection, committing on success and rolling back otherwise.
+func (ss *SQLServerExtras) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := ss.conn.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction start failed"}
+	}
+
+	if err := f(tx); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			err = multierror.Append(err, errRollback)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction commit failed"}
+	}
+
+	return nil
+}
+
+func isInvalidObjectName(err error) bool {
+	var msErr mssql.Error
+	return errors.As(err, &msErr) && msErr.Number == errInvalidObjectName
+}
@@ -92,6 +92,10 @@ func Test(t *testing.T) {
 	t.Run("testMsiTrue", testMsiTrue)
 	t.Run("testOpenWithPasswordAndMSI", testOpenWithPasswordAndMSI)
 	t.Run("testMsiFalse", testMsiFalse)
+	t.Run("testExtended", testExtended)
+	t.Run("testRunWithHistory", testRunWithHistory)
+	t.Run("testLegacyHistory", testLegacyHistory)
+	t.Run("testMigrationsSchema", testMigrationsSchema)
 
 	t.Cleanup(func() {
 		for _, spec := range specs {
@@ -182,7 +186,7 @@ func testMultiStatement(t *testing.T) {
 
 		// make sure second table exists
 		var exists int
-		if err := d.(*SQLServer).conn.QueryRowContext(context.Background(), "SELECT COUNT(1) FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT schema_name()) AND table_catalog = (SELECT db_name())").Scan(&exists); err != nil {
+		if err := d.(*SQLServerExtras).conn.QueryRowContext(context.Background(), "SELECT COUNT(1) FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT schema_name()) AND table_catalog = (SELECT db_name())").Scan(&exists); err != nil {
 			t.Fatal(err)
 		}
 		if exists != 1 {
@@ -239,7 +243,7 @@ func testLockWorks(t *testing.T) {
 		}
 		dt.Test(t, d, []byte("SELECT 1"))
 
-		ms := d.(*SQLServer)
+		ms := d.(*SQLServerExtras)
 
 		err = ms.Lock()
 		if err != nil {
@@ -327,3 +331,203 @@ func testMsiFalse(t *testing.T) {
 		}
 	})
 }
+
+func testExtended(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		SkipIfUnsupportedArch(t, c)
+		ip, port, err := c.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := msConnectionString(ip, port)
+		p := &SQLServer{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		dt.TestExtended(t, d.(*SQLServerExtras))
+	})
+}
+
+func testRunWithHistory(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		SkipIfUnsupportedArch(t, c)
+		ip, port, err := c.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := msConnectionString(ip, port)
+		p := &SQLServer{}
+		d, err := p.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		ms := d.(*SQLServerExtras)
+
+		// a multi-batch script is recorded as applied together with its body
+		migr := "CREATE TABLE foo (foo text);\nGO\nCREATE VIEW foo_view AS SELECT foo FROM foo;\nGO\n"
+		if err := ms.RunWithHistory(1, true, strings.NewReader(migr)); err != nil {
+			t.Fatal(err)
+		}
+		if isApplied, err := ms.IsMigrationApplied(1); err != nil {
+			t.Fatal(err)
+		} else if !isApplied {
+			t.Fatal("expected version 1 to be applied")
+		}
+
+		// a failing batch rolls back the previous batches and the history write
+		migr = "CREATE TABLE bar (bar text);\nGO\nCREATE TABLEE baz (baz text);\n"
+		if err := ms.RunWithHistory(2, true, strings.NewReader(migr)); err == nil {
+			t.Fatal("expected err but got nil")
+		}
+		if isApplied, err := ms.IsMigrationApplied(2); err != nil {
+			t.Fatal(err)
+		} else if isApplied {
+			t.Fatal("expected version 2 not to be applied")
+		}
+		var exists int
+		if err := ms.conn.QueryRowContext(context.Background(), "SELECT COUNT(1) FROM information_schema.tables WHERE table_name = 'bar'").Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if exists != 0 {
+			t.Fatal("expected table bar to be rolled back")
+		}
+	})
+}
+
+func testLegacyHistory(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		SkipIfUnsupportedArch(t, c)
+		ip, port, err := c.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the migrations table of an earlier release at version 3
+		db, err := sql.Open("sqlserver", msConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range []string{
+			"CREATE TABLE legacy_migrations (version BIGINT PRIMARY KEY NOT NULL, dirty BIT NOT NULL)",
+			"INSERT INTO legacy_migrations (version, dirty) VALUES (3, 0)",
+		} {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		p := &SQLServer{}
+		d, err := p.Open(msConnectionString(ip, port) + "&x-migrations-table=legacy_migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		ms := d.(*SQLServerExtras)
+		dt.TestLegacyHistory(t, ms, false)
+
+		// the first up after the upgrade only runs the migrations above version 3
+		if err := ms.RunWithHistory(4, true, strings.NewReader("SELECT 1")); err != nil {
+			t.Fatal(err)
+		}
+		applied, err := ms.GetAllAppliedMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(applied) != 4 || applied[0] != 4 {
+			t.Fatalf("expected versions 4 to 1 to be applied, got %v", applied)
+		}
+	})
+}
+
+func testMigrationsSchema(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, c dktest.ContainerInfo) {
+		SkipIfUnsupportedArch(t, c)
+		ip, port, err := c.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("sqlserver", msConnectionString(ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("CREATE SCHEMA migrations"); err != nil {
+			t.Fatal(err)
+		}
+
+		p := &SQLServer{}
+		d, err := p.Open(msConnectionString(ip, port) + "&x-migrations-schema=migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := d.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		dt.TestExtended(t, d.(*SQLServerExtras))
+
+		var count int
+		if err := db.QueryRow("SELECT COUNT(1) FROM information_schema.tables WHERE table_schema = 'migrations' AND table_name = 'schema_migrations'").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatal("expected the migrations table to be in the migrations schema")
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestSplitBatches(t *testing.T) {
+	testCases := []struct {
+		name     string
+		migr     string
+		expected []batch
+	}{
+		{name: "no separator", migr: "SELECT 1", expected: []batch{{query: "SELECT 1", line: 0, count: 1}}},
+		{name: "empty", migr: "", expected: nil},
+		{name: "two batches", migr: "SELECT 1\nGO\nSELECT 2\n", expected: []batch{{query: "SELECT 1\n", line: 0, count: 1}, {query: "SELECT 2\n", line: 2, count: 1}}},
+		{name: "lower case with crlf", migr: "SELECT 1\r\ngo\r\nSELECT 2", expected: []batch{{query: "SELECT 1\r\n", line: 0, count: 1}, {query: "SELECT 2", line: 2, count: 1}}},
+		{name: "repeat count and comment", migr: "INSERT INTO t DEFAULT VALUES\nGO 3 -- three rows\n", expected: []batch{{query: "INSERT INTO t DEFAULT VALUES\n", line: 0, count: 3}}},
+		{name: "empty batches dropped", migr: "GO\n\nGO\nSELECT 1\nGO", expected: []batch{{query: "SELECT 1\n", line: 3, count: 1}}},
+		{name: "GO inside a statement", migr: "SELECT 'GO' AS GOTO\n", expected: []batch{{query: "SELECT 'GO' AS GOTO\n", line: 0, count: 1}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := splitBatches(tc.migr)
+			if len(batches) != len(tc.expected) {
+				t.Fatalf("expected %d batches, got %d: %#v", len(tc.expected), len(batches), batches)
+			}
+			for i := range batches {
+				if batches[i] != tc.expected[i] {
+					t.Errorf("batch %d: expected %#v, got %#v", i, tc.expected[i], batches[i])
+				}
+			}
+		})
+	}
+}
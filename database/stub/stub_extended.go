@@ -0,0 +1,162 @@
+package stub
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+func init() {
+	database.Register("stub-extended", &ExtendedStub{})
+}
+
+// ExtendedStub is an in-memory database.ExtendedDriver, database.RepeatableDriver, database.FuncDriver,
+// database.ContextDriver, database.DirectiveDriver and database.LegacyHistoryDriver.
+type ExtendedStub struct {
+	Stub
+
+	// Applied holds the dirty flag of every applied version.
+	Applied map[uint]bool
+
+	// Repeatables holds the checksum of every applied repeatable migration.
+	Repeatables map[string]string
+
+	// Legacy, if set, is the version of the row left by an earlier release that only kept the current version.
+	// The row itself is in Applied.
+	Legacy *uint
+}
+
+func (s *ExtendedStub) Open(url string) (database.Driver, error) {
+	return &ExtendedStub{
+		Stub: Stub{
+			Url:               url,
+			CurrentVersion:    database.NilVersion,
+			MigrationSequence: make([]string, 0),
+			Config:            &Config{},
+		},
+		Applied:     make(map[uint]bool),
+		Repeatables: make(map[string]string),
+	}, nil
+}
+
+// WithExtendedInstance returns an empty ExtendedStub.
+func WithExtendedInstance(instance interface{}, config *Config) (database.Driver, error) {
+	return &ExtendedStub{
+		Stub: Stub{
+			Instance:          instance,
+			CurrentVersion:    database.NilVersion,
+			MigrationSequence: make([]string, 0),
+			Config:            config,
+		},
+		Applied:     make(map[uint]bool),
+		Repeatables: make(map[string]string),
+	}, nil
+}
+
+func (s *ExtendedStub) GetAllAppliedMigrations() ([]int, error) {
+	applied := make([]int, 0, len(s.Applied))
+	for v := range s.Applied {
+		applied = append(applied, int(v))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(applied)))
+	return applied, nil
+}
+
+func (s *ExtendedStub) IsMigrationApplied(version uint) (bool, error) {
+	_, ok := s.Applied[version]
+	return ok, nil
+}
+
+func (s *ExtendedStub) IsDatabaseDirty() (int, bool, error) {
+	applied, _ := s.GetAllAppliedMigrations()
+	for i := len(applied) - 1; i >= 0; i-- {
+		if s.Applied[uint(applied[i])] {
+			return applied[i], true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (s *ExtendedStub) AddDirtyMigration(version uint) error {
+	if _, dup := s.Applied[version]; dup {
+		return fmt.Errorf("migration %d already recorded", version)
+	}
+	s.Applied[version] = true
+	return nil
+}
+
+func (s *ExtendedStub) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	s.Applied[version] = dirty
+	return nil
+}
+
+func (s *ExtendedStub) RemoveMigration(version uint) error {
+	delete(s.Applied, version)
+	return nil
+}
+
+func (s *ExtendedStub) LegacyVersion() (int, bool, error) {
+	if s.Legacy == nil {
+		return database.NilVersion, false, nil
+	}
+	return int(*s.Legacy), true, nil
+}
+
+func (s *ExtendedStub) BackfillHistory(versions []uint) error {
+	for _, v := range versions {
+		if _, ok := s.Applied[v]; !ok {
+			s.Applied[v] = false
+		}
+	}
+	s.Legacy = nil
+	return nil
+}
+
+func (s *ExtendedStub) GetAllAppliedRepeatables() (map[string]string, error) {
+	applied := make(map[string]string, len(s.Repeatables))
+	for name, checksum := range s.Repeatables {
+		applied[name] = checksum
+	}
+	return applied, nil
+}
+
+func (s *ExtendedStub) SetRepeatableApplied(name string, checksum string) error {
+	s.Repeatables[name] = checksum
+	return nil
+}
+
+// FuncMigration is appended to MigrationSequence for every migration run through RunFunc.
+const FuncMigration = "FUNC"
+
+// RunFunc calls fn without a transaction.
+func (s *ExtendedStub) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	if err := fn(ctx, nil); err != nil {
+		return err
+	}
+	s.MigrationSequence = append(s.MigrationSequence, FuncMigration)
+	return nil
+}
+
+// RunContext runs the migration like Run unless ctx is already done.
+func (s *ExtendedStub) RunContext(ctx context.Context, migration io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Run(migration)
+}
+
+// RunWithDirectives runs the migration like RunContext, there are no transactions to leave out.
+func (s *ExtendedStub) RunWithDirectives(ctx context.Context, migration io.Reader, directives source.Directives) error {
+	return s.RunContext(ctx, migration)
+}
+
+func (s *ExtendedStub) Drop() error {
+	s.Applied = make(map[uint]bool)
+	s.Repeatables = make(map[string]string)
+	return s.Stub.Drop()
+}
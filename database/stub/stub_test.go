@@ -41,3 +41,12 @@ func TestMigrate(t *testing.T) {
 
 	dt.TestMigrate(t, m)
 }
+
+func TestExtended(t *testing.T) {
+	s := &ExtendedStub{}
+	d, err := s.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dt.TestExtended(t, d.(*ExtendedStub))
+}
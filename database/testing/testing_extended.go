@@ -0,0 +1,177 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/abramad-labs/histomigrate/database"
+)
+
+// TestExtended runs tests against database implementations of the ExtendedDriver interface.
+// The history table is expected to be empty when TestExtended is called.
+func TestExtended(t *testing.T, d database.ExtendedDriver) {
+	TestEmptyHistory(t, d) // test first
+	TestAddDirtyMigration(t, d)
+	TestUpdateMigrationDirtyFlag(t, d)
+	TestOutOfOrderMigrations(t, d)
+	TestRemoveMigration(t, d)
+}
+
+func TestEmptyHistory(t *testing.T, d database.ExtendedDriver) {
+	applied, err := d.GetAllAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("GetAllAppliedMigrations: expected no applied migrations, got %v", applied)
+	}
+
+	if _, dirty, err := d.IsDatabaseDirty(); err != nil {
+		t.Fatal(err)
+	} else if dirty {
+		t.Fatal("IsDatabaseDirty: expected database not to be dirty")
+	}
+}
+
+func TestAddDirtyMigration(t *testing.T, d database.ExtendedDriver) {
+	if err := d.AddDirtyMigration(2); err != nil {
+		t.Fatal(err)
+	}
+
+	isApplied, err := d.IsMigrationApplied(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isApplied {
+		t.Fatal("IsMigrationApplied: expected version 2 to be recorded")
+	}
+
+	v, dirty, err := d.IsDatabaseDirty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dirty || v != 2 {
+		t.Fatalf("IsDatabaseDirty: expected version 2 to be dirty, got %v (dirty: %v)", v, dirty)
+	}
+
+	// a second attempt to record the same version must not succeed
+	if err := d.AddDirtyMigration(2); err == nil {
+		t.Fatal("AddDirtyMigration: expected err not to be nil for a duplicate version")
+	}
+}
+
+func TestUpdateMigrationDirtyFlag(t *testing.T, d database.ExtendedDriver) {
+	if err := d.UpdateMigrationDirtyFlag(2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, dirty, err := d.IsDatabaseDirty(); err != nil {
+		t.Fatal(err)
+	} else if dirty {
+		t.Fatal("IsDatabaseDirty: expected database not to be dirty")
+	}
+
+	if err := d.UpdateMigrationDirtyFlag(2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, dirty, err := d.IsDatabaseDirty(); err != nil {
+		t.Fatal(err)
+	} else if !dirty || v != 2 {
+		t.Fatalf("IsDatabaseDirty: expected version 2 to be dirty, got %v (dirty: %v)", v, dirty)
+	}
+
+	if err := d.UpdateMigrationDirtyFlag(2, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutOfOrderMigrations(t *testing.T, d database.ExtendedDriver) {
+	for _, v := range []uint{3, 1} {
+		if err := d.AddDirtyMigration(v); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.UpdateMigrationDirtyFlag(v, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied, err := d.GetAllAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{3, 2, 1}
+	if len(applied) != len(expected) {
+		t.Fatalf("GetAllAppliedMigrations: expected %v, got %v", expected, applied)
+	}
+	for i := range expected {
+		if applied[i] != expected[i] {
+			t.Fatalf("GetAllAppliedMigrations: expected %v, got %v", expected, applied)
+		}
+	}
+}
+
+func TestRemoveMigration(t *testing.T, d database.ExtendedDriver) {
+	for _, v := range []uint{1, 2, 3} {
+		if err := d.RemoveMigration(v); err != nil {
+			t.Fatal(err)
+		}
+
+		isApplied, err := d.IsMigrationApplied(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isApplied {
+			t.Fatalf("IsMigrationApplied: expected version %v to be removed", v)
+		}
+	}
+
+	// removing a version that was never recorded is not an error, Force relies on it
+	if err := d.RemoveMigration(4); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLegacyHistory tests the upgrade of a migrations table written by an earlier release.
+// The table is expected to hold only the row of version 3, dirty or not as given.
+func TestLegacyHistory(t *testing.T, d database.LegacyHistoryDriver, dirty bool) {
+	legacy, ok, err := d.LegacyVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || legacy != 3 {
+		t.Fatalf("LegacyVersion: expected version 3, got %v (legacy: %v)", legacy, ok)
+	}
+
+	if err := d.BackfillHistory([]uint{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := d.LegacyVersion(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("LegacyVersion: expected the legacy row to be converted")
+	}
+
+	applied, err := d.GetAllAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{3, 2, 1}
+	if len(applied) != len(expected) {
+		t.Fatalf("GetAllAppliedMigrations: expected %v, got %v", expected, applied)
+	}
+	for i := range expected {
+		if applied[i] != expected[i] {
+			t.Fatalf("GetAllAppliedMigrations: expected %v, got %v", expected, applied)
+		}
+	}
+
+	v, isDirty, err := d.IsDatabaseDirty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isDirty != dirty || dirty && v != 3 {
+		t.Fatalf("IsDatabaseDirty: expected the dirty flag of version 3 (%v) to be kept, got %v (dirty: %v)", dirty, v, isDirty)
+	}
+}
@@ -34,13 +34,6 @@ var (
 	ErrMaxRetriesExceeded = errors.New("max retries exceeded")
 )
 
-func init() {
-	db := YugabyteDB{}
-	database.Register("yugabyte", &db)
-	database.Register("yugabytedb", &db)
-	database.Register("ysql", &db)
-}
-
 type Config struct {
 	MigrationsTable     string
 	LockTable           string
@@ -116,7 +109,9 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 		return nil, err
 	}
 
-	return px, nil
+	return &YugabyteDBExtras{
+		YugabyteDB: px,
+	}, nil
 }
 
 func (c *YugabyteDB) Open(dbURL string) (database.Driver, error) {
@@ -280,7 +275,7 @@ func (c *YugabyteDB) SetVersion(version int, dirty bool) error {
 		// empty schema version for failed down migration on the first migration
 		// See: https://github.com/golang-migrate/migrate/issues/330
 		if version >= 0 || (version == database.NilVersion && dirty) {
-			if _, err := tx.Exec(`INSERT INTO "`+c.config.MigrationsTable+`" (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			if _, err := tx.Exec(`INSERT INTO "`+c.config.MigrationsTable+`" (version, dirty, applied_at) VALUES ($1, $2, now())`, version, dirty); err != nil {
 				return err
 			}
 		}
@@ -290,7 +285,7 @@ func (c *YugabyteDB) SetVersion(version int, dirty bool) error {
 }
 
 func (c *YugabyteDB) Version() (version int, dirty bool, err error) {
-	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `" LIMIT 1`
+	query := `SELECT version, dirty FROM "` + c.config.MigrationsTable + `" ORDER BY dirty DESC, version DESC LIMIT 1`
 	err = c.db.QueryRow(query).Scan(&version, &dirty)
 
 	switch {
@@ -375,17 +370,43 @@ func (c *YugabyteDB) ensureVersionTable() (err error) {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
 	}
 	if count == 1 {
-		return nil
+		return c.ensureAppliedAt()
 	}
 
 	// if not, create the empty migration table
-	query = `CREATE TABLE "` + c.config.MigrationsTable + `" (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL)`
+	query = `CREATE TABLE "` + c.config.MigrationsTable + `" (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL, applied_at TIMESTAMPTZ DEFAULT now())`
 	if _, err := c.db.Exec(query); err != nil {
 		return &database.Error{OrigErr: err, Query: []byte(query)}
 	}
 	return nil
 }
 
+// ensureAppliedAt adds the applied_at column to tables created by earlier releases. It is added without
+// a default first, so that their rows keep a NULL applied_at and are found by LegacyVersion.
+// Every ALTER is a schema change, so the column is only altered if it or its default is missing.
+func (c *YugabyteDB) ensureAppliedAt() error {
+	var columnDefault sql.NullString
+	query := `SELECT column_default FROM information_schema.columns WHERE table_name = $1 AND table_schema = (SELECT current_schema()) AND column_name = 'applied_at'`
+	err := c.db.QueryRow(query, c.config.MigrationsTable).Scan(&columnDefault)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	var queries []string
+	if errors.Is(err, sql.ErrNoRows) {
+		queries = append(queries, `ALTER TABLE "`+c.config.MigrationsTable+`" ADD COLUMN IF NOT EXISTS applied_at TIMESTAMPTZ`)
+	}
+	if !columnDefault.Valid {
+		queries = append(queries, `ALTER TABLE "`+c.config.MigrationsTable+`" ALTER COLUMN applied_at SET DEFAULT now()`)
+	}
+	for _, query := range queries {
+		if _, err := c.db.Exec(query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+	return nil
+}
+
 func (c *YugabyteDB) ensureLockTable() error {
 	// check if lock table exists
 	var count int
@@ -410,8 +431,21 @@ func (c *YugabyteDB) doTxWithRetry(
 	ctx context.Context,
 	txOpts *sql.TxOptions,
 	fn func(tx *sql.Tx) error,
+) error {
+	return c.doTxWithCommitRetry(ctx, txOpts, func(tx *sql.Tx, _ bool) error {
+		return fn(tx)
+	})
+}
+
+// doTxWithCommitRetry runs fn like doTxWithRetry, telling it whether the commit of an earlier attempt
+// failed without a definite outcome, i.e. whether the writes of that attempt may be in place.
+func (c *YugabyteDB) doTxWithCommitRetry(
+	ctx context.Context,
+	txOpts *sql.TxOptions,
+	fn func(tx *sql.Tx, ambiguous bool) error,
 ) error {
 	backOff := c.newBackoff(ctx)
+	ambiguous := false
 
 	return backoff.Retry(func() error {
 		tx, err := c.db.BeginTx(ctx, txOpts)
@@ -423,7 +457,7 @@ func (c *YugabyteDB) doTxWithRetry(
 		//nolint:errcheck
 		defer tx.Rollback()
 
-		if err := fn(tx); err != nil {
+		if err := fn(tx, ambiguous); err != nil {
 			if errIsRetryable(err) {
 				return err
 			}
@@ -433,6 +467,7 @@ func (c *YugabyteDB) doTxWithRetry(
 
 		if err := tx.Commit(); err != nil {
 			if errIsRetryable(err) {
+				ambiguous = ambiguous || errIsAmbiguousCommit(err)
 				return err
 			}
 
@@ -464,16 +499,37 @@ func (c *YugabyteDB) newBackoff(ctx context.Context) backoff.BackOff {
 }
 
 func errIsRetryable(err error) bool {
-	var pgErr *pgconn.PgError
-	if !errors.As(err, &pgErr) {
+	code, ok := errCode(err)
+	if !ok {
 		return false
 	}
 
 	// Assume that it's safe to retry 08006 and XX000 because we check for lock existence
 	// before creating and lock ID is primary key. Version field in migrations table is primary key too
-	// and delete all versions is an idempotent operation.
-	return pgErr.Code == pgerrcode.SerializationFailure || // optimistic locking conflict
-		pgErr.Code == pgerrcode.DeadlockDetected ||
-		pgErr.Code == pgerrcode.ConnectionFailure || // node down, need to reconnect
-		pgErr.Code == pgerrcode.InternalError // may happen during HA
+	// and delete all versions is an idempotent operation. History writes are idempotent as well, see YugabyteDBExtras.
+	return code == pgerrcode.SerializationFailure || // optimistic locking conflict
+		code == pgerrcode.DeadlockDetected ||
+		code == pgerrcode.ConnectionFailure || // node down, need to reconnect
+		code == pgerrcode.InternalError // may happen during HA
+}
+
+// errIsAmbiguousCommit reports whether a failed commit may have been applied nonetheless,
+// as opposed to conflicts, which abort the transaction.
+func errIsAmbiguousCommit(err error) bool {
+	code, ok := errCode(err)
+	return ok && (code == pgerrcode.ConnectionFailure || code == pgerrcode.InternalError)
+}
+
+func errCode(err error) (string, bool) {
+	// Open connects through lib/pq, instances passed to WithInstance may use pgx
+	var pgErr *pgconn.PgError
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code, true
+	case errors.As(err, &pqErr):
+		return string(pqErr.Code), true
+	default:
+		return "", false
+	}
 }
@@ -0,0 +1,205 @@
+package yugabytedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/hashicorp/go-multierror"
+	"github.com/lib/pq"
+)
+
+func init() {
+	db := YugabyteDBExtras{
+		YugabyteDB: &YugabyteDB{},
+	}
+
+	database.Register("yugabyte", &db)
+	database.Register("yugabytedb", &db)
+	database.Register("ysql", &db)
+}
+
+// ErrMigrationExists is returned by AddDirtyMigration if the version is already recorded.
+var ErrMigrationExists = errors.New("migration already recorded")
+
+// YugabyteDBExtras implements database.ExtendedDriver on top of YugabyteDB.
+// History writes run through doTxWithCommitRetry and are safe to repeat: a retry after a commit whose
+// outcome was lost (e.g. the node went down) finds the row it wrote and treats it as its own, while a retry
+// after a serialization failure doesn't, as the row may be the one of a concurrent writer.
+// It also implements database.LegacyHistoryDriver for migrations tables of earlier releases.
+type YugabyteDBExtras struct {
+	*YugabyteDB
+}
+
+// GetAllAppliedMigrations retrieves all versions recorded in the migrations table in descending order.
+func (c *YugabyteDBExtras) GetAllAppliedMigrations() (appliedMigrations []int, err error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `" ORDER BY version DESC`
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+	}()
+
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+		appliedMigrations = append(appliedMigrations, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return appliedMigrations, nil
+}
+
+// AddDirtyMigration inserts a dirty row for the given version.
+// It fails with ErrMigrationExists if the version is already recorded, unless the row was written by an
+// earlier attempt of this call whose commit was lost.
+func (c *YugabyteDBExtras) AddDirtyMigration(version uint) error {
+	query := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty, applied_at) VALUES ($1, true, now()) ON CONFLICT (version) DO NOTHING`
+
+	err := c.executeTx(func(tx *sql.Tx, ambiguous bool) error {
+		return insertDirty(tx, query, version, ambiguous)
+	})
+	return historyError(err, query)
+}
+
+// UpdateMigrationDirtyFlag sets or clears the dirty flag of the given version and refreshes its applied_at timestamp.
+func (c *YugabyteDBExtras) UpdateMigrationDirtyFlag(version uint, dirty bool) error {
+	query := `UPDATE "` + c.config.MigrationsTable + `" SET dirty = $1, applied_at = now() WHERE version = $2`
+
+	err := c.executeTx(func(tx *sql.Tx, _ bool) error {
+		_, err := tx.Exec(query, dirty, int64(version))
+		return err
+	})
+	return historyError(err, query)
+}
+
+// IsMigrationApplied checks if a row for the given version exists in the migrations table.
+// A missing migrations table is reported as not applied.
+func (c *YugabyteDBExtras) IsMigrationApplied(version uint) (bool, error) {
+	query := `SELECT COUNT(1) > 0 FROM "` + c.config.MigrationsTable + `" WHERE version = $1`
+
+	var isApplied bool
+	if err := c.db.QueryRow(query, int64(version)).Scan(&isApplied); err != nil {
+		if isUndefinedTable(err) {
+			return false, nil
+		}
+		return false, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return isApplied, nil
+}
+
+// RemoveMigration deletes the row of the given version from the migrations table.
+// Deleting a version that isn't recorded is not an error, which also makes retries harmless.
+func (c *YugabyteDBExtras) RemoveMigration(version uint) error {
+	query := `DELETE FROM "` + c.config.MigrationsTable + `" WHERE version = $1`
+
+	err := c.executeTx(func(tx *sql.Tx, _ bool) error {
+		_, err := tx.Exec(query, int64(version))
+		return err
+	})
+	return historyError(err, query)
+}
+
+// IsDatabaseDirty returns the first version found with the dirty flag set.
+// A missing migrations table is reported as a clean database.
+func (c *YugabyteDBExtras) IsDatabaseDirty() (int, bool, error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `" WHERE dirty = true LIMIT 1`
+
+	var version int
+	if err := c.db.QueryRow(query).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isUndefinedTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return version, true, nil
+}
+
+// LegacyVersion returns the version of the row written by an earlier release, which only kept the current version.
+// Such a row has no applied_at, as every row written since has one.
+func (c *YugabyteDBExtras) LegacyVersion() (int, bool, error) {
+	query := `SELECT version FROM "` + c.config.MigrationsTable + `" WHERE applied_at IS NULL ORDER BY version DESC LIMIT 1`
+
+	var version int
+	if err := c.db.QueryRow(query).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isUndefinedTable(err) {
+			return database.NilVersion, false, nil
+		}
+		return database.NilVersion, false, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return version, true, nil
+}
+
+// BackfillHistory records the versions as cleanly applied and sets applied_at on the rows of the earlier release,
+// keeping their dirty flag, in a single transaction.
+func (c *YugabyteDBExtras) BackfillHistory(versions []uint) error {
+	insert := `INSERT INTO "` + c.config.MigrationsTable + `" (version, dirty, applied_at) VALUES ($1, false, now()) ON CONFLICT (version) DO NOTHING`
+	update := `UPDATE "` + c.config.MigrationsTable + `" SET applied_at = now() WHERE applied_at IS NULL`
+
+	query := insert
+	err := c.executeTx(func(tx *sql.Tx, _ bool) error {
+		query = insert
+		for _, version := range versions {
+			if _, err := tx.Exec(query, int64(version)); err != nil {
+				return err
+			}
+		}
+		query = update
+		_, err := tx.Exec(query)
+		return err
+	})
+	return historyError(err, query)
+}
+
+// executeTx runs f in a serializable transaction through doTxWithCommitRetry.
+// It must return the errors of the database as they are, so that the retryable ones are recognized.
+func (c *YugabyteDBExtras) executeTx(f func(tx *sql.Tx, ambiguous bool) error) error {
+	return c.doTxWithCommitRetry(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}, f)
+}
+
+// insertDirty runs query, which inserts a dirty row for version unless there is one.
+// An existing row is only taken for the one of this call if the commit of an earlier attempt was lost:
+// an attempt repeated after a serialization failure finds the row of a concurrent writer.
+func insertDirty(tx *sql.Tx, query string, version uint, ambiguous bool) error {
+	res, err := tx.Exec(query, int64(version))
+	if err != nil {
+		return err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 && !ambiguous {
+		return fmt.Errorf("%w: %d", ErrMigrationExists, version)
+	}
+	return nil
+}
+
+// historyError wraps the error of a history write along with its query.
+func historyError(err error, query string) error {
+	if err == nil || errors.Is(err, ErrMigrationExists) {
+		return err
+	}
+	return &database.Error{OrigErr: err, Query: []byte(query)}
+}
+
+func isUndefinedTable(err error) bool {
+	// 42P01 is "UndefinedTableError" in YugabyteDB
+	// https://github.com/yugabyte/yugabyte-db/blob/9c6b8e6beb56eed8eeb357178c0c6b837eb49896/src/postgres/src/backend/utils/errcodes.txt#L366
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
@@ -5,6 +5,7 @@ package yugabytedb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -14,7 +15,8 @@ import (
 	"github.com/abramad-labs/histomigrate"
 	"github.com/dhui/dktest"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgconn"
+	"github.com/lib/pq"
 
 	dt "github.com/abramad-labs/histomigrate/database/testing"
 	"github.com/abramad-labs/histomigrate/dktesting"
@@ -95,6 +97,9 @@ func Test(t *testing.T) {
 	t.Run("testMigrate", testMigrate)
 	t.Run("testMultiStatement", testMultiStatement)
 	t.Run("testFilterCustomQuery", testFilterCustomQuery)
+	t.Run("testExtended", testExtended)
+	t.Run("testAddDirtyMigrationRetry", testAddDirtyMigrationRetry)
+	t.Run("testLegacyHistory", testLegacyHistory)
 
 	t.Cleanup(func() {
 		for _, spec := range specs {
@@ -170,7 +175,7 @@ func testMultiStatement(t *testing.T) {
 
 		// make sure second table exists
 		var exists bool
-		if err := d.(*YugabyteDB).db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT current_schema()))").Scan(&exists); err != nil {
+		if err := d.(*YugabyteDBExtras).db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'bar' AND table_schema = (SELECT current_schema()))").Scan(&exists); err != nil {
 			t.Fatal(err)
 		}
 		if !exists {
@@ -197,3 +202,139 @@ func testFilterCustomQuery(t *testing.T) {
 		dt.Test(t, d, []byte("SELECT 1"))
 	})
 }
+
+func testExtended(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := getConnectionString(ip, port)
+		c := &YugabyteDB{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt.TestExtended(t, d.(*YugabyteDBExtras))
+	})
+}
+
+func testAddDirtyMigrationRetry(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := getConnectionString(ip, port)
+		c := &YugabyteDB{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		yd := d.(*YugabyteDBExtras)
+
+		query := `INSERT INTO "` + yd.config.MigrationsTable + `" (version, dirty, applied_at) VALUES ($1, true, now()) ON CONFLICT (version) DO NOTHING`
+		attempts := 0
+		err = yd.executeTx(func(tx *sql.Tx, ambiguous bool) error {
+			attempts++
+			if attempts == 1 {
+				// a concurrent writer records the version, and this attempt fails to serialize
+				if _, err := yd.db.Exec(query, 5); err != nil {
+					return err
+				}
+				return &pq.Error{Code: "40001"}
+			}
+			return insertDirty(tx, query, 5, ambiguous)
+		})
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", attempts)
+		}
+		if !errors.Is(err, ErrMigrationExists) {
+			t.Fatalf("expected ErrMigrationExists after the retry, got %v", err)
+		}
+	})
+}
+
+func testLegacyHistory(t *testing.T) {
+	dktesting.ParallelTest(t, specs, func(t *testing.T, ci dktest.ContainerInfo) {
+		createDB(t, ci)
+
+		ip, port, err := ci.Port(defaultPort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the migrations table of an earlier release at version 3
+		db, err := sql.Open("postgres", fmt.Sprintf("postgres://yugabyte:yugabyte@%v:%v/migrate?sslmode=disable", ip, port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range []string{
+			`CREATE TABLE legacy_migrations (version INT NOT NULL PRIMARY KEY, dirty BOOL NOT NULL)`,
+			`INSERT INTO legacy_migrations (version, dirty) VALUES (3, false)`,
+		} {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		addr := getConnectionString(ip, port, "x-migrations-table=legacy_migrations")
+		c := &YugabyteDB{}
+		d, err := c.Open(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt.TestLegacyHistory(t, d.(*YugabyteDBExtras), false)
+	})
+}
+
+func TestErrIsAmbiguousCommit(t *testing.T) {
+	testCases := []struct {
+		err       error
+		ambiguous bool
+	}{
+		{err: &pq.Error{Code: "08006"}, ambiguous: true},
+		{err: &pgconn.PgError{Code: "XX000"}, ambiguous: true},
+		{err: &pq.Error{Code: "40001"}, ambiguous: false},
+		{err: &pq.Error{Code: "40P01"}, ambiguous: false},
+		{err: errors.New("foo"), ambiguous: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			if got := errIsAmbiguousCommit(tc.err); got != tc.ambiguous {
+				t.Fatalf("expected %v, got %v", tc.ambiguous, got)
+			}
+		})
+	}
+}
+
+func TestErrIsRetryable(t *testing.T) {
+	testCases := []struct {
+		err       error
+		retryable bool
+	}{
+		{err: &pq.Error{Code: "40001"}, retryable: true},
+		{err: &pgconn.PgError{Code: "40001"}, retryable: true},
+		{err: fmt.Errorf("wrapped: %w", &pq.Error{Code: "08006"}), retryable: true},
+		{err: &pq.Error{Code: "23505"}, retryable: false},
+		{err: errors.New("foo"), retryable: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			if got := errIsRetryable(tc.err); got != tc.retryable {
+				t.Fatalf("expected %v, got %v", tc.retryable, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build archive
+
+package cli
+
+import (
+	_ "github.com/abramad-labs/histomigrate/source/archive"
+)
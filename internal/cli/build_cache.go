@@ -0,0 +1,7 @@
+//go:build cache
+
+package cli
+
+import (
+	_ "github.com/abramad-labs/histomigrate/source/cache"
+)
@@ -0,0 +1,7 @@
+//go:build git
+
+package cli
+
+import (
+	_ "github.com/abramad-labs/histomigrate/source/git"
+)
@@ -0,0 +1,7 @@
+//go:build http_manifest
+
+package cli
+
+import (
+	_ "github.com/abramad-labs/histomigrate/source/http_manifest"
+)
@@ -0,0 +1,7 @@
+//go:build multi
+
+package cli
+
+import (
+	_ "github.com/abramad-labs/histomigrate/source/multi"
+)
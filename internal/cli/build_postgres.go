@@ -4,4 +4,10 @@ package cli
 
 import (
 	_ "github.com/abramad-labs/histomigrate/database/postgres"
+	"github.com/abramad-labs/histomigrate/database/postgres/schemas"
 )
+
+func init() {
+	urlFanOuts["postgres"] = urlFanOut{selects: schemas.Selects, fanOut: schemas.FanOutURL}
+	urlFanOuts["postgresql"] = urlFanOut{selects: schemas.Selects, fanOut: schemas.FanOutURL}
+}
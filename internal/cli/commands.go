@@ -1,23 +1,29 @@
 package cli
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/abramad-labs/histomigrate"
 	_ "github.com/abramad-labs/histomigrate/database/stub" // TODO remove again
+	"github.com/abramad-labs/histomigrate/source"
 	_ "github.com/abramad-labs/histomigrate/source/file"
+	"github.com/abramad-labs/histomigrate/source/signed"
 )
 
 var (
 	errInvalidSequenceWidth     = errors.New("Digits must be positive")
 	errIncompatibleSeqAndFormat = errors.New("The seq and format options are mutually exclusive")
 	errInvalidTimeFormat        = errors.New("Time format may not be empty")
+	errVersionBelowLatest       = errors.New("Version is below the latest applied version")
 )
 
 func nextSeqVersion(matches []string, seqDigits int) (string, error) {
@@ -71,8 +77,33 @@ func timeVersion(startTime time.Time, format string) (version string, err error)
 	return
 }
 
+// createOptions holds the optional behaviour of createCmd.
+type createOptions struct {
+	// templateDir holds skeletons named up.<ext>, down.<ext> and single.<ext>,
+	// rendered as text/template templates with a skeleton as data.
+	templateDir string
+	singleFile  bool
+	// fromDiff is a shell command printing the body of a migration, run once per direction,
+	// e.g. a schema diff tool.
+	fromDiff string
+	// latest is the latest applied version, or -1 if unknown.
+	latest int64
+	// outOfOrder allows creating a version below latest.
+	outOfOrder bool
+}
+
+// skeleton is the data of the templates of createCmd.
+type skeleton struct {
+	Version   string
+	Name      string
+	Direction string // empty for single files
+	Body      string // output of the -from-diff command for Direction
+	Up        string // output of the -from-diff command for the up migration
+	Down      string // output of the -from-diff command for the down migration
+}
+
 // createCmd (meant to be called via a CLI command) creates a new migration
-func createCmd(dir string, startTime time.Time, format string, name string, ext string, seq bool, seqDigits int, print bool) error {
+func createCmd(dir string, startTime time.Time, format string, name string, ext string, seq bool, seqDigits int, print bool, opts createOptions) error {
 	if seq && format != defaultTimeFormat {
 		return errIncompatibleSeqAndFormat
 	}
@@ -103,6 +134,12 @@ func createCmd(dir string, startTime time.Time, format string, name string, ext
 		}
 	}
 
+	if opts.latest >= 0 && !opts.outOfOrder {
+		if v, err := strconv.ParseUint(version, 10, 64); err == nil && v < uint64(opts.latest) {
+			return fmt.Errorf("%w: %s is below %d, use -out-of-order if intended", errVersionBelowLatest, version, opts.latest)
+		}
+	}
+
 	versionGlob := filepath.Join(dir, version+"_*"+ext)
 	matches, err := filepath.Glob(versionGlob)
 
@@ -114,15 +151,57 @@ func createCmd(dir string, startTime time.Time, format string, name string, ext
 		return fmt.Errorf("duplicate migration version: %s", version)
 	}
 
+	data := skeleton{Version: version, Name: name}
+	if opts.fromDiff != "" {
+		if data.Up, err = runDiffHook(opts.fromDiff, version, name, "up"); err != nil {
+			return err
+		}
+		if data.Down, err = runDiffHook(opts.fromDiff, version, name, "down"); err != nil {
+			return err
+		}
+	}
+
+	files := make(map[string][]byte)
+	var order []string
+	if opts.singleFile {
+		basename := fmt.Sprintf("%s_%s%s", version, name, ext)
+		content, err := renderSkeleton(opts.templateDir, "single"+ext, data)
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			content = []byte(fmt.Sprintf("-- +migrate Up\n%s\n-- +migrate Down\n%s", withNewline(data.Up), withNewline(data.Down)))
+		}
+		files[basename] = content
+		order = append(order, basename)
+	} else {
+		for _, direction := range []string{"up", "down"} {
+			basename := fmt.Sprintf("%s_%s.%s%s", version, name, direction, ext)
+			data.Direction = direction
+			data.Body = data.Up
+			if direction == "down" {
+				data.Body = data.Down
+			}
+			content, err := renderSkeleton(opts.templateDir, direction+ext, data)
+			if err != nil {
+				return err
+			}
+			if content == nil && data.Body != "" {
+				content = []byte(withNewline(data.Body))
+			}
+			files[basename] = content
+			order = append(order, basename)
+		}
+	}
+
 	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
 		return err
 	}
 
-	for _, direction := range []string{"up", "down"} {
-		basename := fmt.Sprintf("%s_%s.%s%s", version, name, direction, ext)
+	for _, basename := range order {
 		filename := filepath.Join(dir, basename)
 
-		if err = createFile(filename); err != nil {
+		if err = createFile(filename, files[basename]); err != nil {
 			return err
 		}
 
@@ -135,7 +214,60 @@ func createCmd(dir string, startTime time.Time, format string, name string, ext
 	return nil
 }
 
-func createFile(filename string) error {
+// renderSkeleton renders the template named name in templateDir, if any.
+// It returns nil if templateDir is empty or has no such template.
+func renderSkeleton(templateDir string, name string, data skeleton) ([]byte, error) {
+	if templateDir == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(filepath.Join(templateDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// runDiffHook runs command with sh and returns its output. The version, name and direction
+// of the migration are passed as MIGRATE_VERSION, MIGRATE_NAME and MIGRATE_DIRECTION.
+func runDiffHook(command string, version string, name string, direction string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"MIGRATE_VERSION="+version,
+		"MIGRATE_NAME="+name,
+		"MIGRATE_DIRECTION="+direction,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("-from-diff %s: %w: %s", direction, err, msg)
+		}
+		return "", fmt.Errorf("-from-diff %s: %w", direction, err)
+	}
+	return stdout.String(), nil
+}
+
+func withNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
+
+func createFile(filename string, content []byte) error {
 	// create exclusive (fails if file already exists)
 	// os.Create() specifies 0666 as the FileMode, so we're doing the same
 	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
@@ -144,54 +276,190 @@ func createFile(filename string) error {
 		return err
 	}
 
-	return f.Close()
+	_, err = f.Write(content)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
 }
 
-func gotoCmd(m *migrate.Migrate, v uint) error {
-	if err := m.Migrate(v); err != nil {
-		if err != migrate.ErrNoChange {
-			return err
-		}
-		log.Println(err)
+// varsFlag collects repeated -var K=V flags.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for k, val := range v {
+		pairs = append(pairs, k+"="+val)
 	}
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	k, val, err := migrate.ParseVar(s)
+	if err != nil {
+		return err
+	}
+	v[k] = val
 	return nil
 }
 
+// templateVars merges the template variables of the vars file, the environment and the -var flags,
+// in increasing precedence. Rendering has to be enabled explicitly, by render, a vars file or -var flags;
+// otherwise templateVars returns nil, leaving rendering disabled whatever the environment holds.
+func templateVars(render bool, varsFile string, flagVars map[string]string) (map[string]string, error) {
+	if !render && varsFile == "" && len(flagVars) == 0 {
+		return nil, nil
+	}
+
+	var fileVars map[string]string
+	if varsFile != "" {
+		f, err := os.Open(varsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if fileVars, err = migrate.ReadVars(f); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", varsFile, err)
+		}
+	}
+
+	return migrate.MergeVars(fileVars, migrate.EnvVars(), flagVars), nil
+}
+
+func gotoCmd(m *migrate.Migrate, v uint) error {
+	return m.Migrate(v)
+}
+
 func upCmd(m *migrate.Migrate, limit int) error {
 	if limit >= 0 {
-		if err := m.Steps(limit); err != nil {
-			if err != migrate.ErrNoChange {
-				return err
-			}
-			log.Println(err)
+		return m.Steps(limit)
+	}
+	return m.Up()
+}
+
+func planCmd(m *migrate.Migrate, limit int) error {
+	plan, err := m.Plan(limit)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		log.Println(migrate.ErrNoChange)
+		return nil
+	}
+	for _, p := range plan {
+		line := fmt.Sprintf("%v %v", p.Version, p.Identifier)
+		if len(p.Tags) > 0 {
+			line += fmt.Sprintf(" [%v]", strings.Join(p.Tags, ","))
 		}
-	} else {
-		if err := m.Up(); err != nil {
-			if err != migrate.ErrNoChange {
-				return err
-			}
-			log.Println(err)
+		if p.Skipped {
+			line += " (skipped)"
 		}
+		log.Println(line)
 	}
 	return nil
 }
 
 func downCmd(m *migrate.Migrate, limit int) error {
 	if limit >= 0 {
-		if err := m.Steps(-limit); err != nil {
-			if err != migrate.ErrNoChange {
-				return err
-			}
-			log.Println(err)
-		}
-	} else {
-		if err := m.Down(); err != nil {
-			if err != migrate.ErrNoChange {
-				return err
-			}
-			log.Println(err)
+		return m.Steps(-limit)
+	}
+	return m.Down()
+}
+
+// seedUpCmd applies the seeds of seeder, once all migrations of schema are applied.
+// The schema is locked while it is checked only, see migrate.CheckApplied.
+func seedUpCmd(schema *migrate.Migrate, seeder *migrate.Migrate, limit int) error {
+	if err := schema.CheckApplied(); err != nil {
+		return fmt.Errorf("not applying seeds: %w", err)
+	}
+	return upCmd(seeder, limit)
+}
+
+func statusCmd(m *migrate.Migrate) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied"
 		}
+		log.Printf("%v %v %v\n", s.Version, s.Identifier, state)
+	}
+	return nil
+}
+
+// signCmd writes the manifest of the migrations of sourceURL and its signature.
+func signCmd(sourceURL string, keyPath string, manifestPath string) error {
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
 	}
+	key, err := signed.ParsePrivateKey(keyData)
+	if err != nil {
+		return err
+	}
+
+	d, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	manifest, err := signed.Build(d)
+	if err != nil {
+		return err
+	}
+	data := manifest.Bytes()
+
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath+".sig", signed.Sign(data, key), 0644); err != nil {
+		return err
+	}
+
+	log.Printf("Signed %d migrations in %s\n", len(manifest.Entries), manifestPath)
+	return nil
+}
+
+// verifySignatureCmd verifies the signature of a manifest and checks the migrations of sourceURL against it.
+func verifySignatureCmd(sourceURL string, keyPath string, manifestPath string) error {
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	key, err := signed.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	signature, err := os.ReadFile(manifestPath + ".sig")
+	if err != nil {
+		return err
+	}
+
+	manifest, err := signed.Verify(data, signature, key)
+	if err != nil {
+		return err
+	}
+
+	d, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := signed.Check(d, manifest); err != nil {
+		return err
+	}
+
+	log.Printf("Verified %d migrations against %s\n", len(manifest.Entries), manifestPath)
 	return nil
 }
 
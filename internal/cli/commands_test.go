@@ -1,14 +1,26 @@
 package cli
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/abramad-labs/histomigrate"
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	"github.com/abramad-labs/histomigrate/source/signed"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -231,7 +243,7 @@ func (s *CreateCmdSuite) TestCreateCmd() {
 				dir = filepath.Join(baseDir, dir)
 			}
 
-			err := createCmd(dir, c.startTime, c.format, c.name, c.ext, c.seq, c.seqDigits, false)
+			err := createCmd(dir, c.startTime, c.format, c.name, c.ext, c.seq, c.seqDigits, false, createOptions{latest: -1})
 
 			if c.expectedErr != nil {
 				s.EqualError(err, c.expectedErr.Error())
@@ -253,6 +265,70 @@ func (s *CreateCmdSuite) TestCreateCmd() {
 	}
 }
 
+func (s *CreateCmdSuite) TestCreateCmdOptions() {
+	ts := time.Date(2000, 12, 25, 00, 01, 02, 3456789, time.UTC)
+
+	templates := s.mustCreateTempDir()
+	defer s.mustRemoveDir(templates)
+	s.mustWriteFile(templates, "up.sql", "-- histomigrate: timeout=30s\nBEGIN;\n{{ .Body }}COMMIT;\n")
+	s.mustWriteFile(templates, "single.sql", "-- {{ .Version }} {{ .Name }}\n-- +migrate Up\n{{ .Up }}-- +migrate Down\n{{ .Down }}")
+
+	cases := []struct {
+		tid      string
+		opts     createOptions
+		expected map[string]string
+		err      error
+	}{
+		{"template", createOptions{templateDir: templates, latest: -1}, map[string]string{
+			"0001_name.up.sql":   "-- histomigrate: timeout=30s\nBEGIN;\nCOMMIT;\n",
+			"0001_name.down.sql": "",
+		}, nil},
+		{"single file", createOptions{singleFile: true, latest: -1}, map[string]string{
+			"0001_name.sql": "-- +migrate Up\n\n-- +migrate Down\n",
+		}, nil},
+		{"single file template from diff", createOptions{templateDir: templates, singleFile: true, fromDiff: `echo "$MIGRATE_DIRECTION $MIGRATE_VERSION $MIGRATE_NAME"`, latest: -1}, map[string]string{
+			"0001_name.sql": "-- 0001 name\n-- +migrate Up\nup 0001 name\n-- +migrate Down\ndown 0001 name\n",
+		}, nil},
+		{"from diff", createOptions{fromDiff: `[ "$MIGRATE_DIRECTION" = up ] && printf 'CREATE TABLE t (id INT);' || true`, latest: -1}, map[string]string{
+			"0001_name.up.sql":   "CREATE TABLE t (id INT);\n",
+			"0001_name.down.sql": "",
+		}, nil},
+		{"from diff failing", createOptions{fromDiff: "echo boom >&2; exit 3", latest: -1}, nil, errors.New("-from-diff up: exit status 3: boom")},
+		{"below latest", createOptions{latest: 2}, nil, errVersionBelowLatest},
+		{"below latest out of order", createOptions{latest: 2, outOfOrder: true}, map[string]string{
+			"0001_name.up.sql":   "",
+			"0001_name.down.sql": "",
+		}, nil},
+	}
+
+	for _, c := range cases {
+		s.Run(c.tid, func() {
+			dir := s.mustCreateTempDir()
+			defer s.mustRemoveDir(dir)
+
+			err := createCmd(dir, ts, defaultTimeFormat, "name", "sql", true, 4, false, c.opts)
+			if c.err != nil {
+				if errors.Is(err, c.err) {
+					return
+				}
+				s.EqualError(err, c.err.Error())
+				s.assertEmptyDir(dir)
+				return
+			}
+			s.Require().NoError(err)
+
+			fis, err := os.ReadDir(dir)
+			s.Require().NoError(err)
+			s.Len(fis, len(c.expected))
+			for name, body := range c.expected {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				s.Require().NoError(err)
+				s.Equal(body, string(content))
+			}
+		})
+	}
+}
+
 func TestNumDownFromArgs(t *testing.T) {
 	cases := []struct {
 		name                string
@@ -290,3 +366,200 @@ func TestNumDownFromArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateVars(t *testing.T) {
+	t.Setenv("MIGRATE_VAR_reader", "env")
+	vars, err := templateVars(false, "", varsFlag{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars != nil {
+		t.Errorf("expected environment variables not to enable rendering, got %v", vars)
+	}
+
+	vars, err = templateVars(true, "", varsFlag{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["reader"] != "env" {
+		t.Errorf("expected -render to read the environment, got %v", vars)
+	}
+
+	varsFile := filepath.Join(t.TempDir(), "staging.vars")
+	if err := os.WriteFile(varsFile, []byte("schema=file\nrole=file\ntablespace=file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MIGRATE_VAR_role", "env")
+	t.Setenv("MIGRATE_VAR_tablespace", "env")
+
+	flagVars := varsFlag{}
+	if err := flagVars.Set("tablespace=flag"); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err = templateVars(false, varsFile, flagVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["schema"] != "file" || vars["role"] != "env" || vars["tablespace"] != "flag" {
+		t.Errorf("unexpected precedence of variables: %v", vars)
+	}
+
+	if err := flagVars.Set("tablespace"); err == nil {
+		t.Error("expected an error for a variable without value")
+	}
+}
+
+func TestSignAndVerifySignatureCmd(t *testing.T) {
+	dir := t.TempDir()
+	migrations := filepath.Join(dir, "db")
+	if err := os.MkdirAll(migrations, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"1_init.up.sql": "CREATE 1", "1_init.down.sql": "DROP 1"} {
+		if err := os.WriteFile(filepath.Join(migrations, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath := filepath.Join(dir, "release.key")
+	pubPath := filepath.Join(dir, "release.pub")
+	if err := os.WriteFile(privPath, []byte(base64.StdEncoding.EncodeToString(priv)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, []byte(base64.StdEncoding.EncodeToString(pub)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := filepath.Join(dir, "db.manifest")
+	if err := signCmd("file://"+migrations, privPath, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignatureCmd("file://"+migrations, pubPath, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(migrations, "2_unreviewed.up.sql"), []byte("CREATE 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignatureCmd("file://"+migrations, pubPath, manifest); !errors.Is(err, signed.ErrNotInManifest) {
+		t.Fatalf("expected ErrNotInManifest, got %v", err)
+	}
+}
+
+func TestSeedUpCmd(t *testing.T) {
+	newMigrate := func(fsys fstest.MapFS) *migrate.Migrate {
+		t.Helper()
+		src, err := iofs.New(fsys, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := dStub.WithInstance(nil, &dStub.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := migrate.NewWithInstance("iofs", src, "stub", db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	schema := newMigrate(fstest.MapFS{"1_init.up.sql": {Data: []byte("CREATE 1")}})
+	seeder := newMigrate(fstest.MapFS{"1_users.up.sql": {Data: []byte("INSERT 1")}})
+
+	if err := seedUpCmd(schema, seeder, -1); !errors.Is(err, migrate.ErrSchemaPending) {
+		t.Fatalf("expected ErrSchemaPending, got %v", err)
+	}
+	if err := schema.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := seedUpCmd(schema, seeder, -1); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := seeder.Version(); err != nil || v != 1 {
+		t.Fatalf("expected seed version 1, got %v, %v", v, err)
+	}
+}
+
+func TestReporterExitCode(t *testing.T) {
+	tt := []struct {
+		name         string
+		err          error
+		exitNoChange bool
+		failed       bool
+		expected     int
+	}{
+		{"success", nil, false, false, 0},
+		{"no change", migrate.ErrNoChange, false, false, 0},
+		{"no change with -exit-no-change", migrate.ErrNoChange, true, false, exitNoChange},
+		{"dirty", migrate.ErrDirty{Version: 3}, false, false, exitDirty},
+		{"lock timeout", migrate.ErrLockTimeout, false, false, exitLockTimeout},
+		{"missing version", fmt.Errorf("%w for version 9: %w", migrate.ErrMissingVersion, os.ErrNotExist), false, false, exitMissingVersion},
+		{"missing path", &fs.PathError{Op: "open", Path: "migrations", Err: fs.ErrNotExist}, false, false, exitError},
+		{"nil version", migrate.ErrNilVersion, false, false, exitMissingVersion},
+		{"migration failed", errors.New("syntax error"), false, true, exitMigrationFailed},
+		{"other error", errors.New("connection refused"), false, false, exitError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newReporter("up", outputText, tc.exitNoChange, time.Now())
+			r.failed = tc.failed
+			if code := r.exitCode(tc.err); code != tc.expected {
+				t.Errorf("expected exit code %d, got %d", tc.expected, code)
+			}
+		})
+	}
+}
+
+func TestReporterResult(t *testing.T) {
+	src, err := iofs.New(fstest.MapFS{
+		"1_init.up.sql":   {Data: []byte("CREATE 1")},
+		"2_orders.up.sql": {Data: []byte("-- histomigrate: depends=3\nCREATE 2")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newReporter("up", outputJSON, false, time.Now())
+	r.watch(m)
+	res := r.result(m, upCmd(m, -1))
+	if res.Status != "error" || res.ExitCode != exitMigrationFailed {
+		t.Fatalf("expected a failed migration, got %+v", res)
+	}
+	if len(res.Migrations) != 2 || res.Migrations[0].Identifier != "init" || res.Migrations[0].Error != "" || res.Migrations[1].Error == "" {
+		t.Fatalf("unexpected migrations %+v", res.Migrations)
+	}
+
+	var buf bytes.Buffer
+	if err := writeResult(&buf, res); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"command", "status", "exit_code", "error", "version", "dirty", "migrations", "duration_ms"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected key %q in %s", key, buf.String())
+		}
+	}
+
+	r = newReporter("up", outputJSON, false, time.Now())
+	if res := r.result(nil, migrate.ErrNoChange); res.Status != "no_change" || res.ExitCode != 0 || res.Migrations == nil {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	nurl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix        = "MIGRATE_"
+	environmentsKey  = "environments"
+	secretFilePrefix = "file:"
+)
+
+var defaultConfigFiles = []string{"migrate.yaml", "migrate.yml", "migrate.toml"}
+
+var (
+	errNoConfig        = errors.New("Environment given without a config file")
+	errUnknownConfig   = errors.New("Config files must end with .yaml, .yml or .toml")
+	errUnknownEnv      = errors.New("Unknown environment")
+	errUnknownKey      = errors.New("Unknown config key")
+	errMissingVariable = errors.New("Environment variable is not set")
+)
+
+// unconfigurable are the global flags that can't be given by environment variables or config files.
+var unconfigurable = map[string]bool{"help": true, "version": true, "var": true, "config": true, "env": true, "yes": true}
+
+// sticky are the boolean flags that can't be turned off once the config file turns them on.
+var sticky = map[string]bool{"protected": true}
+
+// secretRef matches ${env:NAME} references in database URLs.
+var secretRef = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// config holds the global flags given by a config file, at the top level and per environment.
+type config struct {
+	values       map[string]string
+	environments map[string]map[string]string
+}
+
+// envVarName returns the environment variable of a flag, e.g. MIGRATE_LOCK_TIMEOUT for lock-timeout.
+func envVarName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadFlags sets the flags of fs that weren't given on the command line from the environment selected by -env
+// in the config file, then from MIGRATE_* environment variables, then from the top level of the config file.
+// Sticky flags turned on by the config file stay on, whatever the command line and environment variables say.
+// The config file is given by -config, or is the first of migrate.yaml, migrate.yml and migrate.toml
+// found in the working directory.
+func loadFlags(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range []string{"config", "env"} {
+		if v, ok := lookupEnv(envVarName(name)); ok && !set[name] {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %w", envVarName(name), err)
+			}
+		}
+	}
+
+	env := fs.Lookup("env").Value.String()
+	configPath := fs.Lookup("config").Value.String()
+	if configPath == "" {
+		configPath = findConfig()
+	}
+	c := &config{}
+	if configPath != "" {
+		var err error
+		if c, err = loadConfig(configPath, fs); err != nil {
+			return err
+		}
+		if _, ok := c.environments[env]; env != "" && !ok {
+			return fmt.Errorf("%w %q in %s", errUnknownEnv, env, configPath)
+		}
+	} else if env != "" {
+		return errNoConfig
+	}
+
+	if err := setFlags(fs, set, func(name string) (string, bool) {
+		v, ok := c.environments[env][name]
+		return v, ok
+	}, func(name string) string {
+		return configPath + ": " + name
+	}); err != nil {
+		return err
+	}
+	if err := setFlags(fs, set, func(name string) (string, bool) {
+		return lookupEnv(envVarName(name))
+	}, envVarName); err != nil {
+		return err
+	}
+	if err := setFlags(fs, set, func(name string) (string, bool) {
+		v, ok := c.values[name]
+		return v, ok
+	}, func(name string) string {
+		return configPath + ": " + name
+	}); err != nil {
+		return err
+	}
+
+	for name := range sticky {
+		v, ok := c.environments[env][name]
+		if !ok {
+			v = c.values[name]
+		}
+		if on, _ := strconv.ParseBool(v); on {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", configPath, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setFlags sets the configurable flags of fs missing from set to the values found by lookup, and adds them to set.
+// Errors name the invalid value with origin.
+func setFlags(fs *flag.FlagSet, set map[string]bool, lookup func(name string) (string, bool), origin func(name string) string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || unconfigurable[f.Name] {
+			return
+		}
+		if v, ok := lookup(f.Name); ok {
+			if errSet := fs.Set(f.Name, v); errSet != nil {
+				err = fmt.Errorf("%s: %w", origin(f.Name), errSet)
+			}
+			set[f.Name] = true
+		}
+	})
+	return err
+}
+
+// findConfig returns the first default config file found in the working directory, or "".
+func findConfig() string {
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadConfig reads a YAML or TOML config file whose keys are the names of the flags of fs, e.g.
+//
+//	database: postgres://localhost:5432/app?sslmode=disable
+//	environments:
+//	  staging:
+//	    database: postgres://app:${env:PGPASSWORD}@staging:5432/app
+func loadConfig(path string, fs *flag.FlagSet) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, errUnknownConfig
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	c, err := newConfig(raw, fs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func newConfig(raw map[string]interface{}, fs *flag.FlagSet) (*config, error) {
+	c := &config{environments: map[string]map[string]string{}}
+
+	var err error
+	envs, _ := raw[environmentsKey].(map[string]interface{})
+	if _, ok := raw[environmentsKey]; ok && envs == nil {
+		return nil, fmt.Errorf("%s must be a table of environments", environmentsKey)
+	}
+	delete(raw, environmentsKey)
+	if c.values, err = configValues(raw, fs); err != nil {
+		return nil, err
+	}
+
+	for name, v := range envs {
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("environment %s must be a table", name)
+		}
+		if c.environments[name], err = configValues(values, fs); err != nil {
+			return nil, fmt.Errorf("environment %s: %w", name, err)
+		}
+	}
+	return c, nil
+}
+
+// configValues checks that the keys of raw are flags of fs and formats their values.
+func configValues(raw map[string]interface{}, fs *flag.FlagSet) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		if fs.Lookup(key) == nil || unconfigurable[key] {
+			return nil, fmt.Errorf("%w %q", errUnknownKey, key)
+		}
+		switch v.(type) {
+		case string, bool, int, int64, uint64, float64:
+			values[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s must be a string, number or boolean", key)
+		}
+	}
+	return values, nil
+}
+
+// resolveSecrets replaces the ${env:NAME} references of a database URL with the value of environment variable NAME,
+// escaped for the part of the URL the reference is in, see secretEscape.
+// A URL of the form file:PATH is replaced by the trimmed content of the file PATH, e.g. a mounted secret.
+func resolveSecrets(databaseURL string, lookupEnv func(string) (string, bool)) (string, error) {
+	if strings.HasPrefix(databaseURL, secretFilePrefix) && !strings.HasPrefix(databaseURL, secretFilePrefix+"//") {
+		data, err := os.ReadFile(strings.TrimPrefix(databaseURL, secretFilePrefix))
+		if err != nil {
+			return "", err
+		}
+		databaseURL = strings.TrimSpace(string(data))
+	}
+
+	var missing []string
+	var resolved strings.Builder
+	last := 0
+	for _, ref := range secretRef.FindAllStringSubmatchIndex(databaseURL, -1) {
+		name := databaseURL[ref[2]:ref[3]]
+		v, ok := lookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		resolved.WriteString(databaseURL[last:ref[0]])
+		resolved.WriteString(secretEscape(databaseURL, ref[0])(v))
+		last = ref[1]
+	}
+	resolved.WriteString(databaseURL[last:])
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("%w: %s", errMissingVariable, strings.Join(missing, ", "))
+	}
+	return resolved.String(), nil
+}
+
+// secretEscape returns the escaping of a value inserted at offset i of databaseURL. Values in the userinfo,
+// the path or the query are escaped for it; values anywhere else, e.g. the host or the whole URL, are inserted as is.
+func secretEscape(databaseURL string, i int) func(string) string {
+	asIs := func(v string) string { return v }
+
+	end := len(databaseURL)
+	if fragment := strings.IndexByte(databaseURL, '#'); fragment >= 0 {
+		if i > fragment {
+			return asIs
+		}
+		end = fragment
+	}
+	if query := strings.IndexByte(databaseURL[:end], '?'); query >= 0 {
+		if i > query {
+			return nurl.QueryEscape
+		}
+		end = query
+	}
+
+	scheme := strings.Index(databaseURL[:end], "://")
+	if scheme < 0 || i < scheme+len("://") {
+		return asIs
+	}
+	authority := databaseURL[scheme+len("://") : end]
+	if slash := strings.IndexByte(authority, '/'); slash >= 0 {
+		if i >= scheme+len("://")+slash {
+			return func(v string) string { return (&nurl.URL{Path: v}).EscapedPath() }
+		}
+		authority = authority[:slash]
+	}
+	if at := strings.LastIndexByte(authority, '@'); at >= 0 && i < scheme+len("://")+at {
+		return func(v string) string { return nurl.User(v).String() }
+	}
+	return asIs
+}
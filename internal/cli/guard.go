@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate"
+)
+
+var (
+	errProtectedDownAll = errors.New("Refusing to apply all down migrations to a protected database")
+	errNotConfirmed     = errors.New("Not confirmed")
+)
+
+// guard asks for typed confirmation before commands that undo migrations or lose data
+// are run against a protected database, see -protected.
+type guard struct {
+	protected bool
+	yes       bool
+	// env is the environment of the config file, typed to confirm.
+	env string
+	in  io.Reader
+}
+
+// word returns the word to type to confirm.
+func (g *guard) word() string {
+	if g.env != "" {
+		return g.env
+	}
+	return "yes"
+}
+
+// confirm shows what a command is about to do and asks to type the name of the environment,
+// unless -yes is given. It does nothing unless the database is protected and plan is non-empty.
+func (g *guard) confirm(action string, plan []string) error {
+	if !g.protected || len(plan) == 0 {
+		return nil
+	}
+
+	target := "the protected database"
+	if g.env != "" {
+		target = fmt.Sprintf("the protected environment %s", g.env)
+	}
+	log.Printf("About to %s on %s:\n", action, target)
+	for _, line := range plan {
+		log.Println("  " + line)
+	}
+	if g.yes {
+		return nil
+	}
+
+	log.Printf("Type %q to continue: ", g.word())
+	var response string
+	_, _ = fmt.Fscanln(g.in, &response)
+	if strings.TrimSpace(response) != g.word() {
+		return errNotConfirmed
+	}
+	return nil
+}
+
+// refuseDownAll fails if the database is protected; rolling it back entirely is never allowed.
+func (g *guard) refuseDownAll() error {
+	if g.protected {
+		return errProtectedDownAll
+	}
+	return nil
+}
+
+// confirmDown confirms rolling back the migrations m would roll back to apply limit down migrations,
+// or all if limit is -1, keeping only those whose version keep accepts unless it is nil.
+func (g *guard) confirmDown(m *migrate.Migrate, action string, limit int, keep func(version uint) bool) error {
+	if !g.protected {
+		return nil
+	}
+	planned, err := m.PlanDown(limit)
+	if err != nil {
+		return err
+	}
+	var plan []string
+	for _, p := range planned {
+		if keep == nil || keep(p.Version) {
+			plan = append(plan, fmt.Sprintf("%v %v (down)", p.Version, p.Identifier))
+		}
+	}
+	return g.confirm(action, plan)
+}
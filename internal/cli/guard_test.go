@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abramad-labs/histomigrate"
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestGuardConfirm(t *testing.T) {
+	plan := []string{"drop everything inside the database"}
+	tt := []struct {
+		name     string
+		g        guard
+		input    string
+		expected error
+	}{
+		{"unprotected", guard{}, "", nil},
+		{"typed environment", guard{protected: true, env: "staging"}, "staging\n", nil},
+		{"typed yes without environment", guard{protected: true}, "yes\n", nil},
+		{"typed something else", guard{protected: true, env: "staging"}, "y\n", errNotConfirmed},
+		{"no input", guard{protected: true, env: "staging"}, "", errNotConfirmed},
+		{"-yes", guard{protected: true, env: "staging", yes: true}, "", nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.g.in = strings.NewReader(tc.input)
+			if err := tc.g.confirm("drop everything", plan); !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	g := guard{protected: true, in: strings.NewReader("")}
+	if err := g.confirm("roll back 0 migrations", nil); err != nil {
+		t.Errorf("expected an empty plan to need no confirmation, got %v", err)
+	}
+}
+
+func TestGuardRefuseDownAll(t *testing.T) {
+	if err := (&guard{}).refuseDownAll(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := (&guard{protected: true, yes: true}).refuseDownAll(); !errors.Is(err, errProtectedDownAll) {
+		t.Errorf("expected errProtectedDownAll, got %v", err)
+	}
+}
+
+func TestGuardConfirmDown(t *testing.T) {
+	src, err := iofs.New(fstest.MapFS{
+		"1_init.up.sql":    {Data: []byte("CREATE 1")},
+		"1_init.down.sql":  {Data: []byte("DROP 1")},
+		"2_users.up.sql":   {Data: []byte("CREATE 2")},
+		"2_users.down.sql": {Data: []byte("DROP 2")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &guard{protected: true, env: "prod", in: strings.NewReader("")}
+	if err := g.confirmDown(m, "roll back 1 migrations", 1, nil); !errors.Is(err, errNotConfirmed) {
+		t.Errorf("expected errNotConfirmed, got %v", err)
+	}
+	// goto 2 rolls nothing back
+	if err := g.confirmDown(m, "migrate to version 2", -1, func(version uint) bool { return version > 2 }); err != nil {
+		t.Errorf("expected no confirmation, got %v", err)
+	}
+
+	g.in = strings.NewReader("prod\n")
+	if err := g.confirmDown(m, "undo migration 1", -1, func(version uint) bool { return version == 1 }); err != nil {
+		t.Errorf("expected the confirmation to be accepted, got %v", err)
+	}
+}
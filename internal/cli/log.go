@@ -42,3 +42,19 @@ func (l *Log) fatal(args ...interface{}) {
 func (l *Log) fatalErr(err error) {
 	l.fatal("error:", err)
 }
+
+// prefixLog prefixes every line of a Log, e.g. with the target of a migration.
+type prefixLog struct {
+	prefix string
+	log    *Log
+}
+
+// Printf prints out formatted string into a log
+func (l *prefixLog) Printf(format string, v ...interface{}) {
+	l.log.Printf(l.prefix+format, v...)
+}
+
+// Verbose shows if verbose print enabled
+func (l *prefixLog) Verbose() bool {
+	return l.log.Verbose()
+}
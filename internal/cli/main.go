@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -18,19 +19,37 @@ import (
 const (
 	defaultTimeFormat = "20060102150405"
 	defaultTimezone   = "UTC"
-	createUsage       = `create [-ext E] [-dir D] [-seq] [-digits N] [-format] [-tz] NAME
+	defaultManifest   = "migrations.manifest"
+	createUsage       = `create [-ext E] [-dir D] [-seq] [-digits N] [-format] [-tz] [-template T] [-single-file] [-from-diff C] [-out-of-order] NAME
 	   Create a set of timestamped up/down migrations titled NAME, in directory D with extension E.
 	   Use -seq option to generate sequential up/down migrations with N digits.
 	   Use -format option to specify a Go time format string. Note: migrations with the same time cause "duplicate migration version" error.
            Use -tz option to specify the timezone that will be used when generating non-sequential migrations (defaults: UTC).
+	   Use -template option to fill the files from the skeletons up.E, down.E and single.E in directory T.
+	   Use -single-file option to create one file with +migrate Up and Down sections.
+	   Use -from-diff option to fill the files with the output of shell command C, run once per direction.
+	   With -database, versions below the latest applied one are refused unless -out-of-order is given.
 `
 	gotoUsage = `goto V       Migrate to version V`
-	upUsage   = `up [N]       Apply all or N up migrations`
+	upUsage   = `up [-tags T] [-targets F [-parallel P] [-continue-on-error]] [N]   Apply all or N up migrations
+	Use -tags to select migrations by tag, e.g. -tags=!seed; untagged ones are always selected, others stay pending
+	Use -targets to migrate the databases of file F instead of -database, one URL per line, optionally preceded by a name;
+	P at a time, skipping the remaining ones after a failure unless -continue-on-error is given.
+	A postgres -database URL with x-schemas or x-schema-pattern migrates the schemas it selects as targets,
+	one after the other over a single connection`
+	planUsage = `plan [-tags T] [N] List all or N pending up migrations without applying them
+	Use -tags to select migrations by tag, as for up`
 	downUsage = `down [N] [-all]    Apply all or N down migrations
 	Use -all to apply all down migrations`
 	dropUsage = `drop [-f]    Drop everything inside database
 	Use -f to bypass confirmation`
 	forceUsage = `force V      Set version V but don't run migration (ignores dirty state)`
+	signUsage  = `sign -key K [-manifest M]  Write a manifest of the migrations of -source to M and sign it with private key K
+	The signature is written to M.sig; M defaults to migrations.manifest`
+	verifySignatureUsage = `verify-signature -key K [-manifest M]  Verify the signature of manifest M with public key K
+	and check the migrations of -source against it`
+	seedUsage = `seed up [N] | down [N] [-all] | status  Apply, roll back or list the seeds of -seed-source
+	Seeds keep their own history in -seed-table; seed up needs all schema migrations applied`
 )
 
 func handleSubCmdHelp(help bool, usage string, flagSet *flag.FlagSet) {
@@ -55,7 +74,7 @@ func printUsageAndExit() {
 
 	// If a command is not found we exit with a status 2 to match the behavior
 	// of flag.Parse() with flag.ExitOnError when parsing an invalid flag.
-	os.Exit(2)
+	os.Exit(exitUsage)
 }
 
 // Main function of a cli application. It is public for backwards compatibility with `cli` package
@@ -68,6 +87,19 @@ func Main(version string) {
 	pathPtr := flag.String("path", "", "")
 	databasePtr := flag.String("database", "", "")
 	sourcePtr := flag.String("source", "", "")
+	seedSourcePtr := flag.String("seed-source", "", "")
+	seedPathPtr := flag.String("seed-path", "", "")
+	seedTablePtr := flag.String("seed-table", migrate.DefaultSeedTable, "")
+	varsFilePtr := flag.String("vars-file", "", "")
+	renderPtr := flag.Bool("render", false, "")
+	outputPtr := flag.String("output", outputText, "")
+	exitNoChangePtr := flag.Bool("exit-no-change", false, "")
+	flag.String("config", "", "")
+	envPtr := flag.String("env", "", "")
+	protectedPtr := flag.Bool("protected", false, "")
+	yesPtr := flag.Bool("yes", false, "")
+	cliVars := varsFlag{}
+	flag.Var(cliVars, "var", "")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr,
@@ -80,10 +112,29 @@ Options:
   -database        Run migrations against this database (driver://url)
   -prefetch N      Number of migrations to load in advance before executing (default 10)
   -lock-timeout N  Allow N seconds to acquire database lock (default 15)
+  -seed-source     Location of the seeds (driver://url)
+  -seed-path       Shorthand for -seed-source=file://path
+  -seed-table      History table of the seeds (default seed_migrations)
+  -var K=V         Render migrations as Go templates, setting variable K to V (repeatable)
+  -vars-file F     Render migrations as Go templates, reading K=V lines from F
+  -render          Render migrations as Go templates, e.g. with variables from the environment only
+                   Once rendering is enabled, variables are also read from MIGRATE_VAR_K environment variables
+  -output F        Print the result of up, down, do, undo, goto, force, version and seed as text or json (default text)
+  -exit-no-change  Exit with code 3 instead of 0 if there was nothing to migrate
+  -config F        Read options from YAML or TOML file F (default migrate.yaml, migrate.yml or migrate.toml if present)
+  -env E           Use the options of environment E of the config file
+  -protected       Ask to type the environment name before down, undo, force, drop and goto to a lower version,
+                   and refuse down -all; usually set per environment in the config file, where it can't be overridden
+  -yes             Confirm these commands on a protected database without asking
   -verbose         Print verbose logging
   -version         Print version
   -help            Print usage
 
+Options not given on the command line are read from the -env environment of the config file,
+then from MIGRATE_* environment variables, e.g. MIGRATE_LOCK_TIMEOUT for -lock-timeout,
+then from the top level of the config file.
+Database URLs may reference secrets as ${env:NAME}, or be read from a file given as file:PATH.
+
 Commands:
   %s
   %s
@@ -91,17 +142,51 @@ Commands:
   %s
   %s
   %s
+  %s
+  %s
+  %s
+  %s
   version      Print current migration version
 
+Exit codes:
+  0  Success
+  1  Error
+  2  Usage error
+  3  No change, with -exit-no-change
+  4  Dirty database
+  5  Database lock timeout
+  6  Missing migration version, or no applied version
+  7  Migration failed
+  8  Some targets failed, with up -targets
+
 Source drivers: `+strings.Join(source.List(), ", ")+`
-Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoUsage, upUsage, downUsage, dropUsage, forceUsage)
+Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoUsage, upUsage, planUsage, downUsage, dropUsage, forceUsage, signUsage, verifySignatureUsage, seedUsage)
 	}
 
 	flag.Parse()
 
+	startTime := time.Now()
+
+	if err := loadFlags(flag.CommandLine, os.LookupEnv); err != nil {
+		log.fatalErr(err)
+	}
+	databaseURL, err := resolveSecrets(*databasePtr, os.LookupEnv)
+	if err != nil {
+		log.fatalErr(err)
+	}
+	*databasePtr = databaseURL
+	g := &guard{protected: *protectedPtr, yes: *yesPtr, env: *envPtr, in: os.Stdin}
+
 	// initialize logger
 	log.verbose = *verbosePtr
 
+	if *outputPtr != outputText && *outputPtr != outputJSON {
+		log.fatalErr(errInvalidOutput)
+	}
+	report := func(command string) *reporter {
+		return newReporter(command, *outputPtr, *exitNoChangePtr, startTime)
+	}
+
 	// show cli version
 	if *versionPtr {
 		fmt.Fprintln(os.Stderr, version)
@@ -118,6 +203,37 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 	if *sourcePtr == "" && *pathPtr != "" {
 		*sourcePtr = fmt.Sprintf("file://%v", *pathPtr)
 	}
+	if *seedSourcePtr == "" && *seedPathPtr != "" {
+		*seedSourcePtr = fmt.Sprintf("file://%v", *seedPathPtr)
+	}
+
+	vars, err := templateVars(*renderPtr, *varsFilePtr, cliVars)
+	if err != nil {
+		log.fatalErr(err)
+	}
+
+	// handle Ctrl+c, stopping every Migrate instance set up below
+	running := newStopper()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT)
+	defer signal.Stop(signals)
+	go func() {
+		for range signals {
+			log.Println("Stopping after this running migration ...")
+			running.stop()
+			return
+		}
+	}()
+
+	setup := func(m *migrate.Migrate) {
+		m.Log = log
+		m.PrefetchMigrations = *prefetchPtr
+		m.LockTimeout = time.Duration(int64(*lockTimeoutPtr)) * time.Second
+		if vars != nil {
+			m.Renderer = migrate.NewTemplateRenderer(vars)
+		}
+		running.add(m)
+	}
 
 	// initialize migrate
 	// don't catch migraterErr here and let each command decide
@@ -131,24 +247,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 		}
 	}()
 	if migraterErr == nil {
-		migrater.Log = log
-		migrater.PrefetchMigrations = *prefetchPtr
-		migrater.LockTimeout = time.Duration(int64(*lockTimeoutPtr)) * time.Second
-
-		// handle Ctrl+c
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, syscall.SIGINT)
-		go func() {
-			for range signals {
-				log.Println("Stopping after this running migration ...")
-				migrater.GracefulStop <- true
-				return
-			}
-		}()
+		setup(migrater)
 	}
 
-	startTime := time.Now()
-
 	if len(flag.Args()) < 1 {
 		printUsageAndExit()
 	}
@@ -167,6 +268,10 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 		timezoneName := createFlagSet.String("tz", defaultTimezone, `The timezone that will be used for generating timestamps (default: utc)`)
 		createFlagSet.BoolVar(&seq, "seq", seq, "Use sequential numbers instead of timestamps (default: false)")
 		createFlagSet.IntVar(&seqDigits, "digits", seqDigits, "The number of digits to use in sequences (default: 6)")
+		templatePtr := createFlagSet.String("template", "", "Directory of the skeletons up.<ext>, down.<ext> and single.<ext>")
+		singleFilePtr := createFlagSet.Bool("single-file", false, "Create one file with +migrate Up and Down sections (default: false)")
+		fromDiffPtr := createFlagSet.String("from-diff", "", "Shell command printing the body of the migration, run with MIGRATE_DIRECTION=up and down")
+		outOfOrderPtr := createFlagSet.Bool("out-of-order", false, "Allow versions below the latest applied version (default: false)")
 
 		if err := createFlagSet.Parse(args); err != nil {
 			log.fatalErr(err)
@@ -188,7 +293,24 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.fatal(err)
 		}
 
-		if err := createCmd(*dirPtr, startTime.In(timezone), *formatPtr, name, *extPtr, seq, seqDigits, true); err != nil {
+		opts := createOptions{
+			templateDir: *templatePtr,
+			singleFile:  *singleFilePtr,
+			fromDiff:    *fromDiffPtr,
+			latest:      -1,
+			outOfOrder:  *outOfOrderPtr,
+		}
+		if migraterErr == nil {
+			latest, err := migrater.LatestApplied()
+			if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+				log.fatalErr(err)
+			}
+			if err == nil {
+				opts.latest = int64(latest)
+			}
+		}
+
+		if err := createCmd(*dirPtr, startTime.In(timezone), *formatPtr, name, *extPtr, seq, seqDigits, true, opts); err != nil {
 			log.fatalErr(err)
 		}
 
@@ -202,8 +324,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, gotoUsage, gotoSet)
 
+		out := report("goto")
 		if migraterErr != nil {
-			log.fatalErr(migraterErr)
+			out.finish(nil, migraterErr)
 		}
 
 		if gotoSet.NArg() == 0 {
@@ -215,16 +338,23 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.fatal("error: can't read version argument V")
 		}
 
-		if err := gotoCmd(migrater, uint(v)); err != nil {
-			log.fatalErr(err)
+		if err := g.confirmDown(migrater, fmt.Sprintf("migrate to version %d", v), -1, func(version uint) bool { return version > uint(v) }); err != nil {
+			out.finish(migrater, err)
 		}
 
+		out.watch(migrater)
+		out.finish(migrater, gotoCmd(migrater, uint(v)))
+
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
 		}
 
 	case "up":
 		upSet, helpPtr := newFlagSetWithHelp("up")
+		tagsPtr := upSet.String("tags", "", "Comma-separated tags to select, prefixed with ! to exclude")
+		targetsPtr := upSet.String("targets", "", "File of the database URLs to migrate, one per line")
+		parallelPtr := upSet.Int("parallel", 1, "Number of targets migrated at the same time")
+		continuePtr := upSet.Bool("continue-on-error", false, "Keep migrating targets after one failed (default: false)")
 
 		if err := upSet.Parse(args); err != nil {
 			log.fatalErr(err)
@@ -232,10 +362,6 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, upUsage, upSet)
 
-		if migraterErr != nil {
-			log.fatalErr(migraterErr)
-		}
-
 		limit := -1
 		if upSet.NArg() > 0 {
 			n, err := strconv.ParseUint(upSet.Arg(0), 10, 64)
@@ -245,14 +371,83 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			limit = int(n)
 		}
 
-		if err := upCmd(migrater, limit); err != nil {
+		tags, err := migrate.ParseTagFilter(*tagsPtr)
+		if err != nil {
 			log.fatalErr(err)
 		}
 
+		out := report("up")
+		fanOut := &migrate.FanOutConfig{
+			Parallel:        *parallelPtr,
+			ContinueOnError: *continuePtr,
+			Setup: func(target migrate.Target, m *migrate.Migrate) {
+				setup(m)
+				m.Log = &prefixLog{prefix: target.Name + ": ", log: log}
+				m.Tags = tags
+			},
+			Run: func(m *migrate.Migrate) error {
+				defer running.remove(m)
+				return upCmd(m, limit)
+			},
+		}
+		if *targetsPtr != "" {
+			targets, err := readTargets(*targetsPtr)
+			if err != nil {
+				log.fatalErr(err)
+			}
+			out.finishTargets(migrate.FanOut(*sourcePtr, targets, fanOut))
+			break
+		}
+		if f, ok := fanOutFor(*databasePtr); ok {
+			out.finishTargets(f.fanOut(*sourcePtr, *databasePtr, fanOut))
+			break
+		}
+
+		if migraterErr != nil {
+			out.finish(nil, migraterErr)
+		}
+		migrater.Tags = tags
+
+		out.watch(migrater)
+		out.finish(migrater, upCmd(migrater, limit))
+
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
 		}
 
+	case "plan":
+		planSet, helpPtr := newFlagSetWithHelp("plan")
+		tagsPtr := planSet.String("tags", "", "Comma-separated tags to select, prefixed with ! to exclude")
+
+		if err := planSet.Parse(args); err != nil {
+			log.fatalErr(err)
+		}
+
+		handleSubCmdHelp(*helpPtr, planUsage, planSet)
+
+		if migraterErr != nil {
+			log.fatalErr(migraterErr)
+		}
+
+		limit := -1
+		if planSet.NArg() > 0 {
+			n, err := strconv.ParseUint(planSet.Arg(0), 10, 64)
+			if err != nil {
+				log.fatal("error: can't read limit argument N")
+			}
+			limit = int(n)
+		}
+
+		tags, err := migrate.ParseTagFilter(*tagsPtr)
+		if err != nil {
+			log.fatalErr(err)
+		}
+		migrater.Tags = tags
+
+		if err := planCmd(migrater, limit); err != nil {
+			log.fatalErr(err)
+		}
+
 	case "do":
 		doSet, helpPtr := newFlagSetWithHelp("do")
 
@@ -262,8 +457,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, forceUsage, doSet)
 
+		out := report("do")
 		if migraterErr != nil {
-			log.fatalErr(migraterErr)
+			out.finish(nil, migraterErr)
 		}
 
 		if doSet.NArg() == 0 {
@@ -279,9 +475,8 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.fatal("error: argument V must be >= -1")
 		}
 
-		if err := doMigrationCmd(migrater, uint(v)); err != nil {
-			log.fatalErr(err)
-		}
+		out.watch(migrater)
+		out.finish(migrater, doMigrationCmd(migrater, uint(v)))
 
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
@@ -296,8 +491,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, forceUsage, undoSet)
 
+		out := report("undo")
 		if migraterErr != nil {
-			log.fatalErr(migraterErr)
+			out.finish(nil, migraterErr)
 		}
 
 		if undoSet.NArg() == 0 {
@@ -313,10 +509,13 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.fatal("error: argument V must be >= -1")
 		}
 
-		if err := undoMigrationCmd(migrater, uint(v)); err != nil {
-			log.fatalErr(err)
+		if err := g.confirmDown(migrater, fmt.Sprintf("undo migration %d", v), -1, func(version uint) bool { return version == uint(v) }); err != nil {
+			out.finish(migrater, err)
 		}
 
+		out.watch(migrater)
+		out.finish(migrater, undoMigrationCmd(migrater, uint(v)))
+
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
 		}
@@ -331,8 +530,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, downUsage, downFlagSet)
 
+		out := report("down")
 		if migraterErr != nil {
-			log.fatalErr(migraterErr)
+			out.finish(nil, migraterErr)
 		}
 
 		downArgs := downFlagSet.Args()
@@ -340,6 +540,11 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 		if err != nil {
 			log.fatalErr(err)
 		}
+		if num == -1 {
+			if err := g.refuseDownAll(); err != nil {
+				out.finish(migrater, err)
+			}
+		}
 		if needsConfirm {
 			log.Println("Are you sure you want to apply all down migrations? [y/N]")
 			var response string
@@ -353,10 +558,13 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			}
 		}
 
-		if err := downCmd(migrater, num); err != nil {
-			log.fatalErr(err)
+		if err := g.confirmDown(migrater, fmt.Sprintf("roll back %d migrations", num), num, nil); err != nil {
+			out.finish(migrater, err)
 		}
 
+		out.watch(migrater)
+		out.finish(migrater, downCmd(migrater, num))
+
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
 		}
@@ -371,7 +579,11 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*help, dropUsage, dropFlagSet)
 
-		if !*forceDrop {
+		if g.protected {
+			if err := g.confirm("drop everything", []string{"drop everything inside the database"}); err != nil {
+				log.fatalErr(err)
+			}
+		} else if !*forceDrop {
 			log.Println("Are you sure you want to drop the entire database schema? [y/N]")
 			var response string
 			_, _ = fmt.Scanln(&response)
@@ -396,6 +608,44 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.Println("Finished after", time.Since(startTime))
 		}
 
+	case "sign":
+		signSet, helpPtr := newFlagSetWithHelp("sign")
+		keyPtr := signSet.String("key", "", "Private ed25519 key")
+		manifestPtr := signSet.String("manifest", defaultManifest, "Manifest to write")
+
+		if err := signSet.Parse(args); err != nil {
+			log.fatalErr(err)
+		}
+
+		handleSubCmdHelp(*helpPtr, signUsage, signSet)
+
+		if *keyPtr == "" {
+			log.fatal("error: please specify the private key with -key")
+		}
+
+		if err := signCmd(*sourcePtr, *keyPtr, *manifestPtr); err != nil {
+			log.fatalErr(err)
+		}
+
+	case "verify-signature":
+		verifySet, helpPtr := newFlagSetWithHelp("verify-signature")
+		keyPtr := verifySet.String("key", "", "Public ed25519 key")
+		manifestPtr := verifySet.String("manifest", defaultManifest, "Manifest to verify")
+
+		if err := verifySet.Parse(args); err != nil {
+			log.fatalErr(err)
+		}
+
+		handleSubCmdHelp(*helpPtr, verifySignatureUsage, verifySet)
+
+		if *keyPtr == "" {
+			log.fatal("error: please specify the public key with -key")
+		}
+
+		if err := verifySignatureCmd(*sourcePtr, *keyPtr, *manifestPtr); err != nil {
+			log.fatalErr(err)
+		}
+
 	case "force":
 		forceSet, helpPtr := newFlagSetWithHelp("force")
 
@@ -405,8 +655,9 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 
 		handleSubCmdHelp(*helpPtr, forceUsage, forceSet)
 
+		out := report("force")
 		if migraterErr != nil {
-			log.fatalErr(migraterErr)
+			out.finish(nil, migraterErr)
 		}
 
 		if forceSet.NArg() == 0 {
@@ -422,22 +673,124 @@ Database drivers: `+strings.Join(database.List(), ", ")+"\n", createUsage, gotoU
 			log.fatal("error: argument V must be >= -1")
 		}
 
-		if err := forceCmd(migrater, int(v)); err != nil {
-			log.fatalErr(err)
+		if err := g.confirm(fmt.Sprintf("force version %d", v), []string{fmt.Sprintf("set version %d without running migrations", v)}); err != nil {
+			out.finish(migrater, err)
 		}
 
+		out.finish(migrater, forceCmd(migrater, int(v)))
+
 		if log.verbose {
 			log.Println("Finished after", time.Since(startTime))
 		}
 
-	case "version":
+	case "seed":
+		seedSet, helpPtr := newFlagSetWithHelp("seed")
+
+		if err := seedSet.Parse(args); err != nil {
+			log.fatalErr(err)
+		}
+
+		handleSubCmdHelp(*helpPtr, seedUsage, seedSet)
+
+		if seedSet.NArg() == 0 {
+			log.fatal("error: please specify seed up, down or status")
+		}
+		if *seedSourcePtr == "" {
+			log.fatal("error: please specify the seeds with -seed-source or -seed-path")
+		}
 		if migraterErr != nil {
 			log.fatalErr(migraterErr)
 		}
 
-		if err := versionCmd(migrater); err != nil {
+		seeder, err := migrate.NewSeed(*seedSourcePtr, *databasePtr, *seedTablePtr)
+		if err != nil {
 			log.fatalErr(err)
 		}
+		defer func() {
+			if _, err := seeder.Close(); err != nil {
+				log.Println(err)
+			}
+		}()
+		setup(seeder)
+
+		seedArgs := seedSet.Args()[1:]
+		switch seedSet.Arg(0) {
+		case "up":
+			limit := -1
+			if len(seedArgs) > 0 {
+				n, err := strconv.ParseUint(seedArgs[0], 10, 64)
+				if err != nil {
+					log.fatal("error: can't read limit argument N")
+				}
+				limit = int(n)
+			}
+
+			out := report("seed up")
+			out.watch(seeder)
+			out.finish(seeder, seedUpCmd(migrater, seeder, limit))
+
+		case "down":
+			seedDownSet, _ := newFlagSetWithHelp("seed down")
+			applyAll := seedDownSet.Bool("all", false, "Apply all down seeds")
+			if err := seedDownSet.Parse(seedArgs); err != nil {
+				log.fatalErr(err)
+			}
+
+			num, needsConfirm, err := numDownMigrationsFromArgs(*applyAll, seedDownSet.Args())
+			if err != nil {
+				log.fatalErr(err)
+			}
+			if num == -1 {
+				if err := g.refuseDownAll(); err != nil {
+					log.fatalErr(err)
+				}
+			}
+			if needsConfirm {
+				log.Println("Are you sure you want to apply all down seeds? [y/N]")
+				var response string
+				_, _ = fmt.Scanln(&response)
+				response = strings.ToLower(strings.TrimSpace(response))
+
+				if response == "y" {
+					log.Println("Applying all down seeds")
+				} else {
+					log.fatal("Not applying all down seeds")
+				}
+			}
+
+			if err := g.confirmDown(seeder, fmt.Sprintf("roll back %d seeds", num), num, nil); err != nil {
+				log.fatalErr(err)
+			}
+
+			out := report("seed down")
+			out.watch(seeder)
+			out.finish(seeder, downCmd(seeder, num))
+
+		case "status":
+			if err := statusCmd(seeder); err != nil {
+				log.fatalErr(err)
+			}
+
+		default:
+			log.fatal("error: please specify seed up, down or status")
+		}
+
+		if log.verbose {
+			log.Println("Finished after", time.Since(startTime))
+		}
+
+	case "version":
+		out := report("version")
+		if migraterErr != nil {
+			out.finish(nil, migraterErr)
+		}
+
+		if out.json {
+			_, _, err := migrater.Version()
+			out.finish(migrater, err)
+		} else if err := versionCmd(migrater); err != nil {
+			out.finish(migrater, err)
+		}
 
 	default:
 		printUsageAndExit()
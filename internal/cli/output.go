@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abramad-labs/histomigrate"
+	"github.com/abramad-labs/histomigrate/database"
+)
+
+// Exit codes of the migration commands, stable so that scripts can branch on them.
+const (
+	exitError           = 1
+	exitUsage           = 2
+	exitNoChange        = 3
+	exitDirty           = 4
+	exitLockTimeout     = 5
+	exitMissingVersion  = 6
+	exitMigrationFailed = 7
+	exitTargetsFailed   = 8
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var errInvalidOutput = errors.New("Output must be text or json")
+
+// result is the outcome of a migration command printed by -output json.
+type result struct {
+	Command    string            `json:"command"`
+	Status     string            `json:"status"`
+	ExitCode   int               `json:"exit_code"`
+	Error      string            `json:"error,omitempty"`
+	Version    *uint             `json:"version"`
+	Dirty      bool              `json:"dirty"`
+	Migrations []migrationResult `json:"migrations"`
+	DurationMS float64           `json:"duration_ms"`
+}
+
+type migrationResult struct {
+	Version    uint    `json:"version,omitempty"`
+	Identifier string  `json:"identifier"`
+	Direction  string  `json:"direction"`
+	Repeatable bool    `json:"repeatable,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// targetsResult is the outcome of up -targets printed by -output json.
+type targetsResult struct {
+	Command    string         `json:"command"`
+	Status     string         `json:"status"`
+	ExitCode   int            `json:"exit_code"`
+	Error      string         `json:"error,omitempty"`
+	Targets    []targetResult `json:"targets"`
+	Behind     []string       `json:"behind"`
+	DurationMS float64        `json:"duration_ms"`
+}
+
+type targetResult struct {
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Version    *int              `json:"version"`
+	Dirty      bool              `json:"dirty"`
+	Pending    int               `json:"pending"`
+	Migrations []migrationResult `json:"migrations"`
+	DurationMS float64           `json:"duration_ms"`
+}
+
+// reporter collects the migrations run by a command and reports its outcome
+// in the format given by -output.
+type reporter struct {
+	command      string
+	json         bool
+	exitNoChange bool
+	start        time.Time
+	migrations   []migrationResult
+	failed       bool
+}
+
+func newReporter(command string, output string, exitNoChange bool, start time.Time) *reporter {
+	return &reporter{
+		command:      command,
+		json:         output == outputJSON,
+		exitNoChange: exitNoChange,
+		start:        start,
+		migrations:   []migrationResult{},
+	}
+}
+
+// watch records the migrations run by m.
+func (r *reporter) watch(m *migrate.Migrate) {
+	m.Report = func(mr migrate.MigrationReport) {
+		if mr.Err != nil {
+			r.failed = true
+		}
+		r.migrations = append(r.migrations, newMigrationResult(mr))
+	}
+}
+
+func newMigrationResult(mr migrate.MigrationReport) migrationResult {
+	res := migrationResult{
+		Version:    mr.Version,
+		Identifier: mr.Identifier,
+		Direction:  string(mr.Direction),
+		Repeatable: mr.Repeatable,
+		DurationMS: milliseconds(mr.Duration),
+	}
+	if mr.Err != nil {
+		res.Error = mr.Err.Error()
+	}
+	return res
+}
+
+// exitCode returns the exit code of a command that returned err.
+func (r *reporter) exitCode(err error) int {
+	var errDirty migrate.ErrDirty
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, migrate.ErrNoChange):
+		if r.exitNoChange {
+			return exitNoChange
+		}
+		return 0
+	case r.failed:
+		return exitMigrationFailed
+	case errors.As(err, &errDirty):
+		return exitDirty
+	case errors.Is(err, migrate.ErrLockTimeout), errors.Is(err, migrate.ErrLocked), errors.Is(err, database.ErrLocked):
+		return exitLockTimeout
+	case errors.Is(err, migrate.ErrMissingVersion), errors.Is(err, migrate.ErrNilVersion):
+		return exitMissingVersion
+	default:
+		return exitError
+	}
+}
+
+// result returns the outcome of the command, reading the version from m unless it is nil.
+func (r *reporter) result(m *migrate.Migrate, err error) result {
+	res := result{
+		Command:    r.command,
+		Status:     "ok",
+		ExitCode:   r.exitCode(err),
+		Migrations: r.migrations,
+		DurationMS: milliseconds(time.Since(r.start)),
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		res.Status = "no_change"
+	} else if err != nil {
+		res.Status = "error"
+		res.Error = err.Error()
+	}
+	if m != nil {
+		if v, dirty, errVersion := m.Version(); errVersion == nil {
+			res.Version = &v
+			res.Dirty = dirty
+		}
+	}
+	return res
+}
+
+// finish reports the outcome of the command and exits with its exit code unless it is 0.
+func (r *reporter) finish(m *migrate.Migrate, err error) {
+	res := r.result(m, err)
+	if r.json {
+		if errWrite := writeResult(os.Stdout, res); errWrite != nil {
+			log.fatalErr(errWrite)
+		}
+	} else if errors.Is(err, migrate.ErrNoChange) {
+		log.Println(err)
+	} else if err != nil {
+		log.Println("error:", err)
+	}
+
+	if res.ExitCode != 0 {
+		os.Exit(res.ExitCode)
+	}
+}
+
+// targetsResult returns the outcome of up -targets.
+func (r *reporter) targetsResult(results []migrate.TargetResult, err error) targetsResult {
+	res := targetsResult{
+		Command:    r.command,
+		Status:     "ok",
+		Targets:    []targetResult{},
+		Behind:     []string{},
+		DurationMS: milliseconds(time.Since(r.start)),
+	}
+	ran := false
+	for _, tr := range results {
+		target := targetResult{
+			Name:       tr.Target.Name,
+			Status:     "ok",
+			Dirty:      tr.Dirty,
+			Pending:    tr.Pending,
+			Migrations: []migrationResult{},
+			DurationMS: milliseconds(tr.Duration),
+		}
+		if tr.Version != database.NilVersion {
+			version := tr.Version
+			target.Version = &version
+		}
+		for _, mr := range tr.Migrations {
+			target.Migrations = append(target.Migrations, newMigrationResult(mr))
+		}
+		switch {
+		case errors.Is(tr.Err, migrate.ErrTargetSkipped):
+			target.Status = "skipped"
+		case tr.Err != nil:
+			target.Status = "error"
+			target.Error = tr.Err.Error()
+		case len(tr.Migrations) == 0:
+			target.Status = "no_change"
+		}
+		ran = ran || len(tr.Migrations) > 0
+		if tr.Behind() {
+			res.Behind = append(res.Behind, tr.Target.Name)
+		}
+		res.Targets = append(res.Targets, target)
+	}
+
+	switch {
+	case err != nil:
+		res.Status = "error"
+		res.Error = err.Error()
+		res.ExitCode = exitTargetsFailed
+	case !ran:
+		res.Status = "no_change"
+		if r.exitNoChange {
+			res.ExitCode = exitNoChange
+		}
+	}
+	return res
+}
+
+// finishTargets reports the outcome of up -targets and exits with its exit code unless it is 0.
+func (r *reporter) finishTargets(results []migrate.TargetResult, err error) {
+	res := r.targetsResult(results, err)
+	if r.json {
+		if errWrite := writeResult(os.Stdout, res); errWrite != nil {
+			log.fatalErr(errWrite)
+		}
+	} else {
+		for _, target := range res.Targets {
+			line := target.Name + ": " + strings.ReplaceAll(target.Status, "_", " ")
+			if target.Error != "" {
+				line += ": " + target.Error
+			}
+			if len(target.Migrations) > 0 {
+				line += fmt.Sprintf(", %d migrations", len(target.Migrations))
+			}
+			if target.Version != nil {
+				line += fmt.Sprintf(", version %d", *target.Version)
+			}
+			if target.Dirty {
+				line += " (dirty)"
+			}
+			if target.Pending > 0 {
+				line += fmt.Sprintf(", %d pending", target.Pending)
+			}
+			log.Println(line)
+		}
+		log.Printf("%d of %d targets up to date\n", len(res.Targets)-len(res.Behind), len(res.Targets))
+		if len(res.Behind) > 0 {
+			log.Println("Behind:", strings.Join(res.Behind, ", "))
+		}
+	}
+
+	if res.ExitCode != 0 {
+		os.Exit(res.ExitCode)
+	}
+}
+
+func writeResult(w io.Writer, res interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"sync"
+
+	"github.com/abramad-labs/histomigrate"
+)
+
+// stopper stops the running Migrate instances gracefully, e.g. on Ctrl+c.
+// Instances added once it has stopped are stopped right away.
+type stopper struct {
+	mu        sync.Mutex
+	stopped   bool
+	instances map[*migrate.Migrate]struct{}
+}
+
+func newStopper() *stopper {
+	return &stopper{instances: map[*migrate.Migrate]struct{}{}}
+}
+
+// add starts tracking m until remove is called.
+func (s *stopper) add(m *migrate.Migrate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		m.GracefulStop <- true
+		return
+	}
+	s.instances[m] = struct{}{}
+}
+
+// remove stops tracking m, e.g. once it is done.
+func (s *stopper) remove(m *migrate.Migrate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.instances, m)
+}
+
+// stop asks every tracked instance to stop after its running migration.
+func (s *stopper) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	for m := range s.instances {
+		m.GracefulStop <- true
+	}
+	s.instances = nil
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/abramad-labs/histomigrate"
+)
+
+func TestStopper(t *testing.T) {
+	newMigrate := func() *migrate.Migrate {
+		return &migrate.Migrate{GracefulStop: make(chan bool, 1)}
+	}
+	stopped := func(m *migrate.Migrate) bool {
+		select {
+		case <-m.GracefulStop:
+			return true
+		default:
+			return false
+		}
+	}
+
+	s := newStopper()
+	running, done := newMigrate(), newMigrate()
+	s.add(running)
+	s.add(done)
+	s.remove(done)
+
+	s.stop()
+	s.stop()
+	if !stopped(running) {
+		t.Error("expected the running instance to be stopped")
+	}
+	if stopped(running) {
+		t.Error("expected the running instance to be stopped once")
+	}
+	if stopped(done) {
+		t.Error("expected the removed instance not to be stopped")
+	}
+
+	late := newMigrate()
+	s.add(late)
+	if !stopped(late) {
+		t.Error("expected an instance added after stopping to be stopped")
+	}
+}
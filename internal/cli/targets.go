@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate"
+	iurl "github.com/abramad-labs/histomigrate/internal/url"
+)
+
+var errNoTargets = errors.New("No targets given")
+
+// urlFanOut migrates the many databases selected by a single database URL,
+// e.g. the schemas of a postgres URL with x-schemas.
+type urlFanOut struct {
+	// selects reports whether the database URL selects many databases.
+	selects func(databaseURL string) bool
+	fanOut  func(sourceURL string, databaseURL string, config *migrate.FanOutConfig) ([]migrate.TargetResult, error)
+}
+
+// urlFanOuts are keyed by URL scheme. The database drivers built in register theirs.
+var urlFanOuts = map[string]urlFanOut{}
+
+// fanOutFor returns the fan-out of databaseURL, and false if it selects a single database.
+func fanOutFor(databaseURL string) (urlFanOut, bool) {
+	scheme, err := iurl.SchemeFromURL(databaseURL)
+	if err != nil {
+		return urlFanOut{}, false
+	}
+	f, ok := urlFanOuts[scheme]
+	if !ok || !f.selects(databaseURL) {
+		return urlFanOut{}, false
+	}
+	return f, true
+}
+
+// readTargets reads the targets of up -targets from a file, see parseTargets.
+func readTargets(path string) ([]migrate.Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	targets, err := parseTargets(f, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// parseTargets reads one database URL per line, optionally preceded by a name and whitespace, e.g.
+//
+//	# tenants
+//	acme postgres://app:${env:PGPASSWORD}@db-1:5432/acme
+//	postgres://app:${env:PGPASSWORD}@db-2:5432/globex
+//
+// Secrets are resolved as for -database. Targets without a name are named after their URL, without password.
+func parseTargets(r io.Reader, lookupEnv func(string) (string, bool)) ([]migrate.Target, error) {
+	var targets []migrate.Target
+	names := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var target migrate.Target
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			target.DatabaseURL = fields[0]
+		case 2:
+			target.Name, target.DatabaseURL = fields[0], fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: expected [NAME] URL", lineNo)
+		}
+
+		var err error
+		if target.DatabaseURL, err = resolveSecrets(target.DatabaseURL, lookupEnv); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if target.Name == "" {
+			u, err := nurl.Parse(target.DatabaseURL)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			target.Name = u.Redacted()
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("line %d: duplicate target %s", lineNo, target.Name)
+		}
+		names[target.Name] = true
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errNoTargets
+	}
+	return targets, nil
+}
@@ -34,6 +34,10 @@ var (
 	ErrInvalidVersion = errors.New("version must be >= -1")
 	ErrLocked         = errors.New("database locked")
 	ErrLockTimeout    = errors.New("timeout: can't acquire database lock")
+
+	// ErrMissingVersion wraps os.ErrNotExist when the source has no migration of a version,
+	// or not enough migrations left for a number of steps.
+	ErrMissingVersion = errors.New("no migration found")
 )
 
 // ErrShortLimit is an error returned when not enough migrations
@@ -80,6 +84,18 @@ type Migrate struct {
 	// LockTimeout defaults to DefaultLockTimeout,
 	// but can be set per Migrate instance.
 	LockTimeout time.Duration
+
+	// Tags selects the migrations applied by Up and Steps.
+	// Migrations it doesn't select are skipped and stay pending.
+	Tags TagFilter
+
+	// Renderer, if set, renders every migration read from the source
+	// before it is run, see TemplateRenderer.
+	Renderer Renderer
+
+	// Report, if set, is called after every migration run by the instance,
+	// including the one that failed, e.g. to collect a summary.
+	Report func(MigrationReport)
 }
 
 // New returns a new Migrate instance from a source URL and a database URL.
@@ -277,6 +293,10 @@ func (m *Migrate) Steps(n int) error {
 			return m.unlockErr(ErrDirty{curVersion})
 		}
 
+		if n > 0 && !m.Tags.IsZero() {
+			return m.unlockErr(ErrTagsNotSupported)
+		}
+
 		if n > 0 {
 			go m.readUp(curVersion, n, ret)
 		} else {
@@ -289,11 +309,17 @@ func (m *Migrate) Steps(n int) error {
 
 // Up looks at the currently active migration version
 // and will migrate all the way up (applying all up migrations).
+// Repeatable migrations whose content changed run afterwards; if the database driver
+// can't keep track of them, Up fails with ErrRepeatableNotSupported before migrating anything.
 func (m *Migrate) Up() error {
 	if err := m.lock(); err != nil {
 		return err
 	}
 
+	if err := m.checkRepeatables(); err != nil {
+		return m.unlockErr(err)
+	}
+
 	ret := make(chan interface{}, m.PrefetchMigrations)
 
 	ed, isExtended := m.databaseDrv.(database.ExtendedDriver)
@@ -325,10 +351,33 @@ func (m *Migrate) Up() error {
 			return m.unlockErr(ErrDirty{curVersion})
 		}
 
+		if !m.Tags.IsZero() {
+			return m.unlockErr(ErrTagsNotSupported)
+		}
+
 		go m.readUp(curVersion, -1, ret)
 	}
 
-	return m.unlockErr(m.runMigrations(ret))
+	return m.unlockErr(m.runUpMigrations(ret))
+}
+
+// runUpMigrations runs the versioned migrations received on ret and then all
+// repeatable migrations that changed since their last run.
+// It returns ErrNoChange only if neither had anything to run.
+func (m *Migrate) runUpMigrations(ret <-chan interface{}) error {
+	err := m.runMigrations(ret)
+	if err != nil && !errors.Is(err, ErrNoChange) {
+		return err
+	}
+	if m.stop() {
+		return nil
+	}
+
+	if errRepeatable := m.runRepeatables(); !errors.Is(errRepeatable, ErrNoChange) {
+		return errRepeatable
+	}
+
+	return err
 }
 
 // Down looks at the currently active migration version
@@ -475,6 +524,31 @@ func (m *Migrate) Version() (version uint, dirty bool, err error) {
 	return suint(v), d, nil
 }
 
+// LatestApplied returns the highest applied version, or ErrNilVersion if none has been applied.
+// With an ExtendedDriver, migrations applied out of order don't hide a higher version.
+func (m *Migrate) LatestApplied() (uint, error) {
+	ed, ok := m.databaseDrv.(database.ExtendedDriver)
+	if !ok {
+		version, _, err := m.Version()
+		return version, err
+	}
+
+	applied, err := m.appliedMigrations(ed)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, ErrNilVersion
+	}
+	latest := applied[0]
+	for _, v := range applied[1:] {
+		if v > latest {
+			latest = v
+		}
+	}
+	return uint(latest), nil
+}
+
 // read reads either up or down migrations from source `from` to `to`.
 // Each migration is then written to the ret channel.
 // If an error occurs during reading, that error is written to the ret channel, too.
@@ -674,7 +748,7 @@ func (m *Migrate) readUp(from int, limit int, ret chan<- interface{}) {
 
 			// reached end, and didn't apply any migrations
 			if limit > 0 && count == 0 {
-				ret <- os.ErrNotExist
+				ret <- fmt.Errorf("%w: %w", ErrMissingVersion, os.ErrNotExist)
 				return
 			}
 
@@ -735,7 +809,7 @@ func (m *Migrate) readDown(from int, limit int, ret chan<- interface{}) {
 
 	// can't go over limit if already at nil version
 	if from == -1 && limit > 0 {
-		ret <- os.ErrNotExist
+		ret <- fmt.Errorf("%w: %w", ErrMissingVersion, os.ErrNotExist)
 		return
 	}
 
@@ -813,7 +887,10 @@ func (m *Migrate) runMigrations(ret <-chan interface{}) error {
 			return val
 
 		case *Migration:
-			if err := m.handleSingleMigration(val); err != nil {
+			start := time.Now()
+			err := m.handleSingleMigration(val)
+			m.report(val, start, err)
+			if err != nil {
 				return err // Error during migration execution
 			}
 
@@ -858,7 +935,7 @@ func (m *Migrate) versionExists(version uint) (result error) {
 		return err
 	}
 
-	err = fmt.Errorf("no migration found for version %d: %w", version, err)
+	err = fmt.Errorf("%w for version %d: %w", ErrMissingVersion, version, err)
 	m.logErr(err)
 	return err
 }
@@ -900,6 +977,9 @@ func (m *Migrate) newMigration(version uint, targetVersion int) (*Migration, err
 
 		} else {
 			// create migration from up source
+			if r, err = m.render(r, identifier); err != nil {
+				return nil, err
+			}
 			migr, err = NewMigration(r, identifier, version, targetVersion)
 			if err != nil {
 				return nil, err
@@ -920,6 +1000,9 @@ func (m *Migrate) newMigration(version uint, targetVersion int) (*Migration, err
 
 		} else {
 			// create migration from down source
+			if r, err = m.render(r, identifier); err != nil {
+				return nil, err
+			}
 			migr, err = NewMigration(r, identifier, version, targetVersion)
 			if err != nil {
 				return nil, err
@@ -938,7 +1021,19 @@ func (m *Migrate) newMigration(version uint, targetVersion int) (*Migration, err
 
 // lock is a thread safe helper function to lock the database.
 // It should be called as late as possible when running migrations.
+// Once locked, it backfills a legacy history, see database.LegacyHistoryDriver.
 func (m *Migrate) lock() error {
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	if err := m.backfillHistory(); err != nil {
+		return m.unlockErr(err)
+	}
+	return nil
+}
+
+// acquireLock locks the database within LockTimeout.
+func (m *Migrate) acquireLock() error {
 	m.isLockedMu.Lock()
 	defer m.isLockedMu.Unlock()
 
@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestUpDirectives(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":   {Data: []byte("CREATE 1")},
+		"2_index.up.sql":  {Data: []byte("-- histomigrate: no-transaction, timeout=1m, depends=1\nCREATE 2")},
+		"3_orders.up.sql": {Data: []byte("-- histomigrate: depends=4\nCREATE 3")},
+		"4_items.up.sql":  {Data: []byte("CREATE 4")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("expected ErrMissingDependency, got %v", err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE 1", "-- histomigrate: no-transaction, timeout=1m, depends=1\nCREATE 2"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+	if _, ok := dbDrv.Applied[3]; ok {
+		t.Error("expected migration 3 not to be recorded")
+	}
+
+	// once 4 is applied, 3 can run
+	if err := m.DoMigration(4); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if dirty, ok := dbDrv.Applied[3]; !ok || dirty || len(dbDrv.Applied) != 4 {
+		t.Errorf("expected all migrations to be applied, got %v", dbDrv.Applied)
+	}
+}
+
+func TestUpTimeoutNotSupported(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("-- histomigrate: timeout=1m\nCREATE 1")},
+	}
+
+	m, err := newMigrateWithFS(t, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); !errors.Is(err, ErrTimeoutNotSupported) {
+		t.Fatalf("expected ErrTimeoutNotSupported, got %v", err)
+	}
+}
+
+func TestUpNoTransactionNotSupported(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("-- histomigrate: no-transaction\nCREATE 1")},
+	}
+
+	m, err := newMigrateWithFS(t, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); !errors.Is(err, ErrNoTransactionNotSupported) {
+		t.Fatalf("expected ErrNoTransactionNotSupported, got %v", err)
+	}
+	if version, dirty, err := m.Version(); !errors.Is(err, ErrNilVersion) {
+		t.Errorf("expected the migration not to start, got version %d (dirty %v), %v", version, dirty, err)
+	}
+}
+
+func TestNewMigrationDirectives(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("-- histomigrate: unknown\nCREATE 1")},
+	}
+
+	m, err := newMigrateWithFS(t, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err == nil {
+		t.Fatal("expected an error for an unknown directive")
+	}
+}
+
+func newMigrateWithFS(t *testing.T, fsys fstest.MapFS) (*Migrate, error) {
+	t.Helper()
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	db, err := dStub.WithInstance(nil, &dStub.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return NewWithInstance("iofs", src, "stub", db)
+}
@@ -1,6 +1,7 @@
 package migrate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -9,6 +10,21 @@ import (
 	"github.com/abramad-labs/histomigrate/database"
 )
 
+// ErrFuncNotSupported is returned when a migration implemented in Go meets a database
+// driver that doesn't implement database.FuncDriver.
+var ErrFuncNotSupported = errors.New("database driver does not support migrations implemented in Go")
+
+// ErrTimeoutNotSupported is returned when a migration with a timeout directive meets a database
+// driver that can't cancel it, see database.ContextDriver.
+var ErrTimeoutNotSupported = errors.New("database driver does not support migration timeouts")
+
+// ErrNoTransactionNotSupported is returned when a migration with a no-transaction directive meets a database
+// driver that can't run it outside of a transaction, see database.DirectiveDriver.
+var ErrNoTransactionNotSupported = errors.New("database driver does not support migrations outside of a transaction")
+
+// ErrMissingDependency is returned when a migration depends on a version that isn't applied.
+var ErrMissingDependency = errors.New("missing dependency")
+
 // DoMigration executes a single database migration.
 // It acquires a lock, checks if the migration is already applied, queues it for processing (if not applied), runs it, and then releases the lock.
 // It requires an ExtendedDriver.
@@ -67,12 +83,125 @@ func (m *Migrate) UndoMigration(version uint) error {
 	return m.unlockErr(m.runMigrations(ret))
 }
 
+// MigrationStatus tells whether the migration of a version has been applied, see Status.
+type MigrationStatus struct {
+	Version    uint
+	Identifier string
+	Applied    bool
+}
+
+// Status returns the status of every migration of the source, in order.
+func (m *Migrate) Status() ([]MigrationStatus, error) {
+	pending, err := m.pendingFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatus
+	version, err := m.sourceDrv.First()
+	for err == nil {
+		identifier, errRead := m.identifier(version)
+		if errRead != nil {
+			return nil, errRead
+		}
+		status = append(status, MigrationStatus{Version: version, Identifier: identifier, Applied: !pending(version)})
+		version, err = m.sourceDrv.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// PlanDown returns the applied migrations in the order Steps(-limit) would roll them back, without running them.
+// limit can be -1, implying all applied migrations, as for Down.
+func (m *Migrate) PlanDown(limit int) ([]PlannedMigration, error) {
+	var applied []int
+	if ed, ok := m.databaseDrv.(database.ExtendedDriver); ok {
+		var err error
+		if applied, err = m.appliedMigrations(ed); err != nil {
+			return nil, err
+		}
+	} else {
+		curVersion, _, err := m.databaseDrv.Version()
+		if err != nil {
+			return nil, err
+		}
+		for version := curVersion; version >= 0 && (limit == -1 || len(applied) < limit); {
+			applied = append(applied, version)
+			prev, err := m.sourceDrv.Prev(uint(version))
+			if errors.Is(err, os.ErrNotExist) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			version = int(prev)
+		}
+	}
+
+	var plan []PlannedMigration
+	for _, version := range applied {
+		if limit != -1 && len(plan) >= limit {
+			break
+		}
+		identifier, err := m.identifier(uint(version))
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, PlannedMigration{Version: uint(version), Identifier: identifier})
+	}
+	return plan, nil
+}
+
+// pendingFunc returns a function reporting whether the migration of a version is pending.
+// With an ExtendedDriver, every migration not in the history is pending; otherwise,
+// every migration above the current version.
+func (m *Migrate) pendingFunc() (func(version uint) bool, error) {
+	if ed, ok := m.databaseDrv.(database.ExtendedDriver); ok {
+		applied, err := m.appliedMigrations(ed)
+		if err != nil {
+			return nil, err
+		}
+		appliedSet := make(map[uint]struct{}, len(applied))
+		for _, v := range applied {
+			appliedSet[uint(v)] = struct{}{}
+		}
+		return func(version uint) bool {
+			_, ok := appliedSet[version]
+			return !ok
+		}, nil
+	}
+
+	curVersion, _, err := m.databaseDrv.Version()
+	if err != nil {
+		return nil, err
+	}
+	return func(version uint) bool { return int(version) > curVersion }, nil
+}
+
+// identifier returns the identifier of the up migration of version, or of its down migration if it has none.
+func (m *Migrate) identifier(version uint) (string, error) {
+	r, identifier, err := m.sourceDrv.ReadUp(version)
+	if errors.Is(err, os.ErrNotExist) {
+		r, identifier, err = m.sourceDrv.ReadDown(version)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return identifier, r.Close()
+}
+
 // queueUpMigrations function is responsible for identifying and preparing "up" (forward) migrations that need to be applied.
 // It starts by determining the first available migration from a sourceDrv (source driver, likely a file system or similar).
 // It then iterates through subsequent migrations, skipping any that have already been applied (as indicated by the appliedMigrs list).
 // For each unapplied migration, it creates a Migration object, marks it as an "up" migration, and sends it to the ret channel for further processing.
 // The function also asynchronously buffers the migration's content in a separate goroutine.
 // It respects a limit on the number of new migrations to queue and can be stopped gracefully.
+// Migrations not selected by Migrate.Tags are skipped and don't count towards the limit.
 // If no new migrations are found or queued (and no background errors occur), it signals ErrNoChange.
 func (m *Migrate) queueUpMigrations(appliedMigrs []int, limit int, ret chan<- interface{}) {
 	defer close(ret)
@@ -113,22 +242,32 @@ func (m *Migrate) queueUpMigrations(appliedMigrs []int, limit int, ret chan<- in
 			continue
 		}
 
-		appliedCount++
 		migr, err := m.newMigration(targetVersion, int(targetVersion))
 		if err != nil {
 			ret <- err
 			return
 		}
 
-		migr.UpKindMigration = true
+		if m.Tags.Match(migr.Tags) {
+			appliedCount++
+			migr.UpKindMigration = true
 
-		ret <- migr
+			ret <- migr
 
-		go func(migr *Migration) {
-			if err := migr.Buffer(); err != nil {
-				m.logErr(err)
+			go func(migr *Migration) {
+				if err := migr.Buffer(); err != nil {
+					m.logErr(err)
+				}
+			}(migr)
+		} else {
+			m.logVerbosePrintf("Skipped %v, tags %v not selected by %q\n", migr.LogString(), migr.Tags, m.Tags.String())
+			if migr.Body != nil {
+				if err := migr.Body.Close(); err != nil {
+					ret <- err
+					return
+				}
 			}
-		}(migr)
+		}
 
 		targetVersion, err = m.sourceDrv.Next(targetVersion)
 		if errors.Is(err, os.ErrNotExist) {
@@ -279,9 +418,46 @@ func (m *Migrate) queueDownSingleMigration(version uint, ret chan<- interface{})
 // 3.  Post-Migration State Management: After successful execution of the body, it updates the migration's status to "clean" or "applied." If using an `ExtendedDriver`, it calls `UpdateMigrationDirtyFlag(..., false)` for "up" migrations or `RemoveMigration` for "down" migrations. For basic drivers, it calls `SetVersion(..., false)`.
 // 4.  Logging Timings: Finally, it calculates and logs the time taken for buffering and running the migration, providing insights into performance.
 // The function handles errors at each step, wrapping them with contextual information to indicate exactly where the failure occurred. It relies on the `m.databaseDrv` (which can be `database.ExtendedDriver` or a simpler `BasicDriver`) to interact with the underlying database.
+// If the driver implements `database.TransactionalDriver` and the migration has a body, steps 1-3 are delegated to `RunWithHistory`, which applies the body and the history write together.
+// Migrations implemented in Go (`migr.Func` is set) are run through `database.FuncDriver` in step 2 instead of `Run`,
+// and migrations with a no-transaction directive through `database.DirectiveDriver`.
 func (m *Migrate) handleSingleMigration(migr *Migration) error {
 	ed, isExtended := m.databaseDrv.(database.ExtendedDriver)
 
+	if migr.UpKindMigration {
+		if err := m.checkDependencies(migr); err != nil {
+			return err
+		}
+	}
+	if _, ok := m.databaseDrv.(database.DirectiveDriver); !ok && migr.Body != nil && migr.Directives.NoTransaction {
+		return fmt.Errorf("failed to run migration %d: %w", migr.Version, ErrNoTransactionNotSupported)
+	}
+
+	ctx := context.Background()
+	if migr.Directives.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, migr.Directives.Timeout)
+		defer cancel()
+	}
+
+	if td, ok := m.databaseDrv.(database.TransactionalDriver); ok && migr.Body != nil && !migr.Directives.NoTransaction {
+		m.logVerbosePrintf("Read and execute %v\n", migr.LogString())
+		var err error
+		if tcd, ok := td.(database.TransactionalContextDriver); ok {
+			err = tcd.RunWithHistoryContext(ctx, migr.Version, migr.UpKindMigration, migr.BufferedBody)
+		} else if migr.Directives.Timeout > 0 {
+			err = ErrTimeoutNotSupported
+		} else {
+			err = td.RunWithHistory(migr.Version, migr.UpKindMigration, migr.BufferedBody)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to run migration %d body: %w", migr.Version, err)
+		}
+
+		m.logMigrationTimings(migr)
+		return nil
+	}
+
 	if isExtended {
 		if migr.UpKindMigration {
 			if err := ed.AddDirtyMigration(migr.Version); err != nil {
@@ -298,9 +474,19 @@ func (m *Migrate) handleSingleMigration(migr *Migration) error {
 		}
 	}
 
-	if migr.Body != nil {
+	if migr.Func != nil {
+		fd, ok := m.databaseDrv.(database.FuncDriver)
+		if !ok {
+			return fmt.Errorf("failed to run migration %d: %w", migr.Version, ErrFuncNotSupported)
+		}
+
+		m.logVerbosePrintf("Execute %v\n", migr.LogString())
+		if err := fd.RunFunc(ctx, migr.Func); err != nil {
+			return fmt.Errorf("failed to run migration %d func: %w", migr.Version, err)
+		}
+	} else if migr.Body != nil {
 		m.logVerbosePrintf("Read and execute %v\n", migr.LogString())
-		if err := m.databaseDrv.Run(migr.BufferedBody); err != nil {
+		if err := m.runBody(ctx, migr); err != nil {
 			return fmt.Errorf("failed to run migration %d body: %w", migr.Version, err)
 		}
 	}
@@ -321,6 +507,46 @@ func (m *Migrate) handleSingleMigration(migr *Migration) error {
 		}
 	}
 
+	m.logMigrationTimings(migr)
+	return nil
+}
+
+// runBody runs the body of migr, through database.DirectiveDriver if migr runs outside of a transaction,
+// and through database.ContextDriver if the driver implements it.
+func (m *Migrate) runBody(ctx context.Context, migr *Migration) error {
+	if dd, ok := m.databaseDrv.(database.DirectiveDriver); ok && migr.Directives.NoTransaction {
+		return dd.RunWithDirectives(ctx, migr.BufferedBody, migr.Directives)
+	}
+	if cd, ok := m.databaseDrv.(database.ContextDriver); ok {
+		return cd.RunContext(ctx, migr.BufferedBody)
+	}
+	if migr.Directives.Timeout > 0 {
+		return ErrTimeoutNotSupported
+	}
+	return m.databaseDrv.Run(migr.BufferedBody)
+}
+
+// checkDependencies fails with ErrMissingDependency unless every version migr depends on is applied.
+// Without an ExtendedDriver, versions are applied in order, so only lower versions can be applied already.
+func (m *Migrate) checkDependencies(migr *Migration) error {
+	ed, isExtended := m.databaseDrv.(database.ExtendedDriver)
+	for _, dep := range migr.Directives.Depends {
+		applied := dep < migr.Version
+		if isExtended {
+			var err error
+			if applied, err = ed.IsMigrationApplied(dep); err != nil {
+				return err
+			}
+		}
+		if !applied {
+			return fmt.Errorf("migration %d depends on %d: %w", migr.Version, dep, ErrMissingDependency)
+		}
+	}
+	return nil
+}
+
+// logMigrationTimings logs the time taken for buffering and running a finished migration.
+func (m *Migrate) logMigrationTimings(migr *Migration) {
 	endTime := time.Now()
 	readTime := migr.FinishedReading.Sub(migr.StartedBuffering)
 	runTime := endTime.Sub(migr.FinishedReading)
@@ -332,6 +558,4 @@ func (m *Migrate) handleSingleMigration(migr *Migration) error {
 			m.logPrintf("%v (%v)\n", migr.LogString(), readTime+runTime)
 		}
 	}
-
-	return nil
 }
@@ -0,0 +1,197 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/hashicorp/go-multierror"
+)
+
+var (
+	// ErrTargetsFailed is returned by FanOut if migrating any target failed.
+	ErrTargetsFailed = errors.New("migrating targets failed")
+	// ErrTargetSkipped is the error of the targets FanOut didn't start after another one failed.
+	ErrTargetSkipped = errors.New("skipped after another target failed")
+)
+
+// Target is a database migrated by FanOut.
+type Target struct {
+	// Name identifies the target in results, e.g. a tenant.
+	Name        string
+	DatabaseURL string
+}
+
+// FanOutConfig configures FanOut.
+type FanOutConfig struct {
+	// Parallel is the number of targets migrated at the same time, at least 1.
+	Parallel int
+	// ContinueOnError keeps starting targets after one failed. By default, targets that haven't
+	// started yet are skipped; those already running finish.
+	ContinueOnError bool
+	// New creates the Migrate instance of a target.
+	// It defaults to New with the source URL given to FanOut and the database URL of the target.
+	New func(target Target) (*Migrate, error)
+	// Setup, if set, is called with the Migrate instance of every target before it runs,
+	// e.g. to set Log or LockTimeout. The Report field is set by FanOut.
+	Setup func(target Target, m *Migrate)
+	// Run migrates a target. It defaults to (*Migrate).Up.
+	Run func(m *Migrate) error
+}
+
+// TargetResult is the outcome of migrating a target, see FanOut.
+type TargetResult struct {
+	Target Target
+	// Migrations are the migrations run against the target, in order.
+	Migrations []MigrationReport
+	// Version is the latest version applied to the target after the run, see LatestApplied,
+	// or database.NilVersion if unknown or none.
+	Version int
+	Dirty   bool
+	// Pending is the number of up migrations still pending after the run.
+	Pending  int
+	Duration time.Duration
+	// Err is nil if the target has been migrated or had nothing to migrate.
+	Err error
+}
+
+// Behind tells whether migrations of the target are pending or its state is unknown.
+func (r TargetResult) Behind() bool {
+	return r.Err != nil || r.Dirty || r.Pending > 0
+}
+
+// FanOut runs the migrations of sourceURL against every target, with its own Migrate instance,
+// and so its own lock, for each. The results are in the order of targets.
+// It returns ErrTargetsFailed if any target failed. config may be nil.
+func FanOut(sourceURL string, targets []Target, config *FanOutConfig) ([]TargetResult, error) {
+	if config == nil {
+		config = &FanOutConfig{}
+	}
+	parallel := config.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	newMigrate := config.New
+	if newMigrate == nil {
+		newMigrate = func(target Target) (*Migrate, error) {
+			return New(sourceURL, target.DatabaseURL)
+		}
+	}
+	run := config.Run
+	if run == nil {
+		run = (*Migrate).Up
+	}
+
+	results := make([]TargetResult, len(targets))
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	sem := make(chan struct{}, parallel)
+	for i, target := range targets {
+		sem <- struct{}{}
+
+		mu.Lock()
+		skip := failed && !config.ContinueOnError
+		mu.Unlock()
+		if skip {
+			<-sem
+			results[i] = TargetResult{Target: target, Version: database.NilVersion, Err: ErrTargetSkipped}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = migrateTarget(target, newMigrate, config.Setup, run)
+			if results[i].Err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	errCount := 0
+	for _, r := range results {
+		if r.Err != nil {
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return results, fmt.Errorf("%w: %d of %d", ErrTargetsFailed, errCount, len(targets))
+	}
+	return results, nil
+}
+
+// migrateTarget runs a target and reads its state afterwards.
+func migrateTarget(target Target, newMigrate func(Target) (*Migrate, error), setup func(Target, *Migrate), run func(*Migrate) error) (result TargetResult) {
+	start := time.Now()
+	result = TargetResult{Target: target, Version: database.NilVersion}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	m, err := newMigrate(target)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() {
+		errSource, errDatabase := m.Close()
+		for _, errClose := range []error{errSource, errDatabase} {
+			if errClose != nil {
+				result.Err = multierror.Append(result.Err, errClose)
+			}
+		}
+	}()
+
+	if setup != nil {
+		setup(target, m)
+	}
+	m.Report = func(r MigrationReport) {
+		result.Migrations = append(result.Migrations, r)
+	}
+
+	if err := run(m); err != nil && !errors.Is(err, ErrNoChange) {
+		result.Err = err
+	}
+
+	if version, err := m.LatestApplied(); err == nil {
+		result.Version = int(version)
+	} else if !errors.Is(err, ErrNilVersion) && result.Err == nil {
+		result.Err = err
+	}
+	if dirty, err := m.isDirty(); err == nil {
+		result.Dirty = dirty
+	} else if result.Err == nil {
+		result.Err = err
+	}
+	if plan, err := m.Plan(-1); err == nil {
+		for _, p := range plan {
+			if !p.Skipped {
+				result.Pending++
+			}
+		}
+	} else if result.Err == nil {
+		result.Err = err
+	}
+
+	return result
+}
+
+// isDirty tells whether a migration of the database is dirty.
+func (m *Migrate) isDirty() (bool, error) {
+	if ed, ok := m.databaseDrv.(database.ExtendedDriver); ok {
+		_, dirty, err := ed.IsDatabaseDirty()
+		return dirty, err
+	}
+	_, dirty, err := m.databaseDrv.Version()
+	return dirty, err
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestFanOut(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("CREATE 1")},
+		"2_users.up.sql": {Data: []byte("CREATE 2")},
+	}
+	broken := fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("CREATE 1")},
+		"2_users.up.sql": {Data: []byte("-- histomigrate: depends=3\nCREATE 2")},
+	}
+
+	newMigrate := func(target Target) (*Migrate, error) {
+		if target.DatabaseURL == "unreachable" {
+			return nil, errors.New("connection refused")
+		}
+		src, err := iofs.New(fsys, ".")
+		if target.DatabaseURL == "broken" {
+			src, err = iofs.New(broken, ".")
+		}
+		if err != nil {
+			return nil, err
+		}
+		db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+		if err != nil {
+			return nil, err
+		}
+		return NewWithInstance("iofs", src, "stub", db)
+	}
+
+	targets := []Target{
+		{Name: "a", DatabaseURL: "ok"},
+		{Name: "b", DatabaseURL: "broken"},
+		{Name: "c", DatabaseURL: "unreachable"},
+		{Name: "d", DatabaseURL: "ok"},
+	}
+
+	t.Run("continue on error", func(t *testing.T) {
+		results, err := FanOut("", targets, &FanOutConfig{Parallel: 2, ContinueOnError: true, New: newMigrate})
+		if !errors.Is(err, ErrTargetsFailed) {
+			t.Fatalf("expected ErrTargetsFailed, got %v", err)
+		}
+		if len(results) != len(targets) {
+			t.Fatalf("expected %d results, got %d", len(targets), len(results))
+		}
+		for i, r := range results {
+			if r.Target != targets[i] {
+				t.Errorf("expected result %d to be of %v, got %v", i, targets[i], r.Target)
+			}
+		}
+
+		if a := results[0]; a.Err != nil || a.Version != 2 || a.Pending != 0 || len(a.Migrations) != 2 || a.Behind() {
+			t.Errorf("unexpected result %+v", a)
+		}
+		if b := results[1]; !errors.Is(b.Err, ErrMissingDependency) || b.Version != 1 || b.Pending != 1 || !b.Behind() {
+			t.Errorf("unexpected result %+v", b)
+		}
+		if c := results[2]; c.Err == nil || !c.Behind() {
+			t.Errorf("unexpected result %+v", c)
+		}
+		if d := results[3]; d.Err != nil || d.Version != 2 {
+			t.Errorf("unexpected result %+v", d)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		results, err := FanOut("", targets, &FanOutConfig{New: newMigrate})
+		if !errors.Is(err, ErrTargetsFailed) {
+			t.Fatalf("expected ErrTargetsFailed, got %v", err)
+		}
+		if results[0].Err != nil || results[1].Err == nil {
+			t.Fatalf("unexpected results %+v", results)
+		}
+		for _, r := range results[2:] {
+			if !errors.Is(r.Err, ErrTargetSkipped) {
+				t.Errorf("expected %s to be skipped, got %v", r.Target.Name, r.Err)
+			}
+		}
+	})
+
+	t.Run("run and setup", func(t *testing.T) {
+		setups := 0
+		results, err := FanOut("", targets[:1], &FanOutConfig{
+			New:   newMigrate,
+			Setup: func(target Target, m *Migrate) { setups++ },
+			Run:   func(m *Migrate) error { return m.Steps(1) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if setups != 1 || results[0].Version != 1 || results[0].Pending != 1 {
+			t.Errorf("unexpected result %+v after %d setups", results[0], setups)
+		}
+	})
+}
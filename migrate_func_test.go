@@ -0,0 +1,185 @@
+package migrate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/cache"
+	"github.com/abramad-labs/histomigrate/source/gofunc"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	"github.com/abramad-labs/histomigrate/source/multi"
+	"github.com/abramad-labs/histomigrate/source/signed"
+)
+
+func TestFuncMigrations(t *testing.T) {
+	files, err := iofs.New(fstest.MapFS{
+		"1_init.up.sql":    {Data: []byte("CREATE 1")},
+		"1_init.down.sql":  {Data: []byte("DROP 1")},
+		"3_index.up.sql":   {Data: []byte("CREATE 3")},
+		"3_index.down.sql": {Data: []byte("DROP 3")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	funcs, err := gofunc.WithInstance(gofunc.Migration{
+		Version: 2,
+		Name:    "backfill",
+		Up: func(context.Context, *sql.Tx) error {
+			calls = append(calls, "up")
+			return nil
+		},
+		Down: func(context.Context, *sql.Tx) error {
+			calls = append(calls, "down")
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := multi.WithInstance(multi.Source{Name: "sql", Driver: files}, multi.Source{Name: "go", Driver: funcs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("multi", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE 1", dStub.FuncMigration, "CREATE 3"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+	if applied, _ := dbDrv.GetAllAppliedMigrations(); len(applied) != 3 {
+		t.Fatalf("expected 3 applied migrations, got %v", applied)
+	}
+
+	if err := m.UndoMigration(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != "up" || calls[1] != "down" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if isApplied, _ := dbDrv.IsMigrationApplied(2); isApplied {
+		t.Fatal("expected migration 2 to be removed")
+	}
+}
+
+func TestFuncMigrationsNotSupported(t *testing.T) {
+	src, err := gofunc.WithInstance(gofunc.Migration{
+		Version: 1,
+		Name:    "backfill",
+		Up:      func(context.Context, *sql.Tx) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewWithInstance("gofunc", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); !errors.Is(err, ErrFuncNotSupported) {
+		t.Fatalf("expected ErrFuncNotSupported, got %v", err)
+	}
+}
+
+func TestSignedFuncMigrations(t *testing.T) {
+	var calls int
+	funcs, err := gofunc.WithInstance(gofunc.Migration{
+		Version: 1,
+		Name:    "backfill",
+		Up: func(context.Context, *sql.Tx) error {
+			calls++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := signed.Build(funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := signed.WithInstance(funcs, manifest.Bytes(), signed.Sign(manifest.Bytes(), priv), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("signed", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || !dbDrv.EqualSequence([]string{dStub.FuncMigration}) {
+		t.Fatalf("expected the function to run once, got %d calls and sequence %v", calls, dbDrv.MigrationSequence)
+	}
+}
+
+func TestCachedFuncMigrations(t *testing.T) {
+	var calls int
+	funcs, err := gofunc.WithInstance(gofunc.Migration{
+		Version: 1,
+		Name:    "backfill",
+		Up: func(context.Context, *sql.Tx) error {
+			calls++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := cache.WithInstance(funcs, "gofunc://", &cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("cache", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || !dbDrv.EqualSequence([]string{dStub.FuncMigration}) {
+		t.Fatalf("expected the function to run once, got %d calls and sequence %v", calls, dbDrv.MigrationSequence)
+	}
+}
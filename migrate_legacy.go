@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"sort"
+
+	"github.com/abramad-labs/histomigrate/database"
+)
+
+// backfillHistory converts the legacy version row of a database.LegacyHistoryDriver into a history,
+// recording every version of the source below it as applied. It must be called with the lock held.
+func (m *Migrate) backfillHistory() error {
+	ld, ok := m.databaseDrv.(database.LegacyHistoryDriver)
+	if !ok {
+		return nil
+	}
+	legacy, isLegacy, err := ld.LegacyVersion()
+	if err != nil || !isLegacy {
+		return err
+	}
+
+	versions, err := m.sourceVersionsBelow(legacy)
+	if err != nil {
+		return err
+	}
+	m.logPrintf("Backfilling the history with %d migrations below version %d\n", len(versions), legacy)
+	return ld.BackfillHistory(versions)
+}
+
+// appliedMigrations returns the versions recorded as applied by ed, most recent first.
+// Until the history of a database.LegacyHistoryDriver is backfilled, every version of the source
+// below the legacy version counts as applied too, as it will once backfillHistory has run.
+func (m *Migrate) appliedMigrations(ed database.ExtendedDriver) ([]int, error) {
+	applied, err := ed.GetAllAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	ld, ok := ed.(database.LegacyHistoryDriver)
+	if !ok {
+		return applied, nil
+	}
+	legacy, isLegacy, err := ld.LegacyVersion()
+	if err != nil || !isLegacy {
+		return applied, err
+	}
+
+	versions, err := m.sourceVersionsBelow(legacy)
+	if err != nil {
+		return nil, err
+	}
+	appliedSet := make(map[int]struct{}, len(applied))
+	for _, v := range applied {
+		appliedSet[v] = struct{}{}
+	}
+	for _, v := range versions {
+		if _, ok := appliedSet[int(v)]; !ok {
+			applied = append(applied, int(v))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(applied)))
+	return applied, nil
+}
+
+// sourceVersionsBelow returns the versions of the source lower than version, in order.
+func (m *Migrate) sourceVersionsBelow(version int) ([]uint, error) {
+	var versions []uint
+	v, err := m.sourceDrv.First()
+	for err == nil && int(v) < version {
+		versions = append(versions, v)
+		v, err = m.sourceDrv.Next(v)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return versions, nil
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestBackfillHistory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":    {Data: []byte("CREATE 1")},
+		"2_users.up.sql":   {Data: []byte("CREATE 2")},
+		"3_orders.up.sql":  {Data: []byte("CREATE 3")},
+		"4_indexes.up.sql": {Data: []byte("CREATE 4")},
+	}
+	newLegacy := func(dirty bool) (*Migrate, *dStub.ExtendedStub) {
+		src, err := iofs.New(fsys, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := d.(*dStub.ExtendedStub)
+		// an earlier release left the single row of version 3
+		legacy := uint(3)
+		db.Applied[legacy] = dirty
+		db.Legacy = &legacy
+		m, err := NewWithInstance("iofs", src, "stub", db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, db
+	}
+
+	t.Run("up", func(t *testing.T) {
+		m, db := newLegacy(false)
+
+		plan, err := m.Plan(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan) != 1 || plan[0].Version != 4 {
+			t.Fatalf("expected only version 4 to be pending before the backfill, got %+v", plan)
+		}
+		if db.Legacy == nil {
+			t.Fatal("expected Plan not to backfill the history")
+		}
+
+		if err := m.Up(); err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"CREATE 4"}; !reflect.DeepEqual(db.MigrationSequence, expected) {
+			t.Errorf("expected %v to run, got %v", expected, db.MigrationSequence)
+		}
+		if expected := map[uint]bool{1: false, 2: false, 3: false, 4: false}; !reflect.DeepEqual(db.Applied, expected) {
+			t.Errorf("expected history %v, got %v", expected, db.Applied)
+		}
+		if db.Legacy != nil {
+			t.Error("expected the legacy row to be converted")
+		}
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		m, db := newLegacy(true)
+
+		var errDirty ErrDirty
+		if err := m.Up(); !errors.As(err, &errDirty) || errDirty.Version != 3 {
+			t.Fatalf("expected version 3 to be dirty, got %v", err)
+		}
+		if expected := map[uint]bool{1: false, 2: false, 3: true}; !reflect.DeepEqual(db.Applied, expected) {
+			t.Errorf("expected history %v, got %v", expected, db.Applied)
+		}
+		if len(db.MigrationSequence) != 0 {
+			t.Errorf("expected nothing to run, got %v", db.MigrationSequence)
+		}
+	})
+}
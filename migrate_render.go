@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Renderer renders the body of a migration before it is run.
+// The rendered body is what the database driver runs and what is checksummed
+// for repeatable migrations.
+type Renderer interface {
+	Render(identifier string, body []byte) ([]byte, error)
+}
+
+// TemplateRenderer renders migration bodies as text/template templates, e.g.
+//
+//	CREATE TABLE {{ .schema }}.users (id INT) TABLESPACE {{ .tablespace }};
+//
+// Referencing a variable that isn't set fails the migration.
+type TemplateRenderer struct {
+	Vars map[string]string
+}
+
+// NewTemplateRenderer returns a TemplateRenderer for the given variables.
+func NewTemplateRenderer(vars map[string]string) *TemplateRenderer {
+	return &TemplateRenderer{Vars: vars}
+}
+
+// Render executes body as a template with the variables of r.
+func (r *TemplateRenderer) Render(identifier string, body []byte) ([]byte, error) {
+	tmpl, err := template.New(identifier).Option("missingkey=error").Parse(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	vars := r.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// EnvVarPrefix prefixes environment variables read by EnvVars.
+const EnvVarPrefix = "MIGRATE_VAR_"
+
+// EnvVars returns the template variables given by environment variables,
+// e.g. schema for MIGRATE_VAR_schema.
+func EnvVars() map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, EnvVarPrefix) {
+			continue
+		}
+		if k, v, ok := strings.Cut(strings.TrimPrefix(kv, EnvVarPrefix), "="); ok && k != "" {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// ReadVars reads template variables from r, one key=value pair per line.
+// Blank lines and lines starting with # are ignored.
+func ReadVars(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, err := ParseVar(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		vars[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// ParseVar parses a key=value pair.
+func ParseVar(s string) (key string, value string, err error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid variable %q, expected key=value", s)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// MergeVars merges several sets of variables; later sets take precedence.
+func MergeVars(sets ...map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for _, set := range sets {
+		for k, v := range set {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// render runs body through m.Renderer, if set. Bodies of migrations implemented in Go are returned as is.
+func (m *Migrate) render(body io.ReadCloser, identifier string) (io.ReadCloser, error) {
+	if m.Renderer == nil || body == nil {
+		return body, nil
+	}
+	if _, ok := body.(funcBody); ok {
+		return body, nil
+	}
+
+	content, err := io.ReadAll(body)
+	if errClose := body.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := m.Renderer.Render(identifier, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render migration %s: %w", identifier, err)
+	}
+	return io.NopCloser(bytes.NewReader(rendered)), nil
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	r := NewTemplateRenderer(map[string]string{"schema": "app"})
+
+	out, err := r.Render("1_init", []byte("CREATE TABLE {{ .schema }}.users (id INT)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "CREATE TABLE app.users (id INT)" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if _, err := r.Render("1_init", []byte("GRANT ALL TO {{ .role }}")); err == nil {
+		t.Error("expected an error for a missing variable")
+	}
+}
+
+func TestReadVars(t *testing.T) {
+	vars, err := ReadVars(strings.NewReader("# staging\nschema = app\n\ntablespace=fast\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{"schema": "app", "tablespace": "fast"}
+	if !reflect.DeepEqual(vars, expect) {
+		t.Errorf("expected %v, got %v", expect, vars)
+	}
+
+	if _, err := ReadVars(strings.NewReader("schema\n")); err == nil {
+		t.Error("expected an error for a line without =")
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Setenv(EnvVarPrefix+"schema", "from_env")
+
+	vars := MergeVars(map[string]string{"schema": "from_file", "role": "app"}, EnvVars())
+	if vars["schema"] != "from_env" || vars["role"] != "app" {
+		t.Errorf("unexpected vars %v", vars)
+	}
+}
+
+func TestUpRender(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("CREATE SCHEMA {{ .schema }}")},
+		"R__views.sql":  {Data: []byte("CREATE VIEW {{ .schema }}.v")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Renderer = NewTemplateRenderer(map[string]string{"schema": "tenant_a"})
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE SCHEMA tenant_a", "CREATE VIEW tenant_a.v"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+	if dbDrv.Repeatables["views"] != Checksum([]byte("CREATE VIEW tenant_a.v")) {
+		t.Errorf("expected the checksum of the rendered view, got %v", dbDrv.Repeatables)
+	}
+
+	// the rendered view changes with the variables
+	m.Renderer = NewTemplateRenderer(map[string]string{"schema": "tenant_b"})
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE SCHEMA tenant_a", "CREATE VIEW tenant_a.v", "CREATE VIEW tenant_b.v"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+}
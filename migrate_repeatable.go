@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/database"
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+// ErrRepeatableNotSupported is returned when the source provides repeatable
+// migrations but the database driver can't keep track of them.
+var ErrRepeatableNotSupported = errors.New("database driver does not support repeatable migrations")
+
+// checkRepeatables returns ErrRepeatableNotSupported if the source provides repeatable migrations
+// the database driver can't keep track of, so that Up fails before applying any versioned migration.
+func (m *Migrate) checkRepeatables() error {
+	if _, ok := m.databaseDrv.(database.RepeatableDriver); ok {
+		return nil
+	}
+	rs, ok := m.sourceDrv.(source.RepeatableDriver)
+	if !ok {
+		return nil
+	}
+
+	names, err := rs.Repeatables()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		return ErrRepeatableNotSupported
+	}
+	return nil
+}
+
+// runRepeatables runs every repeatable migration of the source whose checksum differs from the
+// one recorded by the database driver, in the order given by the source.
+// Checksums are taken of the rendered content, see Migrate.Renderer.
+// It returns ErrNoChange if none of them had to run.
+// Sources that don't implement source.RepeatableDriver provide no repeatable migrations.
+func (m *Migrate) runRepeatables() error {
+	rs, ok := m.sourceDrv.(source.RepeatableDriver)
+	if !ok {
+		return ErrNoChange
+	}
+
+	names, err := rs.Repeatables()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return ErrNoChange
+	}
+
+	rd, ok := m.databaseDrv.(database.RepeatableDriver)
+	if !ok {
+		return ErrRepeatableNotSupported
+	}
+
+	applied, err := rd.GetAllAppliedRepeatables()
+	if err != nil {
+		return err
+	}
+
+	ran := 0
+	for _, name := range names {
+		if m.stop() {
+			return nil
+		}
+
+		r, identifier, err := rs.ReadRepeatable(name)
+		if err != nil {
+			return err
+		}
+		if r, err = m.render(r, identifier); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		body, err := io.ReadAll(r)
+		if errClose := r.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read repeatable migration %s: %w", name, err)
+		}
+
+		checksum := Checksum(body)
+		if applied[name] == checksum {
+			m.logVerbosePrintf("Skipped unchanged R %v\n", identifier)
+			continue
+		}
+
+		m.logVerbosePrintf("Read and execute R %v\n", identifier)
+		if err := m.databaseDrv.Run(bytes.NewReader(body)); err != nil {
+			err = fmt.Errorf("failed to run repeatable migration %s: %w", name, err)
+			m.reportRepeatable(identifier, start, err)
+			return err
+		}
+		if err := rd.SetRepeatableApplied(name, checksum); err != nil {
+			err = fmt.Errorf("failed to record repeatable migration %s: %w", name, err)
+			m.reportRepeatable(identifier, start, err)
+			return err
+		}
+
+		ran++
+		m.logPrintf("R %v (%v)\n", identifier, time.Since(start))
+		m.reportRepeatable(identifier, start, nil)
+	}
+
+	if ran == 0 {
+		return ErrNoChange
+	}
+
+	return nil
+}
+
+// Checksum returns the checksum recorded for the body of a repeatable migration.
+func Checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
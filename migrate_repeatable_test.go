@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestUpRepeatables(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":        {Data: []byte("CREATE 1")},
+		"2_more.up.sql":        {Data: []byte("CREATE 2")},
+		"R__views.sql":         {Data: []byte("VIEWS v1")},
+		"R__functions.sql":     {Data: []byte("FUNCTIONS v1")},
+		"R_not_repeatable.sql": {Data: []byte("IGNORED")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// repeatables run after the versioned migrations, in name order
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE 1", "CREATE 2", "FUNCTIONS v1", "VIEWS v1"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+	if dbDrv.Repeatables["views"] != Checksum([]byte("VIEWS v1")) {
+		t.Errorf("expected checksum of views to be recorded, got %v", dbDrv.Repeatables)
+	}
+
+	// nothing changed
+	if err := m.Up(); !errors.Is(err, ErrNoChange) {
+		t.Fatalf("expected ErrNoChange, got %v", err)
+	}
+
+	// only the changed repeatable runs again
+	fsys["R__views.sql"] = &fstest.MapFile{Data: []byte("VIEWS v2")}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE 1", "CREATE 2", "FUNCTIONS v1", "VIEWS v1", "VIEWS v2"}) {
+		t.Fatalf("unexpected sequence %v", dbDrv.MigrationSequence)
+	}
+}
+
+func TestUpRepeatablesNotSupported(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("CREATE 1")},
+		"R__views.sql":  {Data: []byte("VIEWS v1")},
+	}
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); !errors.Is(err, ErrRepeatableNotSupported) {
+		t.Fatalf("expected ErrRepeatableNotSupported, got %v", err)
+	}
+	if len(db.(*dStub.Stub).MigrationSequence) != 0 {
+		t.Fatalf("expected no migration to run, got %v", db.(*dStub.Stub).MigrationSequence)
+	}
+}
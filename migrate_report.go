@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+// MigrationReport describes a migration run by a Migrate instance, see Migrate.Report.
+type MigrationReport struct {
+	Version    uint
+	Identifier string
+	Direction  source.Direction
+	// Repeatable is true for repeatable migrations, which have no version.
+	Repeatable bool
+	// Duration is the time spent running the migration, including the wait for its body.
+	Duration time.Duration
+	// Err is the error the migration failed with, or nil.
+	Err error
+}
+
+// report passes the outcome of migr, run since start, to m.Report, if set.
+func (m *Migrate) report(migr *Migration, start time.Time, err error) {
+	if m.Report == nil {
+		return
+	}
+	direction := source.Up
+	if migr.TargetVersion < int(migr.Version) {
+		direction = source.Down
+	}
+	m.Report(MigrationReport{
+		Version:    migr.Version,
+		Identifier: migr.Identifier,
+		Direction:  direction,
+		Duration:   time.Since(start),
+		Err:        err,
+	})
+}
+
+// reportRepeatable passes the outcome of a repeatable migration to m.Report, if set.
+func (m *Migrate) reportRepeatable(identifier string, start time.Time, err error) {
+	if m.Report == nil {
+		return
+	}
+	m.Report(MigrationReport{
+		Identifier: identifier,
+		Direction:  source.Up,
+		Repeatable: true,
+		Duration:   time.Since(start),
+		Err:        err,
+	})
+}
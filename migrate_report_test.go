@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestReport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":     {Data: []byte("CREATE 1")},
+		"1_init.down.sql":   {Data: []byte("DROP 1")},
+		"2_orders.up.sql":   {Data: []byte("-- histomigrate: depends=3\nCREATE 2")},
+		"2_orders.down.sql": {Data: []byte("DROP 2")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []MigrationReport
+	m.Report = func(r MigrationReport) {
+		reports = append(reports, r)
+	}
+
+	if err := m.Up(); !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("expected ErrMissingDependency, got %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %v", reports)
+	}
+	if r := reports[0]; r.Version != 1 || r.Identifier != "init" || r.Direction != source.Up || r.Err != nil {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if r := reports[1]; r.Version != 2 || !errors.Is(r.Err, ErrMissingDependency) {
+		t.Errorf("expected migration 2 to fail, got %+v", r)
+	}
+
+	reports = nil
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Version != 1 || reports[0].Direction != source.Down {
+		t.Errorf("expected migration 1 down to be reported, got %v", reports)
+	}
+}
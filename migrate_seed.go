@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	nurl "net/url"
+)
+
+// DefaultSeedTable is the history table of the seed track, see NewSeed.
+const DefaultSeedTable = "seed_migrations"
+
+// ErrSchemaPending is returned by CheckApplied if migrations are pending.
+var ErrSchemaPending = errors.New("schema migrations are pending")
+
+// ErrSeedNotSupported is returned by SeedDatabaseURL for database drivers
+// that can't keep the history of the seeds apart from the one of the schema.
+var ErrSeedNotSupported = errors.New("database driver can't keep a separate seed history")
+
+// seedTableParams maps the schemes of the database drivers to the query parameter naming their history table.
+// Drivers missing here, e.g. neo4j, keep their history in a fixed place.
+var seedTableParams = map[string]string{
+	"cassandra":     "x-migrations-table",
+	"clickhouse":    "x-migrations-table",
+	"cockroach":     "x-migrations-table",
+	"cockroachdb":   "x-migrations-table",
+	"crdb-postgres": "x-migrations-table",
+	"firebird":      "x-migrations-table",
+	"firebirdsql":   "x-migrations-table",
+	"mongodb":       "x-migrations-collection",
+	"mongodb+srv":   "x-migrations-collection",
+	"mysql":         "x-migrations-table",
+	"pgx":           "x-migrations-table",
+	"pgx4":          "x-migrations-table",
+	"pgx5":          "x-migrations-table",
+	"postgres":      "x-migrations-table",
+	"postgresql":    "x-migrations-table",
+	"ql":            "x-migrations-table",
+	"redshift":      "x-migrations-table",
+	"rqlite":        "x-migrations-table",
+	"snowflake":     "x-migrations-table",
+	"spanner":       "x-migrations-table",
+	"sqlcipher":     "x-migrations-table",
+	"sqlite":        "x-migrations-table",
+	"sqlite3":       "x-migrations-table",
+	"sqlserver":     "x-migrations-table",
+	"ysql":          "x-migrations-table",
+	"yugabyte":      "x-migrations-table",
+	"yugabytedb":    "x-migrations-table",
+}
+
+// NewSeed returns a Migrate instance for the seed track: data fixtures versioned independently
+// of the schema, read from their own source and recorded in their own history table
+// (DefaultSeedTable if table is empty), so that rolling back seeds never touches the schema and vice versa.
+//
+// The table is passed to the database driver as a query parameter of databaseURL, see SeedDatabaseURL.
+func NewSeed(seedSourceURL, databaseURL, table string) (*Migrate, error) {
+	seedDatabaseURL, err := SeedDatabaseURL(databaseURL, table)
+	if err != nil {
+		return nil, err
+	}
+	return New(seedSourceURL, seedDatabaseURL)
+}
+
+// SeedDatabaseURL returns databaseURL with its history table set to table, or DefaultSeedTable if it is empty.
+// The table replaces the value of the query parameter the database driver reads it from, x-migrations-table
+// or x-migrations-collection for mongodb. It fails with ErrSeedNotSupported for drivers without such a parameter.
+func SeedDatabaseURL(databaseURL, table string) (string, error) {
+	if table == "" {
+		table = DefaultSeedTable
+	}
+	u, err := nurl.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	param, ok := seedTableParams[u.Scheme]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSeedNotSupported, u.Scheme)
+	}
+	q := u.Query()
+	q.Set(param, table)
+	q.Del("x-migrations-table-quoted")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// CheckApplied returns ErrDirty if the database is dirty and ErrSchemaPending
+// if migrations are pending, e.g. to apply seeds only on top of an up to date schema.
+// It holds the lock while checking, so that it doesn't look at a database in the middle of
+// a migration, but a migration may start as soon as it returns.
+func (m *Migrate) CheckApplied() error {
+	if err := m.lock(); err != nil {
+		return err
+	}
+
+	version, dirty, err := m.databaseDrv.Version()
+	if err != nil {
+		return m.unlockErr(err)
+	}
+	if dirty {
+		return m.unlockErr(ErrDirty{version})
+	}
+
+	plan, err := m.Plan(-1)
+	if err != nil {
+		return m.unlockErr(err)
+	}
+	if len(plan) > 0 {
+		return m.unlockErr(fmt.Errorf("%w: %d, starting with %d", ErrSchemaPending, len(plan), plan[0].Version))
+	}
+	return m.unlock()
+}
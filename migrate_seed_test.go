@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSeedDatabaseURL(t *testing.T) {
+	tt := []struct {
+		url    string
+		table  string
+		expect string
+	}{
+		{url: "postgres://localhost/db?sslmode=disable", expect: "postgres://localhost/db?sslmode=disable&x-migrations-table=seed_migrations"},
+		{url: "postgres://localhost/db?x-migrations-table=schema", table: "fixtures", expect: "postgres://localhost/db?x-migrations-table=fixtures"},
+		{url: `postgres://localhost/db?x-migrations-table="s"."t"&x-migrations-table-quoted=1`, expect: "postgres://localhost/db?x-migrations-table=seed_migrations"},
+		{url: "mongodb://localhost/db", expect: "mongodb://localhost/db?x-migrations-collection=seed_migrations"},
+	}
+	for _, v := range tt {
+		got, err := SeedDatabaseURL(v.url, v.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.expect {
+			t.Errorf("expected %s, got %s", v.expect, got)
+		}
+	}
+}
+
+func TestSeedDatabaseURLNotSupported(t *testing.T) {
+	for _, url := range []string{"neo4j://localhost:7687", "unknown://localhost/db"} {
+		if _, err := SeedDatabaseURL(url, ""); !errors.Is(err, ErrSeedNotSupported) {
+			t.Errorf("%s: expected ErrSeedNotSupported, got %v", url, err)
+		}
+	}
+}
+
+func TestCheckAppliedAndStatus(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":      {Data: []byte("CREATE 1")},
+		"1_init.down.sql":    {Data: []byte("DROP 1")},
+		"2_users.up.sql":     {Data: []byte("CREATE 2")},
+		"3_cleanup.down.sql": {Data: []byte("DROP 3")},
+	}
+	m, err := newMigrateWithFS(t, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CheckApplied(); !errors.Is(err, ErrSchemaPending) {
+		t.Fatalf("expected ErrSchemaPending, got %v", err)
+	}
+
+	if err := m.Steps(1); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []MigrationStatus{
+		{Version: 1, Identifier: "init", Applied: true},
+		{Version: 2, Identifier: "users"},
+		{Version: 3, Identifier: "cleanup"},
+	}
+	if !reflect.DeepEqual(status, expect) {
+		t.Fatalf("expected %+v, got %+v", expect, status)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CheckApplied(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestLatestApplied(t *testing.T) {
+	m, err := newMigrateWithFS(t, fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("CREATE 1")},
+		"5_users.up.sql": {Data: []byte("CREATE 5")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.LatestApplied(); !errors.Is(err, ErrNilVersion) {
+		t.Fatalf("expected ErrNilVersion, got %v", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.LatestApplied(); err != nil || v != 5 {
+		t.Fatalf("expected 5, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate/database"
+)
+
+// ErrTagsNotSupported is returned when Migrate.Tags is set for a database driver that
+// only keeps track of the latest version, so skipped migrations couldn't stay pending.
+var ErrTagsNotSupported = errors.New("tag filters need a database driver implementing database.ExtendedDriver")
+
+// TagFilter selects migrations by their tags.
+// A migration is selected if it has none of the Exclude tags and,
+// unless Include is empty, at least one of the Include tags.
+// Untagged migrations are always selected: Include adds tagged migrations
+// to the untagged ones, e.g. seeds to the schema during development.
+type TagFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// ParseTagFilter parses a comma-separated list of tags. Tags prefixed with "!" are excluded,
+// e.g. "!seed,!fixture" selects all migrations but seeds and fixtures.
+func ParseTagFilter(s string) (TagFilter, error) {
+	var f TagFilter
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.HasPrefix(tag, "!") {
+			tag = strings.TrimSpace(tag[1:])
+			if tag == "" {
+				return TagFilter{}, fmt.Errorf("invalid tag filter %q", s)
+			}
+			f.Exclude = append(f.Exclude, tag)
+			continue
+		}
+		f.Include = append(f.Include, tag)
+	}
+	return f, nil
+}
+
+// IsZero reports whether f selects every migration.
+func (f TagFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether a migration with the given tags is selected.
+func (f TagFilter) Match(tags []string) bool {
+	for _, tag := range tags {
+		if contains(f.Exclude, tag) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 || len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if contains(f.Include, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns f in the format read by ParseTagFilter.
+func (f TagFilter) String() string {
+	tags := make([]string, 0, len(f.Include)+len(f.Exclude))
+	tags = append(tags, f.Include...)
+	for _, tag := range f.Exclude {
+		tags = append(tags, "!"+tag)
+	}
+	return strings.Join(tags, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PlannedMigration is a pending up migration, see Plan, or an applied migration to roll back, see PlanDown.
+type PlannedMigration struct {
+	Version    uint
+	Identifier string
+	Tags       []string
+
+	// Skipped is true for migrations not selected by Migrate.Tags. Up leaves them pending.
+	Skipped bool
+}
+
+// Plan returns the pending up migrations in the order Up would consider them, without running them.
+// limit can be -1, implying all pending migrations; skipped migrations don't count towards it.
+func (m *Migrate) Plan(limit int) ([]PlannedMigration, error) {
+	if _, ok := m.databaseDrv.(database.ExtendedDriver); !ok && !m.Tags.IsZero() {
+		return nil, ErrTagsNotSupported
+	}
+	pending, err := m.pendingFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []PlannedMigration
+	selected := 0
+	version, err := m.sourceDrv.First()
+	for err == nil && (limit == -1 || selected < limit) {
+		if pending(version) {
+			p, err := m.planMigration(version)
+			if err != nil {
+				return nil, err
+			}
+			if !p.Skipped {
+				selected++
+			}
+			plan = append(plan, p)
+		}
+		version, err = m.sourceDrv.Next(version)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// planMigration reads the up migration of version to find its tags.
+func (m *Migrate) planMigration(version uint) (PlannedMigration, error) {
+	r, identifier, err := m.sourceDrv.ReadUp(version)
+	if errors.Is(err, os.ErrNotExist) {
+		r, identifier = nil, ""
+	} else if err != nil {
+		return PlannedMigration{}, err
+	} else if r, err = m.render(r, identifier); err != nil {
+		return PlannedMigration{}, err
+	}
+
+	migr, err := NewMigration(r, identifier, version, int(version))
+	if err != nil {
+		return PlannedMigration{}, err
+	}
+	if migr.Body != nil {
+		if err := migr.Body.Close(); err != nil {
+			return PlannedMigration{}, err
+		}
+	}
+
+	return PlannedMigration{
+		Version:    version,
+		Identifier: migr.Identifier,
+		Tags:       migr.Tags,
+		Skipped:    !m.Tags.Match(migr.Tags),
+	}, nil
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abramad-labs/histomigrate/database"
+	dStub "github.com/abramad-labs/histomigrate/database/stub"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func TestTagFilter(t *testing.T) {
+	f, err := ParseTagFilter("backfill, !seed,!fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := TagFilter{Include: []string{"backfill"}, Exclude: []string{"seed", "fixture"}}
+	if !reflect.DeepEqual(f, expect) {
+		t.Fatalf("expected %+v, got %+v", expect, f)
+	}
+	if f.String() != "backfill,!seed,!fixture" {
+		t.Errorf("unexpected string %q", f.String())
+	}
+
+	tt := []struct {
+		filter string
+		tags   []string
+		expect bool
+	}{
+		{filter: "", tags: nil, expect: true},
+		{filter: "", tags: []string{"seed"}, expect: true},
+		{filter: "!seed", tags: nil, expect: true},
+		{filter: "!seed", tags: []string{"seed"}, expect: false},
+		{filter: "seed", tags: nil, expect: true},
+		{filter: "seed", tags: []string{"dev"}, expect: false},
+		{filter: "seed", tags: []string{"dev", "seed"}, expect: true},
+		{filter: "seed,!dev", tags: []string{"dev", "seed"}, expect: false},
+	}
+	for _, v := range tt {
+		f, err := ParseTagFilter(v.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Match(v.tags); got != v.expect {
+			t.Errorf("%q matching %v: expected %v, got %v", v.filter, v.tags, v.expect, got)
+		}
+	}
+
+	if _, err := ParseTagFilter("seed,!"); err == nil {
+		t.Error("expected an error for an empty excluded tag")
+	}
+}
+
+func TestUpTags(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":            {Data: []byte("CREATE 1")},
+		"2_seed_users@seed.up.sql": {Data: []byte("SEED 2")},
+		"3_more.up.sql":            {Data: []byte("-- histomigrate: tags=backfill\nCREATE 3")},
+		"4_fixtures.up.sql":        {Data: []byte("-- histomigrate: tags=seed|test\nSEED 4")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Tags = TagFilter{Exclude: []string{"seed"}}
+	plan, err := m.Plan(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPlan := []PlannedMigration{
+		{Version: 1, Identifier: "init"},
+		{Version: 2, Identifier: "seed_users@seed", Tags: []string{"seed"}, Skipped: true},
+		{Version: 3, Identifier: "more", Tags: []string{"backfill"}},
+		{Version: 4, Identifier: "fixtures", Tags: []string{"seed", "test"}, Skipped: true},
+	}
+	if !reflect.DeepEqual(plan, expectPlan) {
+		t.Fatalf("expected plan %+v, got %+v", expectPlan, plan)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if len(dbDrv.Applied) != 2 || !dbDrv.EqualSequence([]string{"CREATE 1", "-- histomigrate: tags=backfill\nCREATE 3"}) {
+		t.Fatalf("unexpected sequence %v, applied %v", dbDrv.MigrationSequence, dbDrv.Applied)
+	}
+
+	// skipped migrations stay pending
+	if err := m.Up(); !errors.Is(err, ErrNoChange) {
+		t.Fatalf("expected ErrNoChange, got %v", err)
+	}
+
+	m.Tags = TagFilter{Include: []string{"seed"}}
+	if err := m.Steps(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dbDrv.Applied[2]; !ok || len(dbDrv.Applied) != 3 {
+		t.Fatalf("expected migration 2 to be applied, got %v", dbDrv.Applied)
+	}
+
+	m.Tags = TagFilter{}
+	plan, err = m.Plan(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 1 || plan[0].Version != 4 || plan[0].Skipped {
+		t.Fatalf("expected migration 4 to be pending, got %+v", plan)
+	}
+}
+
+func TestUpTagsIncludeUntagged(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":              {Data: []byte("CREATE 1")},
+		"2_seed_users@seed.up.sql":   {Data: []byte("SEED 2")},
+		"3_backfill@backfill.up.sql": {Data: []byte("BACKFILL 3")},
+		"4_orders.up.sql":            {Data: []byte("CREATE 4")},
+	}
+
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDrv := db.(*dStub.ExtendedStub)
+
+	m, err := NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Tags = TagFilter{Include: []string{"seed"}}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !dbDrv.EqualSequence([]string{"CREATE 1", "SEED 2", "CREATE 4"}) {
+		t.Fatalf("expected the untagged migrations and the seed to run, got %v", dbDrv.MigrationSequence)
+	}
+	if _, ok := dbDrv.Applied[3]; ok {
+		t.Fatalf("expected migration 3 to stay pending, got %v", dbDrv.Applied)
+	}
+}
+
+func TestUpTagsNotSupported(t *testing.T) {
+	m, err := newMigrateWithFS(t, fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("CREATE 1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Tags = TagFilter{Exclude: []string{"seed"}}
+	if err := m.Up(); !errors.Is(err, ErrTagsNotSupported) {
+		t.Fatalf("expected ErrTagsNotSupported, got %v", err)
+	}
+	if _, err := m.Plan(-1); !errors.Is(err, ErrTagsNotSupported) {
+		t.Fatalf("expected ErrTagsNotSupported, got %v", err)
+	}
+}
+
+func TestPlanDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_init.up.sql":      {Data: []byte("CREATE 1")},
+		"1_init.down.sql":    {Data: []byte("DROP 1")},
+		"3_users.up.sql":     {Data: []byte("CREATE 3")},
+		"3_users.down.sql":   {Data: []byte("DROP 3")},
+		"4_orders.down.sql":  {Data: []byte("DROP 4")},
+		"5_pending.up.sql":   {Data: []byte("CREATE 5")},
+		"5_pending.down.sql": {Data: []byte("DROP 5")},
+	}
+
+	extended, err := dStub.WithExtendedInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := dStub.WithInstance(nil, &dStub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, db := range map[string]database.Driver{"extended": extended, "basic": basic} {
+		t.Run(name, func(t *testing.T) {
+			src, err := iofs.New(fsys, ".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewWithInstance("iofs", src, "stub", db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if plan, err := m.PlanDown(-1); err != nil || len(plan) != 0 {
+				t.Fatalf("expected an empty plan, got %v, %v", plan, err)
+			}
+			if err := m.Steps(3); err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := m.PlanDown(-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []PlannedMigration{{Version: 4, Identifier: "orders"}, {Version: 3, Identifier: "users"}, {Version: 1, Identifier: "init"}}
+			if !reflect.DeepEqual(plan, expected) {
+				t.Fatalf("expected %v, got %v", expected, plan)
+			}
+			if plan, err = m.PlanDown(2); err != nil || !reflect.DeepEqual(plan, expected[:2]) {
+				t.Fatalf("expected %v, got %v, %v", expected[:2], plan, err)
+			}
+		})
+	}
+}
@@ -764,6 +764,18 @@ func TestStepsDirty(t *testing.T) {
 	}
 }
 
+func TestMissingVersion(t *testing.T) {
+	m, _ := New("stub://", "stub://")
+	m.sourceDrv.(*sStub.Stub).Migrations = sourceStubMigrations
+
+	if err := m.Migrate(2); !errors.Is(err, ErrMissingVersion) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrMissingVersion migrating to a version not in the source, got %v", err)
+	}
+	if err := m.Steps(-1); !errors.Is(err, ErrMissingVersion) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrMissingVersion stepping below the nil version, got %v", err)
+	}
+}
+
 func TestUpAndDown(t *testing.T) {
 	m, _ := New("stub://", "stub://")
 	m.sourceDrv.(*sStub.Stub).Migrations = sourceStubMigrations
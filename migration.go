@@ -2,9 +2,14 @@ package migrate
 
 import (
 	"bufio"
+	"context"
+	"database/sql"
 	"fmt"
 	"io"
 	"time"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/hashicorp/go-multierror"
 )
 
 // DefaultBufferSize sets the in memory buffer size (in Bytes) for every
@@ -58,6 +63,21 @@ type Migration struct {
 	// UpKindMigration indicates the direction of the migration.
 	// true for "up" (applying/forward), false for "down" (rolling back/reverse).
 	UpKindMigration bool
+
+	// Func holds the function of a migration implemented in Go, see source/gofunc.
+	// It is run through database.FuncDriver instead of a body.
+	Func func(ctx context.Context, tx *sql.Tx) error
+
+	// Directives holds the settings given in the header of the migration, see source.Directives.
+	Directives source.Directives
+
+	// Tags holds the tags given by the identifier (see source.IdentifierTags) and the tags directive.
+	Tags []string
+}
+
+// funcBody is implemented by bodies of migrations implemented in Go, see source/gofunc.
+type funcBody interface {
+	MigrationFunc() func(ctx context.Context, tx *sql.Tx) error
 }
 
 // NewMigration returns a new Migration and sets the body, identifier,
@@ -85,6 +105,16 @@ func NewMigration(body io.ReadCloser, identifier string,
 		Version:       version,
 		TargetVersion: targetVersion,
 		Scheduled:     tnow,
+		Tags:          source.IdentifierTags(identifier),
+	}
+
+	// migrations implemented in Go have nothing to buffer
+	if fb, ok := body.(funcBody); ok {
+		m.Func = fb.MigrationFunc()
+		if err := body.Close(); err != nil {
+			return nil, err
+		}
+		body = nil
 	}
 
 	if body == nil {
@@ -98,6 +128,17 @@ func NewMigration(body io.ReadCloser, identifier string,
 		return m, nil
 	}
 
+	directives, r, err := source.ReadDirectives(body)
+	if err != nil {
+		if errClose := body.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+		return nil, fmt.Errorf("migration %d: %w", version, err)
+	}
+	m.Directives = directives
+	m.Tags = append(m.Tags, directives.Tags...)
+	body = readCloser{Reader: r, Closer: body}
+
 	br, bw := io.Pipe()
 	m.Body = body // want to simulate low latency? newSlowReader(body)
 	m.BufferSize = DefaultBufferSize
@@ -106,6 +147,12 @@ func NewMigration(body io.ReadCloser, identifier string,
 	return m, nil
 }
 
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
 // String implements string.Stringer and is used in tests.
 func (m *Migration) String() string {
 	return fmt.Sprintf("%v [%v=>%v]", m.Identifier, m.Version, m.TargetVersion)
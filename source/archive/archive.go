@@ -0,0 +1,164 @@
+// Package archive reads migrations from tar, tar.gz and zip archives without unpacking them.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	nurl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	"github.com/hashicorp/go-multierror"
+)
+
+func init() {
+	source.Register("archive", &Archive{})
+}
+
+var (
+	ErrUnknownFormat  = errors.New("unknown archive format, expected .tar, .tar.gz, .tgz or .zip")
+	ErrDuplicateEntry = errors.New("duplicate archive entry")
+)
+
+// Archive is a source driver reading migrations from an archive.
+type Archive struct {
+	iofs.PartialDriver
+	url  string
+	path string
+}
+
+// Open opens the archive at the location of url, e.g.
+// archive:///releases/migrations.tar.gz?path=db/
+// The path query parameter selects the directory of the migrations within the archive,
+// defaulting to its root. The format is given by the file extension.
+func (a *Archive) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	p := u.Opaque
+	if len(p) == 0 {
+		p = u.Host + u.Path
+	}
+	if len(p) == 0 {
+		return nil, errors.New("no archive path")
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return nil, err
+	}
+
+	dir := u.Query().Get("path")
+
+	var d source.Driver
+	switch name := strings.ToLower(p); {
+	case strings.HasSuffix(name, ".zip"):
+		d, err = openZip(p, dir)
+	case strings.HasSuffix(name, ".tar"):
+		d, err = openTar(p, dir, false)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		d, err = openTar(p, dir, true)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.(*Archive).url = url
+	d.(*Archive).path = p
+	return d, nil
+}
+
+func openZip(name string, dir string) (source.Driver, error) {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := withZip(r, &r.Reader, dir)
+	if err != nil {
+		if errClose := r.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+func openTar(name string, dir string, gzipped bool) (source.Driver, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return WithTar(r, dir)
+}
+
+// WithZip returns a driver reading the migrations in directory dir of a zip archive.
+func WithZip(r *zip.Reader, dir string) (source.Driver, error) {
+	return withZip(r, r, dir)
+}
+
+// withZip validates the entries of r and reads migrations through fsys,
+// which is either r or the *zip.ReadCloser holding it.
+func withZip(fsys fs.FS, r *zip.Reader, dir string) (source.Driver, error) {
+	seen := make(map[string]bool, len(r.File))
+	for _, f := range r.File {
+		name := entryName(f.Name)
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateEntry, f.Name)
+		}
+		seen[name] = true
+	}
+
+	return newArchive(fsys, dir)
+}
+
+// WithTar returns a driver reading the migrations in directory dir of an uncompressed tar archive.
+// The archive is read into memory; r isn't used after WithTar returns.
+func WithTar(r io.Reader, dir string) (source.Driver, error) {
+	fsys, err := readTar(tar.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return newArchive(fsys, dir)
+}
+
+func newArchive(fsys fs.FS, dir string) (source.Driver, error) {
+	a := &Archive{}
+	if err := a.Init(fsys, entryName(dir)); err != nil {
+		return nil, fmt.Errorf("failed to init driver with path %s: %w", dir, err)
+	}
+	return a, nil
+}
+
+// entryName cleans the name of an archive entry into an io/fs path,
+// e.g. "./db/1_init.up.sql" into "db/1_init.up.sql" and "" into ".".
+func entryName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+const scheme = "archive://"
+
+// migrations meet the driver test requirements, in directory db of the archives.
+var migrations = []struct{ name, body string }{
+	{"./db/1_foobar.up.sql", "1 up"},
+	{"./db/1_foobar.down.sql", "1 down"},
+	{"./db/3_foobar.up.sql", "3 up"},
+	{"./db/4_foobar.up.sql", "4 up"},
+	{"./db/4_foobar.down.sql", "4 down"},
+	{"./db/5_foobar.down.sql", "5 down"},
+	{"./db/7_foobar.up.sql", "7 up"},
+	{"./db/7_foobar.down.sql", "7 down"},
+	{"README.md", "not a migration"},
+}
+
+func Test(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"migrations.tar", "migrations.tar.gz", "migrations.tgz", "migrations.zip"} {
+		t.Run(name, func(t *testing.T) {
+			p := filepath.Join(dir, name)
+			mustWriteArchive(t, p, migrations)
+
+			d, err := (&Archive{}).Open(scheme + p + "?path=db/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			st.Test(t, d)
+		})
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := (&Archive{}).Open(scheme + filepath.Join(dir, "migrations.rar")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+
+	duplicates := append(migrations[:len(migrations):len(migrations)], struct{ name, body string }{"db/1_foobar.up.sql", "1 up again"})
+	for _, name := range []string{"duplicates.tar", "duplicates.zip"} {
+		p := filepath.Join(dir, name)
+		mustWriteArchive(t, p, duplicates)
+		if _, err := (&Archive{}).Open(scheme + p + "?path=db"); !errors.Is(err, ErrDuplicateEntry) {
+			t.Errorf("%s: expected ErrDuplicateEntry, got %v", name, err)
+		}
+	}
+
+	p := filepath.Join(dir, "migrations.tar")
+	mustWriteArchive(t, p, migrations)
+	if _, err := (&Archive{}).Open(scheme + p + "?path=nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for a missing path, got %v", err)
+	}
+}
+
+func mustWriteArchive(t *testing.T, name string, files []struct{ name, body string }) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch filepath.Ext(name) {
+	case ".zip":
+		w := zip.NewWriter(&buf)
+		for _, f := range files {
+			fw, err := w.Create(f.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write([]byte(f.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		var tw *tar.Writer
+		var gz *gzip.Writer
+		if filepath.Ext(name) == ".tar" {
+			tw = tar.NewWriter(&buf)
+		} else {
+			gz = gzip.NewWriter(&buf)
+			tw = tar.NewWriter(gz)
+		}
+		for _, f := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(f.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// tarFS is a read-only, in-memory fs.FS holding the regular files of a tar archive.
+type tarFS struct {
+	files map[string]*tarFile
+	dirs  map[string][]fs.DirEntry
+}
+
+type tarFile struct {
+	name    string // base name
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// readTar reads all regular files of r. Entries sharing a name fail with ErrDuplicateEntry,
+// as tar archives may hold several versions of a file.
+func readTar(r *tar.Reader) (*tarFS, error) {
+	t := &tarFS{
+		files: make(map[string]*tarFile),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+
+	for {
+		hdr, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := entryName(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			t.addDir(name)
+			continue
+		case tar.TypeReg:
+		default:
+			// links, devices and the like can't hold migrations
+			continue
+		}
+
+		if _, dup := t.files[name]; dup {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateEntry, hdr.Name)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &tarFile{
+			name:    path.Base(name),
+			data:    data,
+			mode:    fs.FileMode(hdr.Mode).Perm(),
+			modTime: hdr.ModTime,
+		}
+		t.files[name] = f
+		dir := path.Dir(name)
+		t.addDir(dir)
+		t.dirs[dir] = append(t.dirs[dir], fs.FileInfoToDirEntry(f))
+	}
+
+	for _, entries := range t.dirs {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	}
+	return t, nil
+}
+
+// addDir adds dir and its parents.
+func (t *tarFS) addDir(dir string) {
+	for dir != "." {
+		if _, ok := t.dirs[dir]; ok {
+			return
+		}
+		t.dirs[dir] = nil
+		parent := path.Dir(dir)
+		t.dirs[parent] = append(t.dirs[parent], fs.FileInfoToDirEntry(&tarFile{name: path.Base(dir), mode: fs.ModeDir | 0o555}))
+		dir = parent
+	}
+}
+
+// Open implements fs.FS. Only regular files can be opened.
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &openTarFile{tarFile: f, Reader: bytes.NewReader(f.data)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := t.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]fs.DirEntry(nil), entries...), nil
+}
+
+// tarFile implements fs.FileInfo.
+func (f *tarFile) Name() string       { return f.name }
+func (f *tarFile) Size() int64        { return int64(len(f.data)) }
+func (f *tarFile) Mode() fs.FileMode  { return f.mode }
+func (f *tarFile) ModTime() time.Time { return f.modTime }
+func (f *tarFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *tarFile) Sys() interface{}   { return nil }
+
+type openTarFile struct {
+	*tarFile
+	*bytes.Reader
+}
+
+func (f *openTarFile) Stat() (fs.FileInfo, error) { return f.tarFile, nil }
+func (f *openTarFile) Close() error               { return nil }
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/abramad-labs/histomigrate/source"
@@ -23,20 +24,40 @@ type s3Driver struct {
 	s3client   s3iface.S3API
 	config     *Config
 	migrations *source.Migrations
+	etags      map[string]string // by file name
 }
 
 type Config struct {
 	Bucket string
 	Prefix string
+
+	// Endpoint, Region and ForcePathStyle configure the client created by Open,
+	// e.g. for S3-compatible stores such as MinIO. They are ignored by WithInstance.
+	Endpoint       string
+	Region         string
+	ForcePathStyle bool
 }
 
+// Open reads the migrations stored under a prefix of a bucket, e.g.
+// s3://migrations/production?x-endpoint=http://localhost:9000&x-path-style=true
 func (s *s3Driver) Open(folder string) (source.Driver, error) {
 	config, err := parseURI(folder)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := session.NewSession()
+	awsConfig := aws.NewConfig()
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	if config.Region != "" {
+		awsConfig = awsConfig.WithRegion(config.Region)
+	}
+	if config.ForcePathStyle {
+		awsConfig = awsConfig.WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +70,7 @@ func WithInstance(s3client s3iface.S3API, config *Config) (source.Driver, error)
 		config:     config,
 		s3client:   s3client,
 		migrations: source.NewMigrations(),
+		etags:      make(map[string]string),
 	}
 
 	if err := driver.loadMigrations(); err != nil {
@@ -69,32 +91,51 @@ func parseURI(uri string) (*Config, error) {
 		prefix += "/"
 	}
 
-	return &Config{
-		Bucket: u.Host,
-		Prefix: prefix,
-	}, nil
+	config := &Config{
+		Bucket:   u.Host,
+		Prefix:   prefix,
+		Endpoint: u.Query().Get("x-endpoint"),
+		Region:   u.Query().Get("x-region"),
+	}
+	if s := u.Query().Get("x-path-style"); s != "" {
+		if config.ForcePathStyle, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("invalid x-path-style: %w", err)
+		}
+	}
+
+	return config, nil
 }
 
+// loadMigrations lists all objects directly under the prefix, following continuation tokens
+// since a single ListObjectsV2 call returns at most 1000 keys.
 func (s *s3Driver) loadMigrations() error {
-	output, err := s.s3client.ListObjects(&s3.ListObjectsInput{
+	input := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(s.config.Bucket),
 		Prefix:    aws.String(s.config.Prefix),
 		Delimiter: aws.String("/"),
-	})
-	if err != nil {
-		return err
 	}
-	for _, object := range output.Contents {
-		_, fileName := path.Split(aws.StringValue(object.Key))
-		m, err := source.DefaultParse(fileName)
+	for {
+		output, err := s.s3client.ListObjectsV2(input)
 		if err != nil {
-			continue
+			return err
 		}
-		if !s.migrations.Append(m) {
-			return fmt.Errorf("unable to parse file %v", aws.StringValue(object.Key))
+		for _, object := range output.Contents {
+			_, fileName := path.Split(aws.StringValue(object.Key))
+			m, err := source.DefaultParse(fileName)
+			if err != nil {
+				continue
+			}
+			if !s.migrations.Append(m) {
+				return fmt.Errorf("unable to parse file %v", aws.StringValue(object.Key))
+			}
+			s.etags[fileName] = aws.StringValue(object.ETag)
+		}
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return nil
 		}
+		input.ContinuationToken = output.NextContinuationToken
 	}
-	return nil
 }
 
 func (s *s3Driver) Close() error {
@@ -139,6 +180,14 @@ func (s *s3Driver) ReadDown(version uint) (io.ReadCloser, string, error) {
 	return nil, "", os.ErrNotExist
 }
 
+// Revision implements source.Revisioner with the ETag of the object.
+func (s *s3Driver) Revision(version uint, direction source.Direction) (string, error) {
+	if m, ok := s.migrations.Get(version, direction); ok {
+		return s.etags[m.Raw], nil
+	}
+	return "", os.ErrNotExist
+}
+
 func (s *s3Driver) open(m *source.Migration) (io.ReadCloser, string, error) {
 	key := path.Join(s.config.Prefix, m.Raw)
 	object, err := s.s3client.GetObject(&s3.GetObjectInput{
@@ -2,7 +2,9 @@ package awss3
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"testing"
 
@@ -14,7 +16,8 @@ import (
 
 func Test(t *testing.T) {
 	s3Client := fakeS3{
-		bucket: "some-bucket",
+		bucket:   "some-bucket",
+		pageSize: 2,
 		objects: map[string]string{
 			"staging/migrations/1_foobar.up.sql":          "1 up",
 			"staging/migrations/1_foobar.down.sql":        "1 down",
@@ -40,6 +43,32 @@ func Test(t *testing.T) {
 	st.Test(t, driver)
 }
 
+func TestPagination(t *testing.T) {
+	s3Client := fakeS3{
+		bucket:   "some-bucket",
+		pageSize: 10,
+		objects:  map[string]string{},
+	}
+	for v := 1; v <= 25; v++ {
+		s3Client.objects[fmt.Sprintf("migrations/%d_foobar.up.sql", v)] = fmt.Sprintf("%d up", v)
+	}
+	driver, err := WithInstance(&s3Client, &Config{
+		Bucket: "some-bucket",
+		Prefix: "migrations/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	version, err := driver.First()
+	for err == nil {
+		count++
+		version, err = driver.Next(version)
+	}
+	assert.Equal(t, 25, count)
+}
+
 func TestParseURI(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -76,6 +105,17 @@ func TestParseURI(t *testing.T) {
 				Bucket: "migration-bucket",
 			},
 		},
+		{
+			"with endpoint, region and path style",
+			"s3://migration-bucket/production?x-endpoint=http://localhost:9000&x-region=us-east-1&x-path-style=true",
+			&Config{
+				Bucket:         "migration-bucket",
+				Prefix:         "production/",
+				Endpoint:       "http://localhost:9000",
+				Region:         "us-east-1",
+				ForcePathStyle: true,
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -90,27 +130,48 @@ func TestParseURI(t *testing.T) {
 
 type fakeS3 struct {
 	s3.S3
-	bucket  string
-	objects map[string]string
+	bucket   string
+	objects  map[string]string
+	pageSize int
 }
 
-func (s *fakeS3) ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsOutput, error) {
+// ListObjectsV2 returns keys in lexical order, at most pageSize of them per call.
+func (s *fakeS3) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
 	bucket := aws.StringValue(input.Bucket)
 	if bucket != s.bucket {
 		return nil, errors.New("bucket not found")
 	}
 	prefix := aws.StringValue(input.Prefix)
 	delimiter := aws.StringValue(input.Delimiter)
-	var output s3.ListObjectsOutput
+	var names []string
 	for name := range s.objects {
 		if strings.HasPrefix(name, prefix) {
 			if delimiter == "" || !strings.Contains(strings.Replace(name, prefix, "", 1), delimiter) {
-				output.Contents = append(output.Contents, &s3.Object{
-					Key: aws.String(name),
-				})
+				names = append(names, name)
 			}
 		}
 	}
+	sort.Strings(names)
+
+	start := 0
+	if token := aws.StringValue(input.ContinuationToken); token != "" {
+		start = sort.SearchStrings(names, token)
+	}
+	end := len(names)
+	if s.pageSize > 0 && start+s.pageSize < end {
+		end = start + s.pageSize
+	}
+
+	var output s3.ListObjectsV2Output
+	for _, name := range names[start:end] {
+		output.Contents = append(output.Contents, &s3.Object{
+			Key: aws.String(name),
+		})
+	}
+	if end < len(names) {
+		output.IsTruncated = aws.Bool(true)
+		output.NextContinuationToken = aws.String(names[end])
+	}
 	return &output, nil
 }
 
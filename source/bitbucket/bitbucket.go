@@ -29,6 +29,7 @@ type Bitbucket struct {
 	config     *Config
 	client     *bitbucket.Client
 	migrations *source.Migrations
+	commits    map[string]string // by file name
 }
 
 type Config struct {
@@ -106,6 +107,7 @@ func (b *Bitbucket) readDirectory() error {
 		return err
 	}
 
+	b.commits = make(map[string]string)
 	for _, fi := range dirContents {
 
 		m, err := source.DefaultParse(filepath.Base(fi.Path))
@@ -115,6 +117,9 @@ func (b *Bitbucket) readDirectory() error {
 		if !b.migrations.Append(m) {
 			return fmt.Errorf("unable to parse file %v", fi.Path)
 		}
+		if hash, ok := fi.Commit["hash"].(string); ok {
+			b.commits[m.Raw] = hash
+		}
 	}
 
 	return nil
@@ -160,6 +165,16 @@ func (b *Bitbucket) Next(version uint) (nextVersion uint, err error) {
 	}
 }
 
+// Revision implements source.Revisioner with the commit the file was listed at.
+func (b *Bitbucket) Revision(version uint, direction source.Direction) (string, error) {
+	b.ensureFields()
+
+	if m, ok := b.migrations.Get(version, direction); ok {
+		return b.commits[m.Raw], nil
+	}
+	return "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: b.config.Path, Err: os.ErrNotExist}
+}
+
 func (b *Bitbucket) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
 	b.ensureFields()
 
@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abramad-labs/histomigrate/source"
+	_ "github.com/abramad-labs/histomigrate/source/file"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+func migrationsFS() fstest.MapFS {
+	return fstest.MapFS{
+		"1_foobar.up.sql":   {Data: []byte("1 up")},
+		"1_foobar.down.sql": {Data: []byte("1 down")},
+		"3_foobar.up.sql":   {Data: []byte("3 up")},
+		"4_foobar.up.sql":   {Data: []byte("4 up")},
+		"4_foobar.down.sql": {Data: []byte("4 down")},
+		"5_foobar.down.sql": {Data: []byte("5 down")},
+		"7_foobar.up.sql":   {Data: []byte("7 up")},
+		"7_foobar.down.sql": {Data: []byte("7 down")},
+	}
+}
+
+// revisioner counts the reads of an iofs driver and reports a fixed revision for all migrations.
+type revisioner struct {
+	source.Driver
+	revision string
+	reads    int
+}
+
+func (r *revisioner) ReadUp(version uint) (io.ReadCloser, string, error) {
+	r.reads++
+	return r.Driver.ReadUp(version)
+}
+
+func (r *revisioner) ReadDown(version uint) (io.ReadCloser, string, error) {
+	r.reads++
+	return r.Driver.ReadDown(version)
+}
+
+func (r *revisioner) Revision(version uint, direction source.Direction) (string, error) {
+	body, _, err := r.Driver.ReadUp(version)
+	if direction == source.Down {
+		body, _, err = r.Driver.ReadDown(version)
+	}
+	if err != nil {
+		return "", err
+	}
+	return r.revision, body.Close()
+}
+
+func newRevisioner(t *testing.T, fsys fstest.MapFS, revision string) *revisioner {
+	t.Helper()
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &revisioner{Driver: d, revision: revision}
+}
+
+func readAll(t *testing.T, d source.Driver, version uint) string {
+	t.Helper()
+	r, _, err := d.ReadUp(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func Test(t *testing.T) {
+	config := &Config{Dir: t.TempDir()}
+	d, err := WithInstance(newRevisioner(t, migrationsFS(), "r1"), "test://", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, d)
+
+	// everything has been read once, so it can all be served offline
+	config.Offline = true
+	d, err = WithInstance(nil, "test://", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, d)
+}
+
+func TestRevision(t *testing.T) {
+	dir := t.TempDir()
+	fsys := migrationsFS()
+
+	src := newRevisioner(t, fsys, "r1")
+	d, err := WithInstance(src, "test://", &Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, d, 1)
+	if src.reads != 1 {
+		t.Fatalf("expected 1 read, got %d", src.reads)
+	}
+
+	// same revision: served from the cache
+	src = newRevisioner(t, fsys, "r1")
+	if d, err = WithInstance(src, "test://", &Config{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if body := readAll(t, d, 1); body != "1 up" {
+		t.Fatalf("expected 1 up, got %q", body)
+	}
+	if src.reads != 0 {
+		t.Fatalf("expected no reads, got %d", src.reads)
+	}
+
+	// new revision: read again
+	fsys["1_foobar.up.sql"] = &fstest.MapFile{Data: []byte("1 up, changed")}
+	src = newRevisioner(t, fsys, "r2")
+	if d, err = WithInstance(src, "test://", &Config{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if body := readAll(t, d, 1); body != "1 up, changed" {
+		t.Fatalf("expected the changed body, got %q", body)
+	}
+	if src.reads != 1 {
+		t.Fatalf("expected 1 read, got %d", src.reads)
+	}
+
+	// offline: the latest body
+	if d, err = WithInstance(nil, "test://", &Config{Dir: dir, Offline: true}); err != nil {
+		t.Fatal(err)
+	}
+	if body := readAll(t, d, 1); body != "1 up, changed" {
+		t.Fatalf("expected the changed body, got %q", body)
+	}
+}
+
+func TestWithoutRevision(t *testing.T) {
+	dir := t.TempDir()
+	src := newRevisioner(t, migrationsFS(), "")
+
+	for i := 0; i < 2; i++ {
+		d, err := WithInstance(src, "test://", &Config{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		readAll(t, d, 1)
+	}
+	if src.reads != 2 {
+		t.Fatalf("bodies without revision must always be read, got %d reads", src.reads)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := WithInstance(nil, "test://", &Config{Dir: dir, Offline: true}); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+
+	d, err := WithInstance(newRevisioner(t, migrationsFS(), "r1"), "test://", &Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, d, 1)
+	if _, _, err := d.ReadDown(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.ReadUp(5); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+
+	if d, err = WithInstance(nil, "test://", &Config{Dir: dir, Offline: true}); err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, d, 1)
+	if _, _, err := d.ReadUp(5); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+	if _, _, err := d.ReadUp(3); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+	if v, err := d.Next(5); err != nil || v != 7 {
+		t.Fatalf("expected next version 7, got %d, %v", v, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	migrations := t.TempDir()
+	if err := os.WriteFile(filepath.Join(migrations, "1_foobar.up.sql"), []byte("1 up"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := source.Open("file://" + migrations + "?x-cache-dir=" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.(*Cache); !ok {
+		t.Fatalf("expected *Cache, got %T", d)
+	}
+	readAll(t, d, 1)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(migrations, "1_foobar.up.sql")); err != nil {
+		t.Fatal(err)
+	}
+	d, err = source.Open("file://" + migrations + "?x-cache-dir=" + dir + "&x-cache-offline=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if body := readAll(t, d, 1); body != "1 up" {
+		t.Fatalf("expected 1 up, got %q", body)
+	}
+}
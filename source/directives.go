@@ -0,0 +1,126 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDirective is returned for directives that are unknown or have an invalid value.
+var ErrDirective = errors.New("invalid directive")
+
+// DirectiveRegex matches a directive line in the header of a migration:
+//
+//	-- histomigrate: no-transaction, timeout=5m, tags=backfill, depends=20240101
+var DirectiveRegex = regexp.MustCompile(`^\s*--\s*histomigrate:(.*)$`)
+
+// Directives are per-migration settings given in the header of a migration,
+// the comment and blank lines before its first statement.
+//
+// A directive line holds a comma-separated list of directives:
+//
+//	no-transaction    run the migration outside of a transaction
+//	timeout=5m        cancel the migration after the given duration
+//	tags=a|b          tag the migration, see Tags
+//	depends=1|2       require the given versions to be applied first
+//
+// Several directive lines are merged.
+type Directives struct {
+	NoTransaction bool
+	Timeout       time.Duration
+	Tags          []string
+	Depends       []uint
+}
+
+// IsZero reports whether no directive is set.
+func (d Directives) IsZero() bool {
+	return !d.NoTransaction && d.Timeout == 0 && len(d.Tags) == 0 && len(d.Depends) == 0
+}
+
+// ParseDirectives returns the directives of all directive lines in header.
+// Lines not matching DirectiveRegex are ignored.
+func ParseDirectives(header []byte) (Directives, error) {
+	var d Directives
+	for _, line := range bytes.Split(header, []byte("\n")) {
+		m := DirectiveRegex.FindSubmatch(bytes.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		for _, directive := range strings.Split(string(m[1]), ",") {
+			if err := d.set(strings.TrimSpace(directive)); err != nil {
+				return Directives{}, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *Directives) set(directive string) error {
+	key, value, hasValue := strings.Cut(directive, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	switch {
+	case key == "":
+		return nil
+	case key == "no-transaction" && !hasValue:
+		d.NoTransaction = true
+	case key == "timeout" && hasValue:
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("%w: %q", ErrDirective, directive)
+		}
+		d.Timeout = timeout
+	case key == "tags" && hasValue:
+		for _, tag := range strings.Split(value, "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				d.Tags = append(d.Tags, tag)
+			}
+		}
+	case key == "depends" && hasValue:
+		for _, v := range strings.Split(value, "|") {
+			version, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %q", ErrDirective, directive)
+			}
+			d.Depends = append(d.Depends, uint(version))
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrDirective, directive)
+	}
+
+	return nil
+}
+
+// ReadDirectives reads the header of a migration from r and parses its directives.
+// The returned reader yields the full migration, including the header.
+func ReadDirectives(r io.Reader) (Directives, io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	var header bytes.Buffer
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return Directives{}, nil, err
+		}
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 && !bytes.HasPrefix(trimmed, []byte("--")) {
+			// first statement, not part of the header
+			body := io.MultiReader(bytes.NewReader(header.Bytes()), bytes.NewReader(line), br)
+			d, err := ParseDirectives(header.Bytes())
+			return d, body, err
+		}
+
+		header.Write(line)
+		if err == io.EOF {
+			d, err := ParseDirectives(header.Bytes())
+			return d, &header, err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package source
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	header := "-- a comment\n" +
+		"-- histomigrate: no-transaction, timeout=5m, tags=backfill\n" +
+		"--histomigrate: tags=slow|seed, depends=20240101|20240102\r\n"
+
+	d, err := ParseDirectives([]byte(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Directives{
+		NoTransaction: true,
+		Timeout:       5 * time.Minute,
+		Tags:          []string{"backfill", "slow", "seed"},
+		Depends:       []uint{20240101, 20240102},
+	}
+	if !reflect.DeepEqual(d, expect) {
+		t.Errorf("expected %+v, got %+v", expect, d)
+	}
+
+	for _, invalid := range []string{
+		"-- histomigrate: transaction",
+		"-- histomigrate: timeout=soon",
+		"-- histomigrate: timeout=-1s",
+		"-- histomigrate: depends=first",
+		"-- histomigrate: no-transaction=false",
+	} {
+		if _, err := ParseDirectives([]byte(invalid)); !errors.Is(err, ErrDirective) {
+			t.Errorf("expected ErrDirective for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestReadDirectives(t *testing.T) {
+	tt := []struct {
+		name    string
+		content string
+		expect  Directives
+	}{
+		{
+			name:    "header",
+			content: "-- histomigrate: no-transaction\n\nCREATE INDEX CONCURRENTLY i ON t (c);\n",
+			expect:  Directives{NoTransaction: true},
+		},
+		{
+			name:    "after first statement",
+			content: "SELECT 1;\n-- histomigrate: no-transaction\n",
+		},
+		{
+			name:    "header only",
+			content: "-- histomigrate: tags=empty",
+			expect:  Directives{Tags: []string{"empty"}},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d, r, err := ReadDirectives(strings.NewReader(tc.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(d, tc.expect) {
+				t.Errorf("expected %+v, got %+v", tc.expect, d)
+			}
+			body, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tc.content {
+				t.Errorf("expected the full migration %q, got %q", tc.content, body)
+			}
+		})
+	}
+}
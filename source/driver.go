@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io"
 	nurl "net/url"
+	"sort"
 	"sync"
 )
 
 var driversMu sync.RWMutex
 var drivers = make(map[string]Driver)
+var wrappers = make(map[string]Wrapper)
 
 // Driver is the interface every source driver must implement.
 //
@@ -72,7 +74,40 @@ type Driver interface {
 	ReadDown(version uint) (r io.ReadCloser, identifier string, err error)
 }
 
+// RepeatableDriver is an optional interface for source drivers providing
+// repeatable migrations, see Repeatable.
+type RepeatableDriver interface {
+	Driver
+
+	// Repeatables returns the names of all repeatable migrations available
+	// to the driver, in the order they must run.
+	Repeatables() (names []string, err error)
+
+	// ReadRepeatable returns the body and an identifier of the repeatable
+	// migration with the given name.
+	// If there is no such migration, it must return os.ErrNotExist.
+	// Do not start reading, just return the ReadCloser!
+	ReadRepeatable(name string) (r io.ReadCloser, identifier string, err error)
+}
+
+// Revisioner is an optional interface for source drivers that learn the revision
+// of their migrations when listing them, e.g. an ETag or the SHA of a git blob.
+// It lets wrappers such as the cache tell whether a migration changed without reading it.
+type Revisioner interface {
+	Driver
+
+	// Revision returns an opaque string that changes whenever the body of the
+	// migration changes, or "" if the revision isn't known.
+	// If there is no migration for this version and direction,
+	// it must return os.ErrNotExist.
+	Revision(version uint, direction Direction) (revision string, err error)
+}
+
+// Wrapper opens a driver wrapping the one given by url, see RegisterWrapper.
+type Wrapper func(url string) (Driver, error)
+
 // Open returns a new driver instance.
+// URLs with the query parameter of a registered wrapper are opened by the wrapper.
 func Open(url string) (Driver, error) {
 	u, err := nurl.Parse(url)
 	if err != nil {
@@ -84,6 +119,13 @@ func Open(url string) (Driver, error) {
 	}
 
 	driversMu.RLock()
+	for _, param := range sortedKeys(wrappers) {
+		if u.Query().Has(param) {
+			wrap := wrappers[param]
+			driversMu.RUnlock()
+			return wrap(url)
+		}
+	}
 	d, ok := drivers[u.Scheme]
 	driversMu.RUnlock()
 	if !ok {
@@ -106,6 +148,29 @@ func Register(name string, driver Driver) {
 	drivers[name] = driver
 }
 
+// RegisterWrapper globally registers a wrapper for URLs having the query parameter param,
+// e.g. x-cache-dir. The wrapper must remove param before opening the wrapped driver with Open.
+func RegisterWrapper(param string, wrap Wrapper) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if wrap == nil {
+		panic("RegisterWrapper wrapper is nil")
+	}
+	if _, dup := wrappers[param]; dup {
+		panic("RegisterWrapper called twice for parameter " + param)
+	}
+	wrappers[param] = wrap
+}
+
+func sortedKeys(m map[string]Wrapper) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // List lists the registered drivers
 func List() []string {
 	driversMu.RLock()
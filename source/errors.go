@@ -13,3 +13,15 @@ type ErrDuplicateMigration struct {
 func (e ErrDuplicateMigration) Error() string {
 	return "duplicate migration file: " + e.Name()
 }
+
+// ErrDuplicateRepeatable is an error type for reporting repeatable
+// migration files sharing a name.
+type ErrDuplicateRepeatable struct {
+	Repeatable
+	os.FileInfo
+}
+
+// Error implements error interface.
+func (e ErrDuplicateRepeatable) Error() string {
+	return "duplicate repeatable migration file: " + e.FileInfo.Name()
+}
@@ -0,0 +1,254 @@
+// Package git reads migrations from a commit of a local git repository, without checking it out.
+//
+// The driver runs the git command line tool, which must be installed.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	nurl "net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+)
+
+func init() {
+	source.Register("git", &Git{})
+}
+
+// DefaultRef is read if the URL doesn't give a ref.
+const DefaultRef = "HEAD"
+
+var ErrNoRepository = errors.New("no repository path")
+
+// Git is a source driver reading migrations from a local git repository.
+type Git struct {
+	iofs.PartialDriver
+	url    string
+	repo   string
+	commit string
+}
+
+// Config selects the migrations to read from a repository.
+type Config struct {
+	// Ref is a commit, tag or branch, defaulting to DefaultRef.
+	Ref string
+	// Path is the directory of the migrations within the repository, defaulting to its root.
+	Path string
+}
+
+// Open reads the migrations of the repository at the location of url, e.g.
+// git:///path/to/repo?ref=v1.4.2&path=db/migrations
+func (g *Git) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	p := u.Opaque
+	if len(p) == 0 {
+		p = u.Host + u.Path
+	}
+	if len(p) == 0 {
+		return nil, ErrNoRepository
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return nil, err
+	}
+
+	d, err := WithInstance(p, &Config{
+		Ref:  u.Query().Get("ref"),
+		Path: u.Query().Get("path"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.(*Git).url = url
+
+	return d, nil
+}
+
+// WithInstance returns a driver reading migrations from the repository at repo.
+// The ref is resolved to a commit once, so later changes to a branch aren't seen.
+func WithInstance(repo string, config *Config) (source.Driver, error) {
+	if repo == "" {
+		return nil, ErrNoRepository
+	}
+
+	ref := config.Ref
+	if ref == "" {
+		ref = DefaultRef
+	}
+
+	fsys := &gitFS{repo: repo}
+	out, err := fsys.git("rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+	fsys.commit = strings.TrimSpace(string(out))
+
+	dir := path.Clean("/" + config.Path)[1:]
+	if dir == "" {
+		dir = "."
+	}
+
+	g := &Git{
+		repo:   repo,
+		commit: fsys.commit,
+	}
+	if err := g.Init(fsys, dir); err != nil {
+		return nil, fmt.Errorf("failed to init driver with path %s at %s: %w", dir, ref, err)
+	}
+
+	return g, nil
+}
+
+// Commit returns the commit the migrations are read from.
+func (g *Git) Commit() string {
+	return g.commit
+}
+
+// gitFS is a read-only fs.FS of the tree of a commit.
+type gitFS struct {
+	repo   string
+	commit string
+}
+
+// git runs a git command in the repository and returns its standard output.
+func (g *gitFS) git(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.repo}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Open implements fs.FS for the blobs of the tree.
+func (g *gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, err := g.lsTree(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if len(entries) != 1 || entries[0].path != name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	e := entries[0]
+	if e.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	data, err := g.git("cat-file", "blob", e.object)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{entry: e, Reader: bytes.NewReader(data)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS for the trees of the commit.
+func (g *gitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	pathspec := name + "/"
+	if name == "." {
+		pathspec = "."
+	} else if entries, err := g.lsTree(name); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	} else if len(entries) != 1 || !entries[0].IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries, err := g.lsTree(pathspec)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	dirEntries := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(e))
+	}
+	return dirEntries, nil
+}
+
+// lsTree lists the tree entries matching pathspec, sorted by name.
+func (g *gitFS) lsTree(pathspec string) ([]*entry, error) {
+	out, err := g.git("ls-tree", "-z", "-l", "--full-tree", g.commit, "--", pathspec)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*entry
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line == "" {
+			continue
+		}
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, p, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected ls-tree output %q", line)
+		}
+
+		e := &entry{path: p, object: fields[2]}
+		switch fields[1] {
+		case "tree":
+			e.mode = fs.ModeDir | 0o555
+		case "blob":
+			e.mode = 0o444
+			if e.size, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected ls-tree output %q", line)
+			}
+		default:
+			// submodules can't hold migrations
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// entry is an entry of a tree and implements fs.FileInfo.
+type entry struct {
+	path   string
+	object string
+	mode   fs.FileMode
+	size   int64
+}
+
+func (e *entry) Name() string       { return path.Base(e.path) }
+func (e *entry) Size() int64        { return e.size }
+func (e *entry) Mode() fs.FileMode  { return e.mode }
+func (e *entry) ModTime() time.Time { return time.Time{} }
+func (e *entry) IsDir() bool        { return e.mode.IsDir() }
+func (e *entry) Sys() interface{}   { return nil }
+
+type file struct {
+	entry *entry
+	*bytes.Reader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *file) Close() error               { return nil }
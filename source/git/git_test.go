@@ -0,0 +1,111 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+const scheme = "git://"
+
+func Test(t *testing.T) {
+	repo := newRepo(t)
+
+	// write files that meet driver test requirements
+	mustWriteFile(t, repo, "db/1_foobar.up.sql", "1 up")
+	mustWriteFile(t, repo, "db/1_foobar.down.sql", "1 down")
+	mustWriteFile(t, repo, "db/3_foobar.up.sql", "3 up")
+	mustWriteFile(t, repo, "db/4_foobar.up.sql", "4 up")
+	mustWriteFile(t, repo, "db/4_foobar.down.sql", "4 down")
+	mustWriteFile(t, repo, "db/5_foobar.down.sql", "5 down")
+	mustWriteFile(t, repo, "db/7_foobar.up.sql", "7 up")
+	mustWriteFile(t, repo, "db/7_foobar.down.sql", "7 down")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "release")
+	mustGit(t, repo, "tag", "v1.0.0")
+
+	// changes after the tag and in the working tree aren't read
+	mustWriteFile(t, repo, "db/8_foobar.up.sql", "8 up")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "next")
+	mustWriteFile(t, repo, "db/1_foobar.up.sql", "1 up, uncommitted")
+
+	d, err := (&Git{}).Open(scheme + repo + "?ref=v1.0.0&path=db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st.Test(t, d)
+}
+
+func TestRefs(t *testing.T) {
+	repo := newRepo(t)
+
+	mustWriteFile(t, repo, "1_init.up.sql", "v1")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "first")
+	mustWriteFile(t, repo, "1_init.up.sql", "v2")
+	mustGit(t, repo, "commit", "-q", "-am", "second")
+
+	for ref, expect := range map[string]string{"": "v2", "HEAD~1": "v1", "main": "v2"} {
+		d, err := (&Git{}).Open(scheme + repo + "?ref=" + ref)
+		if err != nil {
+			t.Fatalf("ref %q: %v", ref, err)
+		}
+		r, _, err := d.ReadUp(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != expect {
+			t.Errorf("ref %q: expected %q, got %q", ref, expect, body)
+		}
+	}
+
+	if _, err := (&Git{}).Open(scheme + repo + "?ref=nope"); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+	if _, err := (&Git{}).Open(scheme + repo + "?path=nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for a missing path, got %v", err)
+	}
+}
+
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	mustGit(t, repo, "init", "-q", "-b", "main")
+	mustGit(t, repo, "config", "user.name", "test")
+	mustGit(t, repo, "config", "user.email", "test@example.com")
+	mustGit(t, repo, "config", "commit.gpgsign", "false")
+	return repo
+}
+
+func mustGit(t *testing.T, repo string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func mustWriteFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
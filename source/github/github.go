@@ -33,6 +33,7 @@ type Github struct {
 	client     *github.Client
 	options    *github.RepositoryContentGetOptions
 	migrations *source.Migrations
+	shas       map[string]string // blob SHAs by file name
 }
 
 type Config struct {
@@ -121,6 +122,7 @@ func (g *Github) readDirectory() error {
 		return ErrNoDir
 	}
 
+	g.shas = make(map[string]string)
 	for _, fi := range dirContents {
 		m, err := source.DefaultParse(*fi.Name)
 		if err != nil {
@@ -129,6 +131,7 @@ func (g *Github) readDirectory() error {
 		if !g.migrations.Append(m) {
 			return fmt.Errorf("unable to parse file %v", *fi.Name)
 		}
+		g.shas[*fi.Name] = fi.GetSHA()
 	}
 
 	return nil
@@ -174,6 +177,16 @@ func (g *Github) Next(version uint) (nextVersion uint, err error) {
 	}
 }
 
+// Revision implements source.Revisioner with the SHA of the blob.
+func (g *Github) Revision(version uint, direction source.Direction) (string, error) {
+	g.ensureFields()
+
+	if m, ok := g.migrations.Get(version, direction); ok {
+		return g.shas[m.Raw], nil
+	}
+	return "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: g.config.Path, Err: os.ErrNotExist}
+}
+
 func (g *Github) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
 	g.ensureFields()
 
@@ -37,6 +37,7 @@ type Gitlab struct {
 	listOptions *gitlab.ListTreeOptions
 	getOptions  *gitlab.GetFileOptions
 	migrations  *source.Migrations
+	blobIDs     map[string]string // by file path
 }
 
 type Config struct {
@@ -133,6 +134,7 @@ func (g *Gitlab) readDirectory() error {
 		g.listOptions.ListOptions.Page = response.NextPage
 	}
 
+	g.blobIDs = make(map[string]string)
 	for i := range nodes {
 		m, err := g.nodeToMigration(nodes[i])
 		if err != nil {
@@ -142,6 +144,7 @@ func (g *Gitlab) readDirectory() error {
 		if !g.migrations.Append(m) {
 			return fmt.Errorf("unable to parse file %v", nodes[i].Name)
 		}
+		g.blobIDs[m.Raw] = nodes[i].ID
 	}
 
 	return nil
@@ -164,6 +167,14 @@ func (g *Gitlab) nodeToMigration(node *gitlab.TreeNode) (*source.Migration, erro
 	return nil, source.ErrParse
 }
 
+// Revision implements source.Revisioner with the ID of the blob.
+func (g *Gitlab) Revision(version uint, direction source.Direction) (string, error) {
+	if m, ok := g.migrations.Get(version, direction); ok {
+		return g.blobIDs[m.Raw], nil
+	}
+	return "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: g.path, Err: os.ErrNotExist}
+}
+
 func (g *Gitlab) Close() error {
 	return nil
 }
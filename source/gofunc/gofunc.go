@@ -0,0 +1,171 @@
+// Package gofunc provides migrations implemented as Go functions.
+//
+// Migrations are registered per version, usually from init functions, and read through the gofunc:// source:
+//
+//	func init() {
+//		gofunc.Register(20240102150405, "backfill_totals", backfillTotals, nil)
+//	}
+//
+// Use the multi source to interleave them with file migrations, e.g.
+// multi://?src=file://migrations&src=gofunc://
+package gofunc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+func init() {
+	source.Register("gofunc", &GoFunc{})
+}
+
+// Func is the up or down part of a Go migration. It runs in a transaction managed by the database driver.
+type Func func(ctx context.Context, tx *sql.Tx) error
+
+// Migration is a migration implemented in Go. Up or Down may be nil.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      Func
+	Down    Func
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[uint]Migration)
+)
+
+// Register globally registers a Go migration, which is then read by the gofunc:// source.
+// It panics if the version is registered twice.
+func Register(version uint, name string, up, down Func) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if up == nil && down == nil {
+		panic(fmt.Sprintf("Register migration %d without functions", version))
+	}
+	if _, dup := registry[version]; dup {
+		panic(fmt.Sprintf("Register called twice for migration %d", version))
+	}
+	registry[version] = Migration{Version: version, Name: name, Up: up, Down: down}
+}
+
+// GoFunc is a source driver reading Go migrations.
+type GoFunc struct {
+	url        string
+	migrations *source.Migrations
+	funcs      map[uint]Migration
+}
+
+// Open returns a driver for all globally registered migrations.
+func (g *GoFunc) Open(url string) (source.Driver, error) {
+	registryMu.RLock()
+	migrations := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		migrations = append(migrations, m)
+	}
+	registryMu.RUnlock()
+
+	d, err := WithInstance(migrations...)
+	if err != nil {
+		return nil, err
+	}
+	d.(*GoFunc).url = url
+
+	return d, nil
+}
+
+// WithInstance returns a driver for the given migrations, independent of the global registry.
+func WithInstance(migrations ...Migration) (source.Driver, error) {
+	g := &GoFunc{
+		migrations: source.NewMigrations(),
+		funcs:      make(map[uint]Migration, len(migrations)),
+	}
+
+	for _, m := range migrations {
+		if _, dup := g.funcs[m.Version]; dup {
+			return nil, fmt.Errorf("duplicate migration %d", m.Version)
+		}
+		g.funcs[m.Version] = m
+
+		if m.Up != nil {
+			g.migrations.Append(&source.Migration{Version: m.Version, Identifier: m.Name, Direction: source.Up})
+		}
+		if m.Down != nil {
+			g.migrations.Append(&source.Migration{Version: m.Version, Identifier: m.Name, Direction: source.Down})
+		}
+	}
+
+	return g, nil
+}
+
+func (g *GoFunc) Close() error {
+	return nil
+}
+
+func (g *GoFunc) First() (version uint, err error) {
+	if v, ok := g.migrations.First(); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: "first", Path: g.url, Err: os.ErrNotExist}
+}
+
+func (g *GoFunc) Prev(version uint) (prevVersion uint, err error) {
+	if v, ok := g.migrations.Prev(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("prev for version %v", version), Path: g.url, Err: os.ErrNotExist}
+}
+
+func (g *GoFunc) Next(version uint) (nextVersion uint, err error) {
+	if v, ok := g.migrations.Next(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("next for version %v", version), Path: g.url, Err: os.ErrNotExist}
+}
+
+// ReadUp returns a Body carrying the up function of version.
+func (g *GoFunc) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	if m, ok := g.funcs[version]; ok && m.Up != nil {
+		return newBody(m.Name, m.Up), m.Name, nil
+	}
+	return nil, "", &os.PathError{Op: fmt.Sprintf("read up version %v", version), Path: g.url, Err: os.ErrNotExist}
+}
+
+// ReadDown returns a Body carrying the down function of version.
+func (g *GoFunc) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	if m, ok := g.funcs[version]; ok && m.Down != nil {
+		return newBody(m.Name, m.Down), m.Name, nil
+	}
+	return nil, "", &os.PathError{Op: fmt.Sprintf("read down version %v", version), Path: g.url, Err: os.ErrNotExist}
+}
+
+// Body is the migration body of a Go migration. Migrate recognises it through
+// MigrationFunc and calls the function instead of running the body.
+// Reading it yields a comment naming the migration.
+type Body struct {
+	io.Reader
+	fn Func
+}
+
+func newBody(name string, fn Func) *Body {
+	return &Body{
+		Reader: strings.NewReader("-- go migration " + name),
+		fn:     fn,
+	}
+}
+
+// MigrationFunc returns the function of the migration.
+func (b *Body) MigrationFunc() func(ctx context.Context, tx *sql.Tx) error {
+	return b.fn
+}
+
+func (b *Body) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package gofunc
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+func noop(context.Context, *sql.Tx) error { return nil }
+
+func Test(t *testing.T) {
+	d, err := WithInstance(
+		Migration{Version: 1, Name: "foobar", Up: noop, Down: noop},
+		Migration{Version: 3, Name: "foobar", Up: noop},
+		Migration{Version: 4, Name: "foobar", Up: noop, Down: noop},
+		Migration{Version: 5, Name: "foobar", Down: noop},
+		Migration{Version: 7, Name: "foobar", Up: noop, Down: noop},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, d)
+}
+
+func TestOpen(t *testing.T) {
+	called := false
+	Register(42, "backfill", func(context.Context, *sql.Tx) error {
+		called = true
+		return nil
+	}, nil)
+
+	d, err := (&GoFunc{}).Open("gofunc://")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, identifier, err := d.ReadUp(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identifier != "backfill" {
+		t.Errorf("expected identifier backfill, got %v", identifier)
+	}
+
+	body, ok := r.(*Body)
+	if !ok {
+		t.Fatalf("expected *Body, got %T", r)
+	}
+	if err := body.MigrationFunc()(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("expected the registered func to be called")
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "-- go migration backfill" {
+		t.Errorf("unexpected body %q", content)
+	}
+}
+
+func TestWithInstanceDuplicate(t *testing.T) {
+	if _, err := WithInstance(Migration{Version: 1, Up: noop}, Migration{Version: 1, Down: noop}); err == nil {
+		t.Fatal("expected err for duplicate version")
+	}
+}
@@ -1,17 +1,21 @@
 package googlecloudstorage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"cloud.google.com/go/storage"
-	"context"
 	"github.com/abramad-labs/histomigrate/source"
+	"github.com/hashicorp/go-multierror"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 func init() {
@@ -19,58 +23,105 @@ func init() {
 }
 
 type gcs struct {
+	client     *storage.Client // set if created by Open
 	bucket     *storage.BucketHandle
 	prefix     string
 	migrations *source.Migrations
+	etags      map[string]string // by file name
 }
 
+// Open reads the migrations stored under a prefix of a bucket, e.g.
+// gcs://migrations/production?x-endpoint=http://localhost:4443/storage/v1/&x-anonymous=true
 func (g *gcs) Open(folder string) (source.Driver, error) {
 	u, err := url.Parse(folder)
 	if err != nil {
 		return nil, err
 	}
-	client, err := storage.NewClient(context.Background())
+
+	var opts []option.ClientOption
+	if endpoint := u.Query().Get("x-endpoint"); endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	if s := u.Query().Get("x-anonymous"); s != "" {
+		anonymous, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x-anonymous: %w", err)
+		}
+		if anonymous {
+			opts = append(opts, option.WithoutAuthentication())
+		}
+	}
+
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := WithInstance(client.Bucket(u.Host), u.Path)
 	if err != nil {
+		if errClose := client.Close(); errClose != nil {
+			err = multierror.Append(err, errClose)
+		}
 		return nil, err
 	}
-	driver := gcs{
-		bucket:     client.Bucket(u.Host),
-		prefix:     strings.Trim(u.Path, "/") + "/",
+	driver.(*gcs).client = client
+	return driver, nil
+}
+
+// WithInstance reads the migrations stored under prefix in bucket.
+func WithInstance(bucket *storage.BucketHandle, prefix string) (source.Driver, error) {
+	driver := &gcs{
+		bucket:     bucket,
+		prefix:     strings.Trim(prefix, "/") + "/",
 		migrations: source.NewMigrations(),
+		etags:      make(map[string]string),
 	}
-	err = driver.loadMigrations()
-	if err != nil {
+	if driver.prefix == "/" {
+		driver.prefix = ""
+	}
+	if err := driver.loadMigrations(); err != nil {
 		return nil, err
 	}
-	return &driver, nil
+	return driver, nil
 }
 
+// loadMigrations lists all objects directly under the prefix.
+// The iterator fetches further pages of the listing as needed.
 func (g *gcs) loadMigrations() error {
 	iter := g.bucket.Objects(context.Background(), &storage.Query{
 		Prefix:    g.prefix,
 		Delimiter: "/",
 	})
-	object, err := iter.Next()
-	for ; err == nil; object, err = iter.Next() {
+	for {
+		object, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if object.Prefix != "" {
+			// a "directory" below the prefix
+			continue
+		}
+
 		_, fileName := path.Split(object.Name)
-		m, parseErr := source.DefaultParse(fileName)
-		if parseErr != nil {
+		m, err := source.DefaultParse(fileName)
+		if err != nil {
 			continue
 		}
 		if !g.migrations.Append(m) {
 			return fmt.Errorf("unable to parse file %v", object.Name)
 		}
+		g.etags[fileName] = object.Etag
 	}
-	if err != iterator.Done {
-		return err
-	}
-	return nil
 }
 
 func (g *gcs) Close() error {
+	if g.client != nil {
+		return g.client.Close()
+	}
 	return nil
 }
-
 func (g *gcs) First() (uint, error) {
 	v, ok := g.migrations.First()
 	if !ok {
@@ -109,6 +160,14 @@ func (g *gcs) ReadDown(version uint) (io.ReadCloser, string, error) {
 	return nil, "", os.ErrNotExist
 }
 
+// Revision implements source.Revisioner with the ETag of the object.
+func (g *gcs) Revision(version uint, direction source.Direction) (string, error) {
+	if m, ok := g.migrations.Get(version, direction); ok {
+		return g.etags[m.Raw], nil
+	}
+	return "", os.ErrNotExist
+}
+
 func (g *gcs) open(m *source.Migration) (io.ReadCloser, string, error) {
 	objectPath := path.Join(g.prefix, m.Raw)
 	reader, err := g.bucket.Object(objectPath).NewReader(context.Background())
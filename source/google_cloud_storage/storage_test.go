@@ -1,9 +1,12 @@
 package googlecloudstorage
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"testing"
 
-	"github.com/abramad-labs/histomigrate/source"
 	st "github.com/abramad-labs/histomigrate/source/testing"
 	"github.com/fsouza/fake-gcs-server/fakestorage"
 )
@@ -24,14 +27,75 @@ func Test(t *testing.T) {
 		{BucketName: "some-bucket", Name: "prod/migrations/0-random-stuff/whatever.txt"},
 	})
 	defer server.Stop()
-	driver := gcs{
-		bucket:     server.Client().Bucket("some-bucket"),
-		prefix:     "prod/migrations/",
-		migrations: source.NewMigrations(),
+	driver, err := WithInstance(server.Client().Bucket("some-bucket"), "prod/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, driver)
+}
+
+func TestOpenWithEndpoint(t *testing.T) {
+	var objects []fakestorage.Object
+	for v := 1; v <= 25; v++ {
+		objects = append(objects, fakestorage.Object{
+			BucketName: "some-bucket",
+			Name:       fmt.Sprintf("migrations/%d_foobar.up.sql", v),
+			Content:    []byte(fmt.Sprintf("%d up", v)),
+		})
+	}
+	// the client downloads objects from the host of the endpoint, which
+	// the fake server only serves if it is its public host
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
 	}
-	err := driver.loadMigrations()
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		InitialObjects: objects,
+		Scheme:         "http",
+		Host:           "127.0.0.1",
+		Port:           uint16(port),
+		PublicHost:     fmt.Sprintf("127.0.0.1:%d", port),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	st.Test(t, &driver)
+	defer server.Stop()
+
+	g := &gcs{}
+	driver, err := g.Open("gcs://some-bucket/migrations?x-anonymous=true&x-endpoint=" + url.QueryEscape(server.URL()+"/storage/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := driver.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	count := 0
+	version, err := driver.First()
+	for err == nil {
+		count++
+		version, err = driver.Next(version)
+	}
+	if count != 25 {
+		t.Fatalf("expected 25 migrations, got %d", count)
+	}
+
+	r, _, err := driver.ReadUp(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "25 up" {
+		t.Fatalf("expected 25 up, got %q", body)
+	}
 }
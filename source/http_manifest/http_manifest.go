@@ -0,0 +1,285 @@
+// Package httpmanifest reads migrations listed by a JSON manifest from an HTTP(S) server,
+// such as an internal artifact server.
+//
+// The manifest is fetched when the driver is opened; bodies are streamed when they are read
+// and verified against the SHA-256 digests of the manifest.
+package httpmanifest
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+func init() {
+	source.Register("http", &HTTPManifest{})
+	source.Register("https", &HTTPManifest{})
+}
+
+const (
+	DefaultRetries   = 3
+	DefaultRetryWait = 500 * time.Millisecond
+)
+
+var ErrUnexpectedStatus = errors.New("unexpected HTTP status")
+
+// Config configures the requests of the driver.
+type Config struct {
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is sent with every request to the host of the manifest, e.g. an Authorization header.
+	// Files served by other hosts are fetched without it.
+	Header http.Header
+	// Retries is the number of times a request is retried after a network error or a 429 or 5xx status.
+	Retries int
+	// RetryWait is the wait before the first retry, doubling for every further one.
+	RetryWait time.Duration
+}
+
+// HTTPManifest is a source driver reading the migrations listed by a manifest, see Manifest.
+type HTTPManifest struct {
+	config      *Config
+	host        string
+	migrations  *source.Migrations
+	files       map[fileKey]*File
+	repeatables []Repeatable
+}
+
+type fileKey struct {
+	version   uint
+	direction source.Direction
+}
+
+// Open fetches the manifest at url, e.g.
+// https://artifacts.example.com/db/manifest.json?x-header=Authorization:Bearer%20abc&x-retries=5
+// Credentials of the URL are sent as basic authentication. The x- parameters are removed before fetching.
+func (h *HTTPManifest) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Header:    make(http.Header),
+		Retries:   DefaultRetries,
+		RetryWait: DefaultRetryWait,
+	}
+	q := u.Query()
+	for _, header := range q["x-header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid x-header %q, expected Name:Value", header)
+		}
+		config.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if s := q.Get("x-retries"); s != "" {
+		if config.Retries, err = strconv.Atoi(s); err != nil || config.Retries < 0 {
+			return nil, fmt.Errorf("invalid x-retries %q", s)
+		}
+	}
+	if s := q.Get("x-retry-wait"); s != "" {
+		if config.RetryWait, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid x-retry-wait: %w", err)
+		}
+	}
+	for param := range q {
+		if strings.HasPrefix(param, "x-") {
+			q.Del(param)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		config.Header.Set("Authorization", "Basic "+credentials)
+		u.User = nil
+	}
+
+	return WithInstance(u.String(), config)
+}
+
+// WithInstance fetches the manifest at url.
+func WithInstance(url string, config *Config) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	h := &HTTPManifest{
+		config:     config,
+		host:       u.Host,
+		migrations: source.NewMigrations(),
+		files:      make(map[fileKey]*File),
+	}
+
+	resp, err := h.get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if errClose := resp.Body.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	m, err := ParseManifest(data, u)
+	if err != nil {
+		return nil, err
+	}
+	for _, migr := range m.Migrations {
+		for _, direction := range []source.Direction{source.Up, source.Down} {
+			f := migr.Up
+			if direction == source.Down {
+				f = migr.Down
+			}
+			if f == nil {
+				continue
+			}
+			h.migrations.Append(&source.Migration{
+				Version:    migr.Version,
+				Identifier: migr.Identifier,
+				Direction:  direction,
+				Raw:        f.URL,
+			})
+			h.files[fileKey{version: migr.Version, direction: direction}] = f
+		}
+	}
+	h.repeatables = m.Repeatables
+
+	return h, nil
+}
+
+func (h *HTTPManifest) Close() error {
+	return nil
+}
+
+func (h *HTTPManifest) First() (version uint, err error) {
+	if v, ok := h.migrations.First(); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: "first", Path: h.host, Err: os.ErrNotExist}
+}
+
+func (h *HTTPManifest) Prev(version uint) (prevVersion uint, err error) {
+	if v, ok := h.migrations.Prev(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("prev for version %v", version), Path: h.host, Err: os.ErrNotExist}
+}
+
+func (h *HTTPManifest) Next(version uint) (nextVersion uint, err error) {
+	if v, ok := h.migrations.Next(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("next for version %v", version), Path: h.host, Err: os.ErrNotExist}
+}
+
+func (h *HTTPManifest) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	return h.read(version, source.Up)
+}
+
+func (h *HTTPManifest) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	return h.read(version, source.Down)
+}
+
+// Revision implements source.Revisioner with the digest listed in the manifest.
+func (h *HTTPManifest) Revision(version uint, direction source.Direction) (string, error) {
+	if f, ok := h.files[fileKey{version: version, direction: direction}]; ok {
+		return f.SHA256, nil
+	}
+	return "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: h.host, Err: os.ErrNotExist}
+}
+
+// Repeatables implements source.RepeatableDriver.
+func (h *HTTPManifest) Repeatables() (names []string, err error) {
+	for _, r := range h.repeatables {
+		names = append(names, r.Name)
+	}
+	return names, nil
+}
+
+// ReadRepeatable implements source.RepeatableDriver.
+func (h *HTTPManifest) ReadRepeatable(name string) (r io.ReadCloser, identifier string, err error) {
+	for i := range h.repeatables {
+		if h.repeatables[i].Name == name {
+			body, err := h.open(&h.repeatables[i].File)
+			if err != nil {
+				return nil, "", err
+			}
+			return body, name, nil
+		}
+	}
+	return nil, "", &os.PathError{Op: "read repeatable " + name, Path: h.host, Err: os.ErrNotExist}
+}
+
+func (h *HTTPManifest) read(version uint, direction source.Direction) (io.ReadCloser, string, error) {
+	m, ok := h.migrations.Get(version, direction)
+	if !ok {
+		return nil, "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: h.host, Err: os.ErrNotExist}
+	}
+	body, err := h.open(h.files[fileKey{version: version, direction: direction}])
+	if err != nil {
+		return nil, "", err
+	}
+	return body, m.Identifier, nil
+}
+
+// open requests the body of f. Reading it fails with ErrDigestMismatch at its end if it doesn't match.
+func (h *HTTPManifest) open(f *File) (io.ReadCloser, error) {
+	resp, err := h.get(f.URL)
+	if err != nil {
+		return nil, err
+	}
+	return newVerifyingReader(resp.Body, f), nil
+}
+
+// get requests url, retrying after network errors and 429 or 5xx statuses.
+// The response has status 200; its body must be closed.
+func (h *HTTPManifest) get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Host == h.host {
+		for name, values := range h.config.Header {
+			req.Header[name] = values
+		}
+	}
+
+	wait := h.config.RetryWait
+	for attempt := 0; ; attempt++ {
+		resp, err := h.config.Client.Do(req)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			err = fmt.Errorf("%w %s: GET %s", ErrUnexpectedStatus, resp.Status, url)
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+				return nil, err
+			}
+		}
+		if attempt >= h.config.Retries {
+			return nil, err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
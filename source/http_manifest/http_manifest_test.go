@@ -0,0 +1,194 @@
+package httpmanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// newServer serves files and a manifest listing them at /db/manifest.json.
+func newServer(t *testing.T, files map[string]string, handler func(w http.ResponseWriter, r *http.Request) bool) *httptest.Server {
+	t.Helper()
+
+	m := Manifest{}
+	for _, v := range []struct {
+		version  uint
+		up, down bool
+	}{{1, true, true}, {3, true, false}, {4, true, true}, {5, false, true}, {7, true, true}} {
+		migr := Migration{Version: v.version, Identifier: "foobar"}
+		for _, dir := range []string{"up", "down"} {
+			if (dir == "up" && !v.up) || (dir == "down" && !v.down) {
+				continue
+			}
+			name := fmt.Sprintf("migrations/%d_foobar.%s.sql", v.version, dir)
+			body := fmt.Sprintf("%d %s", v.version, dir)
+			if _, ok := files[name]; !ok {
+				files[name] = body
+			}
+			f := &File{URL: name, SHA256: digest(body)}
+			if dir == "up" {
+				migr.Up = f
+			} else {
+				migr.Down = f
+			}
+		}
+		m.Migrations = append(m.Migrations, migr)
+	}
+	files["migrations/R__views.sql"] = "views"
+	m.Repeatables = []Repeatable{{Name: "R__views", File: File{URL: "migrations/R__views.sql", SHA256: digest("views")}}}
+
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler != nil && !handler(w, r) {
+			return
+		}
+		if r.URL.Path == "/db/manifest.json" {
+			_, _ = w.Write(manifest)
+			return
+		}
+		body, ok := files[strings.TrimPrefix(r.URL.Path, "/db/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test(t *testing.T) {
+	server := newServer(t, map[string]string{}, nil)
+
+	d, err := (&HTTPManifest{}).Open(server.URL + "/db/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, d)
+
+	r, identifier, err := d.(*HTTPManifest).ReadRepeatable("R__views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if body, err := io.ReadAll(r); err != nil || string(body) != "views" || identifier != "R__views" {
+		t.Fatalf("unexpected repeatable %s %q: %v", identifier, body, err)
+	}
+}
+
+func TestDigestMismatch(t *testing.T) {
+	server := newServer(t, map[string]string{"migrations/3_foobar.up.sql": "3 up, tampered"}, nil)
+
+	d, err := (&HTTPManifest{}).Open(server.URL + "/db/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := d.ReadUp(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("expected ErrDigestMismatch, got %v", err)
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	server := newServer(t, map[string]string{}, func(w http.ResponseWriter, r *http.Request) bool {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "ci" || password != "secret" || r.Header.Get("X-Api-Key") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	})
+
+	if _, err := (&HTTPManifest{}).Open(server.URL + "/db/manifest.json"); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("expected ErrUnexpectedStatus, got %v", err)
+	}
+
+	url := strings.Replace(server.URL, "://", "://ci:secret@", 1) + "/db/manifest.json?x-header=X-Api-Key:abc"
+	d, err := (&HTTPManifest{}).Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := d.ReadUp(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var requests int32
+	server := newServer(t, map[string]string{}, func(w http.ResponseWriter, r *http.Request) bool {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	})
+
+	if _, err := (&HTTPManifest{}).Open(server.URL + "/db/manifest.json?x-retries=1&x-retry-wait=1ms"); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("expected ErrUnexpectedStatus, got %v", err)
+	}
+	if _, err := (&HTTPManifest{}).Open(server.URL + "/db/manifest.json?x-retries=1&x-retry-wait=1ms"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	server := newServer(t, map[string]string{}, nil)
+	base, err := http.NewRequest(http.MethodGet, server.URL+"/db/manifest.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		manifest string
+	}{
+		{"invalid json", `{`},
+		{"duplicate version", `{"migrations": [{"version": 1, "up": {"url": "a", "sha256": "` + digest("") + `"}}, {"version": 1, "down": {"url": "b", "sha256": "` + digest("") + `"}}]}`},
+		{"no files", `{"migrations": [{"version": 1}]}`},
+		{"invalid digest", `{"migrations": [{"version": 1, "up": {"url": "a", "sha256": "abc"}}]}`},
+		{"missing url", `{"migrations": [{"version": 1, "up": {"sha256": "` + digest("") + `"}}]}`},
+		{"unsupported url", `{"migrations": [{"version": 1, "up": {"url": "file:///etc/passwd", "sha256": "` + digest("") + `"}}]}`},
+		{"duplicate repeatable", `{"repeatables": [{"name": "a", "url": "a", "sha256": "` + digest("") + `"}, {"name": "a", "url": "b", "sha256": "` + digest("") + `"}]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseManifest([]byte(tc.manifest), base.URL); !errors.Is(err, ErrInvalidManifest) {
+				t.Fatalf("expected ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+
+	m, err := ParseManifest([]byte(`{"migrations": [{"version": 1, "up": {"url": "../up.sql", "sha256": "`+strings.ToUpper(digest(""))+`"}}]}`), base.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Migrations[0].Up.URL != server.URL+"/up.sql" || m.Migrations[0].Up.SHA256 != digest("") {
+		t.Fatalf("unexpected file %+v", m.Migrations[0].Up)
+	}
+}
@@ -0,0 +1,148 @@
+package httpmanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	nurl "net/url"
+	"strings"
+)
+
+var (
+	ErrInvalidManifest = errors.New("invalid manifest")
+	ErrDigestMismatch  = errors.New("migration doesn't match its digest")
+)
+
+// Manifest lists the migrations served by an HTTP server, e.g.
+//
+//	{
+//	  "migrations": [
+//	    {
+//	      "version": 1,
+//	      "identifier": "create_users",
+//	      "up": {"url": "1_create_users.up.sql", "sha256": "9f86d0..."},
+//	      "down": {"url": "https://cdn.example.com/1_create_users.down.sql", "sha256": "60303a..."}
+//	    }
+//	  ],
+//	  "repeatables": [
+//	    {"name": "R__views", "url": "R__views.sql", "sha256": "fd61a0..."}
+//	  ]
+//	}
+//
+// Relative URLs are resolved against the URL of the manifest.
+type Manifest struct {
+	Migrations  []Migration  `json:"migrations"`
+	Repeatables []Repeatable `json:"repeatables,omitempty"`
+}
+
+// Migration is a version of a manifest. Up or Down may be nil.
+type Migration struct {
+	Version    uint   `json:"version"`
+	Identifier string `json:"identifier"`
+	Up         *File  `json:"up,omitempty"`
+	Down       *File  `json:"down,omitempty"`
+}
+
+// Repeatable is a repeatable migration of a manifest.
+type Repeatable struct {
+	Name string `json:"name"`
+	File
+}
+
+// File locates the body of a migration.
+type File struct {
+	URL string `json:"url"`
+	// SHA256 is the hex encoded SHA-256 of the body.
+	SHA256 string `json:"sha256"`
+}
+
+// ParseManifest parses and validates a JSON manifest, resolving the URLs of its files against base.
+func ParseManifest(data []byte, base *nurl.URL) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	versions := make(map[uint]bool, len(m.Migrations))
+	for _, migr := range m.Migrations {
+		if versions[migr.Version] {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrInvalidManifest, migr.Version)
+		}
+		versions[migr.Version] = true
+		if migr.Up == nil && migr.Down == nil {
+			return nil, fmt.Errorf("%w: version %d has neither up nor down migration", ErrInvalidManifest, migr.Version)
+		}
+		for _, f := range []*File{migr.Up, migr.Down} {
+			if f == nil {
+				continue
+			}
+			if err := f.resolve(base); err != nil {
+				return nil, fmt.Errorf("%w: version %d: %v", ErrInvalidManifest, migr.Version, err)
+			}
+		}
+	}
+
+	names := make(map[string]bool, len(m.Repeatables))
+	for i := range m.Repeatables {
+		r := &m.Repeatables[i]
+		if r.Name == "" || names[r.Name] {
+			return nil, fmt.Errorf("%w: missing or duplicate repeatable name %q", ErrInvalidManifest, r.Name)
+		}
+		names[r.Name] = true
+		if err := r.resolve(base); err != nil {
+			return nil, fmt.Errorf("%w: repeatable %s: %v", ErrInvalidManifest, r.Name, err)
+		}
+	}
+
+	return m, nil
+}
+
+// resolve checks the digest of f and makes its URL absolute.
+func (f *File) resolve(base *nurl.URL) error {
+	f.SHA256 = strings.ToLower(f.SHA256)
+	if _, err := hex.DecodeString(f.SHA256); err != nil || len(f.SHA256) != 2*sha256.Size {
+		return fmt.Errorf("invalid sha256 %q", f.SHA256)
+	}
+	if f.URL == "" {
+		return errors.New("missing url")
+	}
+	u, err := base.Parse(f.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url %s", f.URL)
+	}
+	f.URL = u.String()
+	return nil
+}
+
+// verifyingReader hashes the body while it is read and fails with ErrDigestMismatch
+// instead of returning io.EOF if it doesn't match the expected digest.
+type verifyingReader struct {
+	body   io.ReadCloser
+	hash   hash.Hash
+	digest string
+	url    string
+}
+
+func newVerifyingReader(body io.ReadCloser, f *File) *verifyingReader {
+	return &verifyingReader{body: body, hash: sha256.New(), digest: f.SHA256, url: f.URL}
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(r.hash.Sum(nil)) != r.digest {
+		return n, fmt.Errorf("%s: %w", r.url, ErrDigestMismatch)
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.body.Close()
+}
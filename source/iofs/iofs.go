@@ -3,11 +3,14 @@
 package iofs
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"path"
+	"sort"
 	"strconv"
 
 	"github.com/abramad-labs/histomigrate/source"
@@ -39,9 +42,11 @@ func (d *driver) Open(url string) (source.Driver, error) {
 //
 // To prepare PartialDriver for use Init() function.
 type PartialDriver struct {
-	migrations *source.Migrations
-	fsys       fs.FS
-	path       string
+	migrations  *source.Migrations
+	repeatables map[string]*source.Repeatable
+	singleFiles map[string]bool // raw names of single-file migrations
+	fsys        fs.FS
+	path        string
 }
 
 // Init prepares not initialized IoFS instance to read migrations from a
@@ -53,29 +58,114 @@ func (d *PartialDriver) Init(fsys fs.FS, path string) error {
 	}
 
 	ms := source.NewMigrations()
+	rs := make(map[string]*source.Repeatable)
+	sf := make(map[string]bool)
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
+		if r, err := source.DefaultRepeatableParse(e.Name()); err == nil {
+			if _, dup := rs[r.Name]; dup {
+				file, err := e.Info()
+				if err != nil {
+					return err
+				}
+				return source.ErrDuplicateRepeatable{
+					Repeatable: *r,
+					FileInfo:   file,
+				}
+			}
+			rs[r.Name] = r
+			continue
+		}
 		m, err := source.DefaultParse(e.Name())
 		if err != nil {
+			migrs, err := d.parseSingleFile(fsys, path, e.Name())
+			if err != nil {
+				return err
+			}
+			if len(migrs) == 0 {
+				continue
+			}
+			sf[e.Name()] = true
+			if err := appendMigrations(ms, e, migrs...); err != nil {
+				return err
+			}
 			continue
 		}
-		file, err := e.Info()
-		if err != nil {
+		if err := appendMigrations(ms, e, m); err != nil {
 			return err
 		}
-		if !ms.Append(m) {
-			return source.ErrDuplicateMigration{
-				Migration: *m,
-				FileInfo:  file,
-			}
-		}
 	}
 
 	d.fsys = fsys
 	d.path = path
 	d.migrations = ms
+	d.repeatables = rs
+	d.singleFiles = sf
+	return nil
+}
+
+// parseSingleFile returns the migrations held by a single-file migration, one per direction.
+// Only the file up to its first section is read, the sections themselves are split by openMigration.
+// Files that match source.SingleFileRegex but have no sections are not migrations.
+func (d *PartialDriver) parseSingleFile(fsys fs.FS, dir string, name string) ([]*source.Migration, error) {
+	m, err := source.DefaultSingleFileParse(name)
+	if err != nil {
+		return nil, nil
+	}
+
+	ok, err := hasSection(fsys, path.Join(dir, name))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	migrs := make([]*source.Migration, 0, 2)
+	for _, direction := range []source.Direction{source.Up, source.Down} {
+		mx := *m
+		mx.Direction = direction
+		migrs = append(migrs, &mx)
+	}
+	return migrs, nil
+}
+
+// hasSection reports whether the file holds a +migrate section, reading it up to the first one.
+func hasSection(fsys fs.FS, name string) (bool, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if source.SectionRegex.Match(bytes.TrimRight(line, "\r\n")) {
+			return true, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func appendMigrations(ms *source.Migrations, e fs.DirEntry, migrs ...*source.Migration) error {
+	for _, m := range migrs {
+		if ms.Append(m) {
+			continue
+		}
+		file, err := e.Info()
+		if err != nil {
+			return err
+		}
+		return source.ErrDuplicateMigration{
+			Migration: *m,
+			FileInfo:  file,
+		}
+	}
 	return nil
 }
 
@@ -128,7 +218,7 @@ func (d *PartialDriver) Next(version uint) (nextVersion uint, err error) {
 // ReadUp is part of source.Driver interface implementation.
 func (d *PartialDriver) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
 	if m, ok := d.migrations.Up(version); ok {
-		body, err := d.open(path.Join(d.path, m.Raw))
+		body, err := d.openMigration(m)
 		if err != nil {
 			return nil, "", err
 		}
@@ -144,7 +234,7 @@ func (d *PartialDriver) ReadUp(version uint) (r io.ReadCloser, identifier string
 // ReadDown is part of source.Driver interface implementation.
 func (d *PartialDriver) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
 	if m, ok := d.migrations.Down(version); ok {
-		body, err := d.open(path.Join(d.path, m.Raw))
+		body, err := d.openMigration(m)
 		if err != nil {
 			return nil, "", err
 		}
@@ -157,6 +247,63 @@ func (d *PartialDriver) ReadDown(version uint) (r io.ReadCloser, identifier stri
 	}
 }
 
+// Repeatables is part of source.RepeatableDriver interface implementation.
+// Repeatable migrations run in the lexical order of their names.
+func (d *PartialDriver) Repeatables() (names []string, err error) {
+	names = make([]string, 0, len(d.repeatables))
+	for name := range d.repeatables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadRepeatable is part of source.RepeatableDriver interface implementation.
+func (d *PartialDriver) ReadRepeatable(name string) (r io.ReadCloser, identifier string, err error) {
+	if m, ok := d.repeatables[name]; ok {
+		body, err := d.open(path.Join(d.path, m.Raw))
+		if err != nil {
+			return nil, "", err
+		}
+		return body, m.Name, nil
+	}
+	return nil, "", &fs.PathError{
+		Op:   "read repeatable " + name,
+		Path: d.path,
+		Err:  fs.ErrNotExist,
+	}
+}
+
+// openMigration opens the body of m. Single-file migrations are read
+// up front, since only the section of m's direction is returned. A missing
+// section is reported like a missing file.
+func (d *PartialDriver) openMigration(m *source.Migration) (io.ReadCloser, error) {
+	name := path.Join(d.path, m.Raw)
+	if !d.singleFiles[m.Raw] {
+		return d.open(name)
+	}
+
+	f, err := d.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := source.ParseSections(content)
+	if err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: name, Err: err}
+	}
+	section, ok := sections[m.Direction]
+	if !ok {
+		return nil, &fs.PathError{Op: "read " + string(m.Direction) + " section", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(section)), nil
+}
+
 func (d *PartialDriver) open(path string) (fs.File, error) {
 	f, err := d.fsys.Open(path)
 	if err == nil {
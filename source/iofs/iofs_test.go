@@ -3,8 +3,14 @@
 package iofs_test
 
 import (
+	"errors"
+	"io"
+	"os"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/abramad-labs/histomigrate/source"
 	"github.com/abramad-labs/histomigrate/source/iofs"
 	st "github.com/abramad-labs/histomigrate/source/testing"
 )
@@ -18,3 +24,107 @@ func Test(t *testing.T) {
 
 	st.Test(t, d)
 }
+
+func TestRepeatables(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_foobar.up.sql": {Data: []byte("1 up")},
+		"R__views.sql":    {Data: []byte("views")},
+		"R__funcs.sql":    {Data: []byte("funcs")},
+	}
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := d.(source.RepeatableDriver).Repeatables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "funcs" || names[1] != "views" {
+		t.Fatalf("expected [funcs views], got %v", names)
+	}
+
+	r, identifier, err := d.(source.RepeatableDriver).ReadRepeatable("views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if identifier != "views" {
+		t.Errorf("expected identifier views, got %v", identifier)
+	}
+
+	if _, _, err := d.(source.RepeatableDriver).ReadRepeatable("nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	fsys["R__views.psql"] = &fstest.MapFile{Data: []byte("views")}
+	if _, err := iofs.New(fsys, "."); !errors.As(err, new(source.ErrDuplicateRepeatable)) {
+		t.Errorf("expected ErrDuplicateRepeatable, got %v", err)
+	}
+}
+
+func TestSingleFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_users.up.sql":   {Data: []byte("1 up")},
+		"1_users.down.sql": {Data: []byte("1 down")},
+		"2_orders.sql":     {Data: []byte("-- +migrate Up\n2 up\n-- +migrate Down\n2 down\n")},
+		"3_backfill.sql":   {Data: []byte("-- +migrate Up\n3 up\n")},
+		"4_notes.sql":      {Data: []byte("no sections, not a migration\n")},
+	}
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectBody := func(r io.ReadCloser, identifier string, err error, expect string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != expect {
+			t.Errorf("expected %q, got %q", expect, body)
+		}
+		if identifier == "" {
+			t.Error("expected identifier")
+		}
+	}
+
+	r, identifier, err := d.ReadUp(2)
+	expectBody(r, identifier, err, "2 up\n")
+	r, identifier, err = d.ReadDown(2)
+	expectBody(r, identifier, err, "2 down\n")
+	r, identifier, err = d.ReadUp(3)
+	expectBody(r, identifier, err, "3 up\n")
+
+	if _, _, err := d.ReadDown(3); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if next, err := d.Next(3); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no version after 3, got %v, %v", next, err)
+	}
+
+	fsys["2_orders.up.sql"] = &fstest.MapFile{Data: []byte("2 up")}
+	if _, err := iofs.New(fsys, "."); !errors.As(err, new(source.ErrDuplicateMigration)) {
+		t.Errorf("expected ErrDuplicateMigration, got %v", err)
+	}
+}
+
+func TestSingleFileSectionsReadLazily(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_users.sql": {Data: []byte("-- +migrate Up\n1 up\n-- +migrate Up\n1 up again\n")},
+	}
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sections are only split once the migration is read
+	if _, _, err := d.ReadUp(1); err == nil || !strings.Contains(err.Error(), "duplicate +migrate up section") {
+		t.Errorf("expected the duplicate section to be reported, got %v", err)
+	}
+}
@@ -31,6 +31,17 @@ type Migration struct {
 	Raw string
 }
 
+// Repeatable is a helper struct for source drivers providing repeatable migrations.
+// Repeatable migrations have no version; they run after all versioned migrations
+// and run again whenever their content changes.
+type Repeatable struct {
+	// Name identifies the repeatable migration, it must be unique in the source.
+	Name string
+
+	// Raw holds the raw location path to this migration in source.
+	Raw string
+}
+
 // Migrations wraps Migration and has an internal index
 // to keep track of Migration order.
 type Migrations struct {
@@ -116,6 +127,12 @@ func (i *Migrations) Down(version uint) (m *Migration, ok bool) {
 	return nil, false
 }
 
+// Get returns the migration of version in the given direction.
+func (i *Migrations) Get(version uint, direction Direction) (m *Migration, ok bool) {
+	m, ok = i.migrations[version][direction]
+	return m, ok
+}
+
 func (i *Migrations) findPos(version uint) int {
 	if len(i.index) > 0 {
 		ix := i.index.Search(version)
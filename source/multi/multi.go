@@ -0,0 +1,276 @@
+package multi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	"path"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/hashicorp/go-multierror"
+)
+
+func init() {
+	source.Register("multi", &Multi{})
+}
+
+var (
+	ErrNoSources           = errors.New("no sources")
+	ErrVersionCollision    = errors.New("version collision")
+	ErrRepeatableCollision = errors.New("repeatable collision")
+)
+
+// Source is a named child of Multi.
+// The name prefixes the identifiers of all migrations read from the child.
+type Source struct {
+	Name   string
+	Driver source.Driver
+}
+
+// Multi merges several source drivers into one ordered index.
+// Every version and every repeatable migration must be provided by exactly one child.
+type Multi struct {
+	url         string
+	sources     []Source
+	owners      map[uint]*Source
+	migrations  *source.Migrations
+	repeatables map[string]*Source
+	names       []string
+}
+
+// Open opens every src query parameter as a child source, e.g.
+// multi://?src=file://core&src=file://billing
+// Children are named after the last element of their location, "core" and "billing" in the example.
+func (m *Multi) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	srcs := u.Query()["src"]
+	if len(srcs) == 0 {
+		return nil, ErrNoSources
+	}
+
+	sources := make([]Source, 0, len(srcs))
+	for _, src := range srcs {
+		d, err := source.Open(src)
+		if err != nil {
+			return nil, closeOnErr(fmt.Errorf("failed to open %s: %w", src, err), sources)
+		}
+		sources = append(sources, Source{Name: sourceName(src), Driver: d})
+	}
+
+	// fall back to the full location for children sharing a name
+	seen := make(map[string]int, len(sources))
+	for _, s := range sources {
+		seen[s.Name]++
+	}
+	for i := range sources {
+		if seen[sources[i].Name] > 1 {
+			sources[i].Name = srcs[i]
+		}
+	}
+
+	mx, err := WithInstance(sources...)
+	if err != nil {
+		return nil, closeOnErr(err, sources)
+	}
+	mx.(*Multi).url = url
+
+	return mx, nil
+}
+
+// WithInstance returns a Multi merging the given sources.
+// It fails with ErrVersionCollision if a version is provided by more than one of them,
+// and with ErrRepeatableCollision if a repeatable migration is.
+func WithInstance(sources ...Source) (source.Driver, error) {
+	if len(sources) == 0 {
+		return nil, ErrNoSources
+	}
+
+	m := &Multi{
+		sources:     sources,
+		owners:      make(map[uint]*Source),
+		migrations:  source.NewMigrations(),
+		repeatables: make(map[string]*Source),
+	}
+
+	for i := range sources {
+		if err := m.index(&m.sources[i]); err != nil {
+			return nil, err
+		}
+		if err := m.indexRepeatables(&m.sources[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// index adds all versions of s to the merged index.
+func (m *Multi) index(s *Source) error {
+	version, err := s.Driver.First()
+	for err == nil {
+		if owner, dup := m.owners[version]; dup {
+			return fmt.Errorf("%w: version %d is provided by %s and %s", ErrVersionCollision, version, owner.Name, s.Name)
+		}
+		m.owners[version] = s
+		m.migrations.Append(&source.Migration{Version: version, Identifier: s.Name, Direction: source.Up})
+
+		version, err = s.Driver.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read versions of %s: %w", s.Name, err)
+	}
+
+	return nil
+}
+
+// indexRepeatables adds the repeatable migrations of s, if any, after those of the children before it.
+func (m *Multi) indexRepeatables(s *Source) error {
+	rd, ok := s.Driver.(source.RepeatableDriver)
+	if !ok {
+		return nil
+	}
+	names, err := rd.Repeatables()
+	if err != nil {
+		return fmt.Errorf("failed to read repeatables of %s: %w", s.Name, err)
+	}
+	for _, name := range names {
+		if owner, dup := m.repeatables[name]; dup {
+			return fmt.Errorf("%w: %s is provided by %s and %s", ErrRepeatableCollision, name, owner.Name, s.Name)
+		}
+		m.repeatables[name] = s
+		m.names = append(m.names, name)
+	}
+	return nil
+}
+
+func (m *Multi) Close() error {
+	return closeAll(m.sources)
+}
+
+func (m *Multi) First() (version uint, err error) {
+	if v, ok := m.migrations.First(); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: "first", Path: m.url, Err: os.ErrNotExist}
+}
+
+func (m *Multi) Prev(version uint) (prevVersion uint, err error) {
+	if v, ok := m.migrations.Prev(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("prev for version %v", version), Path: m.url, Err: os.ErrNotExist}
+}
+
+func (m *Multi) Next(version uint) (nextVersion uint, err error) {
+	if v, ok := m.migrations.Next(version); ok {
+		return v, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("next for version %v", version), Path: m.url, Err: os.ErrNotExist}
+}
+
+// ReadUp reads the up migration from the child providing version.
+// The identifier is prefixed with the name of the child, e.g. "billing/add_invoices".
+func (m *Multi) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	owner, ok := m.owners[version]
+	if !ok {
+		return nil, "", &os.PathError{Op: fmt.Sprintf("read up version %v", version), Path: m.url, Err: os.ErrNotExist}
+	}
+
+	r, identifier, err = owner.Driver.ReadUp(version)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, owner.Name + "/" + identifier, nil
+}
+
+// ReadDown reads the down migration from the child providing version.
+// The identifier is prefixed with the name of the child, e.g. "billing/add_invoices".
+func (m *Multi) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	owner, ok := m.owners[version]
+	if !ok {
+		return nil, "", &os.PathError{Op: fmt.Sprintf("read down version %v", version), Path: m.url, Err: os.ErrNotExist}
+	}
+
+	r, identifier, err = owner.Driver.ReadDown(version)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, owner.Name + "/" + identifier, nil
+}
+
+// Repeatables is part of source.RepeatableDriver interface implementation.
+// The repeatable migrations of every child run in the order of the children.
+func (m *Multi) Repeatables() (names []string, err error) {
+	return append([]string(nil), m.names...), nil
+}
+
+// ReadRepeatable reads the repeatable migration name from the child providing it.
+// The identifier is prefixed with the name of the child, e.g. "billing/views".
+func (m *Multi) ReadRepeatable(name string) (r io.ReadCloser, identifier string, err error) {
+	owner, ok := m.repeatables[name]
+	if !ok {
+		return nil, "", &os.PathError{Op: "read repeatable " + name, Path: m.url, Err: os.ErrNotExist}
+	}
+
+	r, identifier, err = owner.Driver.(source.RepeatableDriver).ReadRepeatable(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, owner.Name + "/" + identifier, nil
+}
+
+// Revision is part of source.Revisioner interface implementation.
+// It returns the revision known to the child providing version, "" if the child doesn't know revisions.
+func (m *Multi) Revision(version uint, direction source.Direction) (revision string, err error) {
+	owner, ok := m.owners[version]
+	if !ok {
+		return "", &os.PathError{Op: fmt.Sprintf("revision for version %v", version), Path: m.url, Err: os.ErrNotExist}
+	}
+
+	if rv, ok := owner.Driver.(source.Revisioner); ok {
+		return rv.Revision(version, direction)
+	}
+	return "", nil
+}
+
+// sourceName derives the name of a child from the last element of its location.
+func sourceName(src string) string {
+	u, err := nurl.Parse(src)
+	if err != nil {
+		return src
+	}
+
+	p := u.Opaque
+	if len(p) == 0 {
+		p = u.Host + u.Path
+	}
+	if name := path.Base(p); name != "." && name != "/" {
+		return name
+	}
+	return src
+}
+
+func closeAll(sources []Source) error {
+	var errs error
+	for _, s := range sources {
+		if err := s.Driver.Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// closeOnErr closes sources after a failed Open and returns err along with any error closing them.
+func closeOnErr(err error, sources []Source) error {
+	if errClose := closeAll(sources); errClose != nil {
+		return multierror.Append(err, errClose)
+	}
+	return err
+}
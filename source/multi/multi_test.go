@@ -0,0 +1,209 @@
+package multi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	"github.com/abramad-labs/histomigrate/source/stub"
+	st "github.com/abramad-labs/histomigrate/source/testing"
+
+	_ "github.com/abramad-labs/histomigrate/source/file"
+)
+
+func newStub(t *testing.T, migrations ...*source.Migration) source.Driver {
+	d, err := (&stub.Stub{}).Open("stub://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		d.(*stub.Stub).Migrations.Append(m)
+	}
+	return d
+}
+
+func Test(t *testing.T) {
+	core := newStub(t,
+		&source.Migration{Version: 1, Direction: source.Up, Identifier: "CREATE 1"},
+		&source.Migration{Version: 1, Direction: source.Down, Identifier: "DROP 1"},
+		&source.Migration{Version: 4, Direction: source.Up, Identifier: "CREATE 4"},
+		&source.Migration{Version: 4, Direction: source.Down, Identifier: "DROP 4"},
+		&source.Migration{Version: 7, Direction: source.Up, Identifier: "CREATE 7"},
+		&source.Migration{Version: 7, Direction: source.Down, Identifier: "DROP 7"},
+	)
+	billing := newStub(t,
+		&source.Migration{Version: 3, Direction: source.Up, Identifier: "CREATE 3"},
+		&source.Migration{Version: 5, Direction: source.Down, Identifier: "DROP 5"},
+	)
+
+	d, err := WithInstance(Source{Name: "core", Driver: core}, Source{Name: "billing", Driver: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Test(t, d)
+}
+
+func TestVersionCollision(t *testing.T) {
+	core := newStub(t, &source.Migration{Version: 1, Direction: source.Up})
+	billing := newStub(t, &source.Migration{Version: 1, Direction: source.Down})
+
+	_, err := WithInstance(Source{Name: "core", Driver: core}, Source{Name: "billing", Driver: billing})
+	if !errors.Is(err, ErrVersionCollision) {
+		t.Fatalf("expected ErrVersionCollision, got %v", err)
+	}
+}
+
+func TestRepeatables(t *testing.T) {
+	core, err := iofs.New(fstest.MapFS{
+		"1_init.up.sql": {Data: []byte("1 up")},
+		"R__views.sql":  {Data: []byte("views")},
+		"R__funcs.sql":  {Data: []byte("funcs")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing, err := iofs.New(fstest.MapFS{
+		"2_invoices.up.sql": {Data: []byte("2 up")},
+		"R__reports.sql":    {Data: []byte("reports")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := newStub(t, &source.Migration{Version: 3, Direction: source.Up})
+
+	d, err := WithInstance(Source{Name: "core", Driver: core}, Source{Name: "billing", Driver: billing}, Source{Name: "plain", Driver: plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd := d.(source.RepeatableDriver)
+
+	names, err := rd.Repeatables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"funcs", "views", "reports"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	r, identifier, err := rd.ReadRepeatable("reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identifier != "billing/reports" {
+		t.Errorf("expected identifier billing/reports, got %v", identifier)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "reports" {
+		t.Errorf("expected body 'reports', got %q", body)
+	}
+	if _, _, err := rd.ReadRepeatable("nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+
+	dup, err := iofs.New(fstest.MapFS{"R__views.sql": {Data: []byte("views")}}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithInstance(Source{Name: "core", Driver: core}, Source{Name: "dup", Driver: dup}); !errors.Is(err, ErrRepeatableCollision) {
+		t.Errorf("expected ErrRepeatableCollision, got %v", err)
+	}
+}
+
+// revisioner reports the version as the revision of every migration.
+type revisioner struct {
+	source.Driver
+}
+
+func (r *revisioner) Revision(version uint, direction source.Direction) (string, error) {
+	return fmt.Sprintf("rev-%d-%s", version, direction), nil
+}
+
+func TestRevision(t *testing.T) {
+	core := newStub(t, &source.Migration{Version: 1, Direction: source.Up})
+	billing := newStub(t, &source.Migration{Version: 2, Direction: source.Up})
+
+	d, err := WithInstance(Source{Name: "core", Driver: &revisioner{Driver: core}}, Source{Name: "billing", Driver: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := d.(source.Revisioner)
+
+	if revision, err := rv.Revision(1, source.Up); err != nil || revision != "rev-1-up" {
+		t.Errorf("expected revision rev-1-up, got %q, %v", revision, err)
+	}
+	if revision, err := rv.Revision(2, source.Up); err != nil || revision != "" {
+		t.Errorf("expected no revision, got %q, %v", revision, err)
+	}
+	if _, err := rv.Revision(3, source.Up); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tmpDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tmpDir, "core"), "1_init.up.sql", "1 up")
+	mustWriteFile(t, filepath.Join(tmpDir, "billing"), "2_invoices.up.sql", "2 up")
+
+	m := &Multi{}
+	d, err := m.Open("multi://?src=file://" + filepath.Join(tmpDir, "core") + "&src=file://" + filepath.Join(tmpDir, "billing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	r, identifier, err := d.ReadUp(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identifier != "billing/invoices" {
+		t.Errorf("expected identifier billing/invoices, got %v", identifier)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "2 up" {
+		t.Errorf("expected body '2 up', got %q", body)
+	}
+
+	if _, err := m.Open("multi://"); !errors.Is(err, ErrNoSources) {
+		t.Errorf("expected ErrNoSources, got %v", err)
+	}
+}
+
+func TestSourceName(t *testing.T) {
+	tt := map[string]string{
+		"file://core":                    "core",
+		"file:///srv/app/db/migrations":  "migrations",
+		"github://user/repo/migrations/": "migrations",
+		"stub://":                        "stub://",
+	}
+	for src, expected := range tt {
+		if name := sourceName(src); name != expected {
+			t.Errorf("%v: expected %v, got %v", src, expected, name)
+		}
+	}
+}
+
+func mustWriteFile(t testing.TB, dir, file string, body string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
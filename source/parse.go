@@ -1,9 +1,11 @@
 package source
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -11,8 +13,10 @@ var (
 )
 
 var (
-	DefaultParse = Parse
-	DefaultRegex = Regex
+	DefaultParse           = Parse
+	DefaultRegex           = Regex
+	DefaultRepeatableParse = ParseRepeatable
+	DefaultSingleFileParse = ParseSingleFile
 )
 
 // Regex matches the following pattern:
@@ -38,3 +42,110 @@ func Parse(raw string) (*Migration, error) {
 	}
 	return nil, ErrParse
 }
+
+// RepeatableRegex matches the following pattern:
+//
+//	R__name.ext
+var RepeatableRegex = regexp.MustCompile(`^R__(.+)\.([^.]+)$`)
+
+// ParseRepeatable returns Repeatable for matching RepeatableRegex pattern.
+func ParseRepeatable(raw string) (*Repeatable, error) {
+	m := RepeatableRegex.FindStringSubmatch(raw)
+	if len(m) == 3 {
+		return &Repeatable{
+			Name: m[1],
+			Raw:  raw,
+		}, nil
+	}
+	return nil, ErrParse
+}
+
+// SingleFileRegex matches the following pattern:
+//
+//	123_name.ext
+//
+// Names matching Regex match SingleFileRegex too, so Parse must be tried first.
+var SingleFileRegex = regexp.MustCompile(`^([0-9]+)_(.*)\.([^.]+)$`)
+
+// ParseSingleFile returns Migration for matching SingleFileRegex pattern.
+// The returned Migration has no Direction, the file holds both, see ParseSections.
+func ParseSingleFile(raw string) (*Migration, error) {
+	m := SingleFileRegex.FindStringSubmatch(raw)
+	if len(m) == 4 {
+		versionUint64, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Migration{
+			Version:    uint(versionUint64),
+			Identifier: m[2],
+			Raw:        raw,
+		}, nil
+	}
+	return nil, ErrParse
+}
+
+// SectionRegex matches the lines starting a section of a single-file migration:
+//
+//	-- +migrate Up
+//	-- +migrate Down
+var SectionRegex = regexp.MustCompile(`(?i)^\s*--\s*\+migrate\s+(` + string(Up) + `|` + string(Down) + `)\b.*$`)
+
+// ErrNoSections is returned by ParseSections for content without any section.
+var ErrNoSections = fmt.Errorf("no +migrate sections")
+
+// ParseSections splits the content of a single-file migration into its up and down sections.
+// Content before the first section is ignored. Only the sections present in the content are returned.
+func ParseSections(content []byte) (map[Direction][]byte, error) {
+	sections := make(map[Direction][]byte, 2)
+
+	var current Direction
+	var body bytes.Buffer
+	flush := func() {
+		if current != "" {
+			sections[current] = append([]byte(nil), body.Bytes()...)
+		}
+		body.Reset()
+	}
+
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if m := SectionRegex.FindSubmatch(bytes.TrimRight(line, "\r\n")); m != nil {
+			flush()
+			current = Direction(strings.ToLower(string(m[1])))
+			if _, dup := sections[current]; dup {
+				return nil, fmt.Errorf("duplicate +migrate %s section", current)
+			}
+			continue
+		}
+		body.Write(line)
+	}
+	flush()
+
+	if len(sections) == 0 {
+		return nil, ErrNoSections
+	}
+
+	return sections, nil
+}
+
+// TagSeparator separates the tags of a migration from its title in filenames:
+//
+//	123_seed_users@seed@dev.up.sql
+const TagSeparator = "@"
+
+// IdentifierTags returns the tags in the last path element of a migration identifier,
+// e.g. ["seed", "dev"] for "seed_users@seed@dev" and "core/seed_users@seed@dev".
+func IdentifierTags(identifier string) []string {
+	if i := strings.LastIndex(identifier, "/"); i >= 0 {
+		identifier = identifier[i+1:]
+	}
+
+	parts := strings.Split(identifier, TagSeparator)
+	var tags []string
+	for _, tag := range parts[1:] {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
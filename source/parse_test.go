@@ -104,3 +104,105 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRepeatable(t *testing.T) {
+	tt := []struct {
+		name             string
+		expectErr        error
+		expectRepeatable *Repeatable
+	}{
+		{
+			name:             "R__views.sql",
+			expectRepeatable: &Repeatable{Name: "views", Raw: "R__views.sql"},
+		},
+		{
+			name:             "R__refresh_stats.v2.sql",
+			expectRepeatable: &Repeatable{Name: "refresh_stats.v2", Raw: "R__refresh_stats.v2.sql"},
+		},
+		{name: "R_views.sql", expectErr: ErrParse},
+		{name: "R__.sql", expectErr: ErrParse},
+		{name: "R__views", expectErr: ErrParse},
+		{name: "1_foobar.up.sql", expectErr: ErrParse},
+	}
+
+	for i, v := range tt {
+		t.Run(v.name, func(t *testing.T) {
+			r, err := ParseRepeatable(v.name)
+			if err != v.expectErr {
+				t.Fatalf("expected %v, got %v, in %v", v.expectErr, err, i)
+			}
+			if v.expectRepeatable != nil && *r != *v.expectRepeatable {
+				t.Errorf("expected %+v, got %+v, in %v", *v.expectRepeatable, *r, i)
+			}
+		})
+	}
+}
+
+func TestParseSingleFile(t *testing.T) {
+	m, err := ParseSingleFile("20240101_add_users.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Migration{Version: 20240101, Identifier: "add_users", Raw: "20240101_add_users.sql"}
+	if *m != expect {
+		t.Errorf("expected %+v, got %+v", expect, *m)
+	}
+
+	if _, err := ParseSingleFile("add_users.sql"); err != ErrParse {
+		t.Errorf("expected ErrParse, got %v", err)
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	content := "-- leading comment\n" +
+		"-- +migrate Up\n" +
+		"CREATE TABLE users (id INT);\n" +
+		"--+migrate down notransaction\r\n" +
+		"DROP TABLE users;\n"
+
+	sections, err := ParseSections([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(sections[Up]); got != "CREATE TABLE users (id INT);\n" {
+		t.Errorf("unexpected up section %q", got)
+	}
+	if got := string(sections[Down]); got != "DROP TABLE users;\n" {
+		t.Errorf("unexpected down section %q", got)
+	}
+
+	if _, err := ParseSections([]byte("CREATE TABLE users (id INT);\n")); err != ErrNoSections {
+		t.Errorf("expected ErrNoSections, got %v", err)
+	}
+	if _, err := ParseSections([]byte("-- +migrate Up\nA;\n-- +migrate Up\nB;\n")); err == nil {
+		t.Error("expected error for duplicate section")
+	}
+}
+
+func TestIdentifierTags(t *testing.T) {
+	tt := []struct {
+		identifier string
+		expect     []string
+	}{
+		{identifier: "seed_users"},
+		{identifier: "seed_users@seed", expect: []string{"seed"}},
+		{identifier: "seed_users@seed@dev", expect: []string{"seed", "dev"}},
+		{identifier: "core/seed_users@seed", expect: []string{"seed"}},
+		{identifier: "core@x/seed_users"},
+		{identifier: "seed_users@"},
+	}
+
+	for _, v := range tt {
+		t.Run(v.identifier, func(t *testing.T) {
+			tags := IdentifierTags(v.identifier)
+			if len(tags) != len(v.expect) {
+				t.Fatalf("expected %v, got %v", v.expect, tags)
+			}
+			for i := range tags {
+				if tags[i] != v.expect[i] {
+					t.Errorf("expected %v, got %v", v.expect, tags)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package signed
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/abramad-labs/histomigrate/source"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Repeatable is the direction of repeatable migrations in a manifest, which have no version.
+const Repeatable source.Direction = "repeatable"
+
+var (
+	ErrInvalidSignature = errors.New("invalid manifest signature")
+	ErrInvalidKey       = errors.New("invalid ed25519 key")
+
+	// ErrMissingFromSource is reported for migrations listed in the manifest that the source doesn't provide.
+	ErrMissingFromSource = errors.New("migration is missing from the source")
+)
+
+// Entry lists one migration in a manifest.
+type Entry struct {
+	Version   uint
+	Direction source.Direction
+	// Identifier is the identifier returned by the source, or the name of a repeatable migration.
+	Identifier string
+	// Digest is the hex encoded SHA-256 of the migration body.
+	Digest string
+}
+
+// Manifest lists the migrations of a source. It is encoded as one line per migration:
+//
+//	<version> <up|down|repeatable> <sha256> <identifier>
+type Manifest struct {
+	Entries []Entry
+}
+
+// Build reads every migration of d and returns its manifest.
+func Build(d source.Driver) (*Manifest, error) {
+	m := &Manifest{}
+	err := walk(d, func(e Entry, r io.ReadCloser) error {
+		digest, err := digestOf(r)
+		if err != nil {
+			if e.Direction == Repeatable {
+				return fmt.Errorf("failed to read repeatable migration %s: %w", e.Identifier, err)
+			}
+			return fmt.Errorf("failed to read %s migration %d: %w", e.Direction, e.Version, err)
+		}
+		e.Digest = digest
+		m.Entries = append(m.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// walk opens every migration of d, in the order of a manifest, and calls f with its entry, without digest.
+// f must close r.
+func walk(d source.Driver, f func(e Entry, r io.ReadCloser) error) error {
+	version, err := d.First()
+	for err == nil {
+		for _, direction := range []source.Direction{source.Up, source.Down} {
+			read := d.ReadUp
+			if direction == source.Down {
+				read = d.ReadDown
+			}
+			r, identifier, err := read(version)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := f(Entry{Version: version, Direction: direction, Identifier: identifier}, r); err != nil {
+				return err
+			}
+		}
+		version, err = d.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if rd, ok := d.(source.RepeatableDriver); ok {
+		names, err := rd.Repeatables()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			r, _, err := rd.ReadRepeatable(name)
+			if err != nil {
+				return err
+			}
+			if err := f(Entry{Direction: Repeatable, Identifier: name}, r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseManifest parses an encoded manifest.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("manifest line %d: expected version, direction, digest and identifier", n)
+		}
+		version, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", n, err)
+		}
+		direction := source.Direction(fields[1])
+		if direction != source.Up && direction != source.Down && direction != Repeatable {
+			return nil, fmt.Errorf("manifest line %d: unknown direction %q", n, fields[1])
+		}
+		if _, err := hex.DecodeString(fields[2]); err != nil || len(fields[2]) != 2*sha256.Size {
+			return nil, fmt.Errorf("manifest line %d: invalid digest %q", n, fields[2])
+		}
+
+		m.Entries = append(m.Entries, Entry{Version: uint(version), Direction: direction, Digest: fields[2], Identifier: fields[3]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Bytes encodes the manifest, which is what gets signed.
+func (m *Manifest) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, e := range m.Entries {
+		fmt.Fprintf(&buf, "%d %s %s %s\n", e.Version, e.Direction, e.Digest, e.Identifier)
+	}
+	return buf.Bytes()
+}
+
+// Sign returns the base64 encoded signature of an encoded manifest.
+func Sign(manifest []byte, key ed25519.PrivateKey) []byte {
+	sig := ed25519.Sign(key, manifest)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// Verify checks the base64 encoded signature of an encoded manifest and parses it.
+func Verify(manifest []byte, signature []byte, key ed25519.PublicKey) (*Manifest, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || !ed25519.Verify(key, manifest, sig) {
+		return nil, ErrInvalidSignature
+	}
+	return ParseManifest(manifest)
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8 key, as written by
+// openssl genpkey -algorithm ed25519, or a base64 encoded key or seed.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		if k, ok := key.(ed25519.PrivateKey); ok {
+			return k, nil
+		}
+		return nil, ErrInvalidKey
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// ParsePublicKey parses a PEM encoded PKIX key, as written by
+// openssl pkey -pubout, or a base64 encoded key.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		if k, ok := key.(ed25519.PublicKey); ok {
+			return k, nil
+		}
+		return nil, ErrInvalidKey
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// digestOf reads and closes r and returns the hex encoded SHA-256 of its content.
+func digestOf(r io.ReadCloser) (string, error) {
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if errClose := r.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// key identifies an entry of a manifest.
+type key struct {
+	version   uint
+	direction source.Direction
+	name      string // repeatables only
+}
+
+func (e Entry) key() key {
+	k := key{version: e.Version, direction: e.Direction}
+	if e.Direction == Repeatable {
+		k.name = e.Identifier
+	}
+	return k
+}
+
+func (m *Manifest) index() map[key]Entry {
+	idx := make(map[key]Entry, len(m.Entries))
+	for _, e := range m.Entries {
+		idx[e.key()] = e
+	}
+	return idx
+}
+
+// Check reads every migration of d and reports all of them that are missing from m or don't match it,
+// and all entries of m that d doesn't provide.
+func Check(d source.Driver, m *Manifest) error {
+	actual, err := Build(d)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	expected := m.index()
+	for _, e := range actual.Entries {
+		want, ok := expected[e.key()]
+		switch {
+		case !ok:
+			errs = multierror.Append(errs, fmt.Errorf("%s migration %d %s: %w", e.Direction, e.Version, e.Identifier, ErrNotInManifest))
+		case want.Identifier != e.Identifier || want.Digest != e.Digest:
+			errs = multierror.Append(errs, fmt.Errorf("%s migration %d %s: %w", e.Direction, e.Version, e.Identifier, ErrDigestMismatch))
+		}
+	}
+	return multierror.Append(errs, missingFromSource(m, actual.index())).ErrorOrNil()
+}
+
+// checkListing reports all migrations of d that are missing from m, and all entries of m that d doesn't provide,
+// without reading their bodies.
+func checkListing(d source.Driver, m *Manifest) error {
+	var errs error
+	expected := m.index()
+	provided := make(map[key]Entry)
+	err := walk(d, func(e Entry, r io.ReadCloser) error {
+		provided[e.key()] = e
+		if _, ok := expected[e.key()]; !ok {
+			errs = multierror.Append(errs, fmt.Errorf("%s migration %d %s: %w", e.Direction, e.Version, e.Identifier, ErrNotInManifest))
+		}
+		return r.Close()
+	})
+	if err != nil {
+		return err
+	}
+	return multierror.Append(errs, missingFromSource(m, provided)).ErrorOrNil()
+}
+
+// missingFromSource reports all entries of m that aren't provided.
+func missingFromSource(m *Manifest, provided map[key]Entry) error {
+	var errs error
+	for _, e := range m.Entries {
+		if _, ok := provided[e.key()]; !ok {
+			errs = multierror.Append(errs, fmt.Errorf("%s migration %d %s: %w", e.Direction, e.Version, e.Identifier, ErrMissingFromSource))
+		}
+	}
+	return errs
+}
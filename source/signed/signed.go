@@ -0,0 +1,266 @@
+// Package signed verifies migrations against a manifest signed with ed25519.
+//
+// A manifest lists the version, direction, identifier and SHA-256 digest of every migration of a source.
+// It is created and signed with the sign command of the CLI (or Build and Sign), typically after review,
+// and shipped alongside the migrations. The Signed driver wraps any source driver providing the migrations
+// of the manifest and refuses to read migrations that don't match it.
+package signed
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	"sort"
+
+	"github.com/abramad-labs/histomigrate/source"
+)
+
+func init() {
+	source.Register("signed", &Signed{})
+}
+
+var (
+	ErrNotInManifest  = errors.New("migration is missing from the manifest")
+	ErrDigestMismatch = errors.New("migration doesn't match the manifest")
+)
+
+// Signed is a source driver verifying the migrations of another driver against a signed manifest.
+// Migrations listed in the manifest that the wrapped driver doesn't provide fail with ErrMissingFromSource,
+// rather than being skipped like migrations the source doesn't have.
+type Signed struct {
+	driver   source.Driver
+	manifest map[key]Entry
+	versions []uint // listed in the manifest, in order
+	listed   map[uint]bool
+}
+
+// Open opens the src query parameter as the wrapped source and verifies the manifest, e.g.
+// signed://?src=file://db&manifest=db.manifest&key=release.pub
+// The signature is read from the signature query parameter, defaulting to the manifest path with ".sig" appended.
+func (s *Signed) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	for _, param := range []string{"src", "manifest", "key"} {
+		if q.Get(param) == "" {
+			return nil, fmt.Errorf("missing %s query parameter", param)
+		}
+	}
+	signaturePath := q.Get("signature")
+	if signaturePath == "" {
+		signaturePath = q.Get("manifest") + ".sig"
+	}
+
+	manifest, err := os.ReadFile(q.Get("manifest"))
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := os.ReadFile(q.Get("key"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := source.Open(q.Get("src"))
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := WithInstance(d, manifest, signature, key)
+	if err != nil {
+		if errClose := d.Close(); errClose != nil {
+			err = fmt.Errorf("%w, closing source: %v", err, errClose)
+		}
+		return nil, err
+	}
+	return sd, nil
+}
+
+// WithInstance wraps d, verifying its migrations against the encoded manifest.
+// It fails with ErrInvalidSignature unless signature is a valid signature of the manifest by key,
+// and with ErrNotInManifest or ErrMissingFromSource unless d provides exactly the migrations of the manifest.
+// The bodies of the migrations are verified as they are read.
+func WithInstance(d source.Driver, manifest []byte, signature []byte, key ed25519.PublicKey) (source.Driver, error) {
+	m, err := Verify(manifest, signature, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkListing(d, m); err != nil {
+		return nil, err
+	}
+
+	var versions []uint
+	listed := make(map[uint]bool)
+	for _, e := range m.Entries {
+		if e.Direction != Repeatable && !listed[e.Version] {
+			listed[e.Version] = true
+			versions = append(versions, e.Version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	return &Signed{
+		driver:   d,
+		manifest: m.index(),
+		versions: versions,
+		listed:   listed,
+	}, nil
+}
+
+func (s *Signed) Close() error {
+	return s.driver.Close()
+}
+
+// First returns the first version of the wrapped driver, failing with ErrMissingFromSource
+// if it skips a version of the manifest.
+func (s *Signed) First() (version uint, err error) {
+	version, err = s.driver.First()
+	if len(s.versions) > 0 && skips(version, err, s.versions[0], true) {
+		return 0, fmt.Errorf("migration %d: %w", s.versions[0], ErrMissingFromSource)
+	}
+	return version, err
+}
+
+// Prev returns the previous version of the wrapped driver, failing with ErrMissingFromSource
+// if it skips a version of the manifest on the way from a version of the manifest.
+func (s *Signed) Prev(version uint) (prevVersion uint, err error) {
+	prevVersion, err = s.driver.Prev(version)
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if s.listed[version] && i > 0 && skips(prevVersion, err, s.versions[i-1], false) {
+		return 0, fmt.Errorf("migration %d: %w", s.versions[i-1], ErrMissingFromSource)
+	}
+	return prevVersion, err
+}
+
+// Next returns the next version of the wrapped driver, failing with ErrMissingFromSource
+// if it skips a version of the manifest on the way from a version of the manifest.
+func (s *Signed) Next(version uint) (nextVersion uint, err error) {
+	nextVersion, err = s.driver.Next(version)
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] > version })
+	if s.listed[version] && i < len(s.versions) && skips(nextVersion, err, s.versions[i], true) {
+		return 0, fmt.Errorf("migration %d: %w", s.versions[i], ErrMissingFromSource)
+	}
+	return nextVersion, err
+}
+
+// ReadUp reads the up migration of version from the wrapped driver and verifies it against the manifest.
+func (s *Signed) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	k := key{version: version, direction: source.Up}
+	r, identifier, err = s.driver.ReadUp(version)
+	if err != nil {
+		return nil, "", s.missing(k, err)
+	}
+	return s.verify(k, r, identifier)
+}
+
+// ReadDown reads the down migration of version from the wrapped driver and verifies it against the manifest.
+func (s *Signed) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	k := key{version: version, direction: source.Down}
+	r, identifier, err = s.driver.ReadDown(version)
+	if err != nil {
+		return nil, "", s.missing(k, err)
+	}
+	return s.verify(k, r, identifier)
+}
+
+// Repeatables returns the repeatable migrations of the wrapped driver.
+func (s *Signed) Repeatables() ([]string, error) {
+	rd, ok := s.driver.(source.RepeatableDriver)
+	if !ok {
+		return nil, nil
+	}
+	return rd.Repeatables()
+}
+
+// ReadRepeatable reads the named repeatable migration from the wrapped driver and verifies it against the manifest.
+func (s *Signed) ReadRepeatable(name string) (r io.ReadCloser, identifier string, err error) {
+	rd, ok := s.driver.(source.RepeatableDriver)
+	if !ok {
+		return nil, "", &os.PathError{Op: "read repeatable " + name, Path: "signed", Err: os.ErrNotExist}
+	}
+	k := key{direction: Repeatable, name: name}
+	r, identifier, err = rd.ReadRepeatable(name)
+	if err != nil {
+		return nil, "", s.missing(k, err)
+	}
+	r, _, err = s.verify(k, r, name)
+	return r, identifier, err
+}
+
+// missing turns the os.ErrNotExist of the wrapped driver into ErrMissingFromSource for migrations of the manifest.
+func (s *Signed) missing(k key, err error) error {
+	e, ok := s.manifest[k]
+	if !ok || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if k.direction == Repeatable {
+		return fmt.Errorf("%s migration %s: %w", k.direction, k.name, ErrMissingFromSource)
+	}
+	return fmt.Errorf("%s migration %d %s: %w", k.direction, k.version, e.Identifier, ErrMissingFromSource)
+}
+
+// skips reports whether the version the wrapped driver moved to, with err, skips want, the version
+// the manifest lists there, moving upwards or downwards.
+func skips(version uint, err error, want uint, upwards bool) bool {
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	if upwards {
+		return version > want
+	}
+	return version < want
+}
+
+// verify reads the migration into memory, so that what was verified is what gets run.
+func (s *Signed) verify(k key, r io.ReadCloser, identifier string) (io.ReadCloser, string, error) {
+	body, err := io.ReadAll(r)
+	if errClose := r.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	e, ok := s.manifest[k]
+	if !ok {
+		return nil, "", fmt.Errorf("%s migration %d %s: %w", k.direction, k.version, identifier, ErrNotInManifest)
+	}
+	digest, _ := digestOf(io.NopCloser(bytes.NewReader(body)))
+	if e.Identifier != identifier || e.Digest != digest {
+		return nil, "", fmt.Errorf("%s migration %d %s: %w", k.direction, k.version, identifier, ErrDigestMismatch)
+	}
+
+	verified := io.NopCloser(bytes.NewReader(body))
+	if fb, ok := r.(funcBody); ok {
+		return &verifiedFunc{ReadCloser: verified, funcBody: fb}, identifier, nil
+	}
+	return verified, identifier, nil
+}
+
+// funcBody is implemented by bodies of migrations implemented in Go, see source/gofunc.
+type funcBody interface {
+	MigrationFunc() func(ctx context.Context, tx *sql.Tx) error
+}
+
+// verifiedFunc is the verified body of a migration implemented in Go. The manifest covers its body,
+// the name of the migration, and it keeps the function so that Migrate still calls it.
+type verifiedFunc struct {
+	io.ReadCloser
+	funcBody
+}
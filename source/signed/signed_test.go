@@ -0,0 +1,225 @@
+package signed
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abramad-labs/histomigrate/source/iofs"
+	st "github.com/abramad-labs/histomigrate/source/testing"
+)
+
+// migrations meet the driver test requirements.
+func migrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_foobar.up.sql":   {Data: []byte("1 up")},
+		"1_foobar.down.sql": {Data: []byte("1 down")},
+		"3_foobar.up.sql":   {Data: []byte("3 up")},
+		"4_foobar.up.sql":   {Data: []byte("4 up")},
+		"4_foobar.down.sql": {Data: []byte("4 down")},
+		"5_foobar.down.sql": {Data: []byte("5 down")},
+		"7_foobar.up.sql":   {Data: []byte("7 up")},
+		"7_foobar.down.sql": {Data: []byte("7 down")},
+		"R__views.sql":      {Data: []byte("views")},
+	}
+}
+
+// sign returns the signed manifest of fsys.
+func sign(t *testing.T, fsys fstest.MapFS, key ed25519.PrivateKey) (manifest []byte, signature []byte) {
+	t.Helper()
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Build(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest = m.Bytes()
+	return manifest, Sign(manifest, key)
+}
+
+func Test(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := migrations()
+	manifest, signature := sign(t, fsys, priv)
+
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := WithInstance(d, manifest, signature, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st.Test(t, sd)
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := migrations()
+	manifest, signature := sign(t, fsys, priv)
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithInstance(d, manifest, signature, otherPub); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for another key, got %v", err)
+	}
+	tampered := bytes.Replace(manifest, []byte("3 up "), []byte("2 up "), 1)
+	if _, err := WithInstance(d, tampered, signature, pub); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a tampered manifest, got %v", err)
+	}
+
+	// change reviewed migrations
+	fsys["3_foobar.up.sql"] = &fstest.MapFile{Data: []byte("3 up, changed")}
+	fsys["R__views.sql"] = &fstest.MapFile{Data: []byte("views, changed")}
+	d, err = iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := WithInstance(d, manifest, signature, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, err := sd.ReadUp(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(r); string(body) != "1 up" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if _, _, err := sd.ReadUp(3); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected ErrDigestMismatch, got %v", err)
+	}
+	if _, _, err := sd.(*Signed).ReadRepeatable("views"); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected ErrDigestMismatch, got %v", err)
+	}
+
+	// add an unreviewed migration
+	fsys["8_foobar.up.sql"] = &fstest.MapFile{Data: []byte("8 up")}
+	d, err = iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithInstance(d, manifest, signature, pub); !errors.Is(err, ErrNotInManifest) {
+		t.Errorf("expected ErrNotInManifest, got %v", err)
+	}
+
+	m, err := ParseManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(d, m)
+	if !errors.Is(err, ErrDigestMismatch) || !errors.Is(err, ErrNotInManifest) {
+		t.Errorf("expected Check to report the changed and the added migration, got %v", err)
+	}
+}
+
+func TestMissingFromSource(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := migrations()
+	manifest, signature := sign(t, fsys, priv)
+
+	d, err := iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := WithInstance(d, manifest, signature, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files deleted after the source was opened
+	delete(fsys, "4_foobar.down.sql")
+	delete(fsys, "R__views.sql")
+	if _, _, err := sd.ReadDown(4); !errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrMissingFromSource, got %v", err)
+	}
+	if _, _, err := sd.(*Signed).ReadRepeatable("views"); !errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrMissingFromSource, got %v", err)
+	}
+	// a direction the manifest doesn't list either is just missing
+	if _, _, err := sd.ReadDown(3); !errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	// a version deleted after the source was opened is skipped by the wrapped driver
+	delete(fsys, "4_foobar.up.sql")
+	if sd.(*Signed).driver, err = iofs.New(fsys, "."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sd.Next(3); !errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrMissingFromSource, got %v", err)
+	}
+	if _, err := sd.Prev(5); !errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrMissingFromSource, got %v", err)
+	}
+
+	// the listing is checked when opening the source
+	d, err = iofs.New(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithInstance(d, manifest, signature, pub); !errors.Is(err, ErrMissingFromSource) {
+		t.Errorf("expected ErrMissingFromSource, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPriv, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedPriv.Equal(priv) {
+		t.Error("PEM private key doesn't match")
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPub, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedPub.Equal(pub) {
+		t.Error("PEM public key doesn't match")
+	}
+
+	if _, err := ParsePublicKey([]byte("bm90IGEga2V5")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}